@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/taraxa/snapshots-api/internal/keyhash"
+)
+
+// runKeygen implements the keygen subcommand: it mints a new API key and prints it
+// together with a keys file entry that stores only its hash
+func runKeygen(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	algorithm := flags.String("hash", keyhash.Argon2id, "hash algorithm: sha256, argon2id or bcrypt")
+	id := flags.String("id", "", "key id shown in logs (required)")
+	owner := flags.String("owner", "", "key owner")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// The id is logged and exported with usage, so it must not be derived from the key
+	if *id == "" {
+		return errors.New("-id is required")
+	}
+
+	key, prefix, err := keyhash.Generate()
+	if err != nil {
+		return err
+	}
+	hash, err := keyhash.Hash(*algorithm, key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "API key (shown once, give it to the client):\n\n  %s\n\n", key)
+	fmt.Fprintf(out, "Keys file entry:\n\n")
+	fmt.Fprintf(out, "  - id: %s\n", *id)
+	if *owner != "" {
+		fmt.Fprintf(out, "    owner: %s\n", *owner)
+	}
+	fmt.Fprintf(out, "    prefix: %s\n", prefix)
+	fmt.Fprintf(out, "    hash: '%s'\n", hash)
+	return nil
+}
+
+func keygen() {
+	if err := runKeygen(os.Args[2:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "keygen: %v\n", err)
+		os.Exit(2)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,20 +14,224 @@ import (
 	"github.com/taraxa/snapshots-api/internal/api"
 	"github.com/taraxa/snapshots-api/internal/auth"
 	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/events"
+	"github.com/taraxa/snapshots-api/internal/freshness"
+	"github.com/taraxa/snapshots-api/internal/keystore"
+	"github.com/taraxa/snapshots-api/internal/metrics"
+	"github.com/taraxa/snapshots-api/internal/ratelimit"
 	"github.com/taraxa/snapshots-api/internal/service"
+	"github.com/taraxa/snapshots-api/internal/signedurl"
+	"github.com/taraxa/snapshots-api/internal/storage"
+	"github.com/taraxa/snapshots-api/internal/usage"
+	"github.com/taraxa/snapshots-api/internal/webhook"
 )
 
 func main() {
-	cfg := config.Load()
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		keygen()
+		return
+	}
+
+	cfg, err := config.LoadStrict()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Initialize snapshot service
-	snapshotService := service.NewSnapshotService(cfg.GCPBucketName, cfg.GCPBucketURL)
+	lister, err := storage.New(storage.Options{
+		Backend:           cfg.StorageBackend,
+		PublicURL:         cfg.StoragePublicURL,
+		Prefix:            cfg.StoragePrefix,
+		MaxPages:          cfg.StorageMaxPages,
+		GCPBucketName:     cfg.GCPBucketName,
+		GCPBucketURL:      cfg.GCPBucketURL,
+		S3Endpoint:        cfg.S3Endpoint,
+		S3Bucket:          cfg.S3Bucket,
+		S3Region:          cfg.S3Region,
+		S3AccessKeyID:     cfg.S3AccessKeyID,
+		S3SecretAccessKey: cfg.S3SecretAccessKey,
+		LocalDir:          cfg.LocalDir,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize storage backend: %v", err)
+	}
+	snapshotService := service.NewSnapshotServiceWithLister(lister)
+	snapshotService.SetCacheOptions(cfg.RefreshInterval, cfg.StaleAfter)
+	snapshotService.SetHistoryDepth(cfg.HistoryDepth)
+	snapshotService.SetMirrors(cfg.Mirrors)
+
+	// Initialize Prometheus metrics
+	var appMetrics *metrics.Metrics
+	if cfg.MetricsEnabled {
+		appMetrics = metrics.New()
+		appMetrics.RegisterCacheAge(snapshotService.CacheAge)
+		snapshotService.SetMetrics(appMetrics)
+	}
+
+	// Check snapshot freshness on every refresh and periodically in between
+	var notifiers []freshness.Notifier
+	if cfg.AlertWebhookURL != "" {
+		notifiers = append(notifiers, freshness.NewWebhookNotifier(cfg.AlertWebhookURL))
+	}
+	if cfg.AlertSlackURL != "" {
+		notifiers = append(notifiers, freshness.NewSlackNotifier(cfg.AlertSlackURL))
+	}
+	thresholds := make(map[string]freshness.Threshold, len(cfg.FreshnessThresholds))
+	for stream, threshold := range cfg.FreshnessThresholds {
+		thresholds[stream] = freshness.Threshold{MaxAge: threshold.MaxAge, MaxBlockStall: threshold.MaxBlockStall}
+	}
+	freshnessChecker := freshness.NewChecker(snapshotService.GetAllNetworks(), thresholds, notifiers...)
+	freshnessChecker.SetMetrics(appMetrics)
+	snapshotService.OnRefresh(func(event service.RefreshEvent) {
+		freshnessChecker.Check(event.Time, event.Snapshots)
+	})
+
+	// Notify webhooks about newly published snapshots
+	var webhooks *webhook.Dispatcher
+	if len(cfg.WebhookURLs) > 0 {
+		webhooks = webhook.NewDispatcher(cfg.WebhookURLs, cfg.WebhookSecret)
+		webhooks.SetRetry(cfg.WebhookMaxAttempts, webhook.DefaultBaseDelay)
+		webhooks.SetDeadLetter(cfg.WebhookDeadLetter)
+		snapshotService.OnRefresh(func(event service.RefreshEvent) {
+			webhooks.Publish(webhook.Published(event.Added, event.Time))
+		})
+	}
+
+	// Stream snapshot changes to /v1/events subscribers
+	eventBroker := events.NewBroker()
+	snapshotService.OnRefresh(func(event service.RefreshEvent) {
+		eventBroker.Publish(event.Previous, event.Current, event.Time)
+	})
+
+	// Keep the snapshot cache warm in the background
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	refreshDone := make(chan struct{})
+	go func() {
+		defer close(refreshDone)
+		snapshotService.Run(refreshCtx)
+	}()
+
+	// Streams age while refreshes fail, so check them against the clock as well
+	go freshnessChecker.Run(refreshCtx, freshness.DefaultCheckInterval)
+
+	webhooksDone := make(chan struct{})
+	go func() {
+		defer close(webhooksDone)
+		if webhooks != nil {
+			webhooks.Run(refreshCtx)
+		}
+	}()
+
+	// Account API key usage, persisted when a store file is configured
+	usageTracker := usage.NewTracker(snapshotService.GetAllNetworks())
+	var usageStore *usage.Store
+	if cfg.UsageStoreFile != "" {
+		usageStore, err = usage.OpenStore(cfg.UsageStoreFile)
+		if err != nil {
+			log.Fatalf("Failed to open usage store: %v", err)
+		}
+		if err := usageTracker.SetStore(usageStore); err != nil {
+			log.Fatalf("Failed to load usage: %v", err)
+		}
+	}
+	usageCtx, stopUsage := context.WithCancel(context.Background())
+	usageDone := make(chan struct{})
+	go func() {
+		defer close(usageDone)
+		usageTracker.Run(usageCtx, cfg.UsageFlushInterval)
+	}()
 
 	// Initialize authentication middleware
 	authMiddleware := auth.NewMiddleware(cfg)
 
+	// Keys managed through the admin API
+	var keyStore *keystore.BoltStore
+	if cfg.KeyStoreFile != "" {
+		keyStore, err = keystore.OpenBoltStore(cfg.KeyStoreFile)
+		if err != nil {
+			log.Fatalf("Failed to open key store: %v", err)
+		}
+		if err := authMiddleware.SetKeyStore(keyStore); err != nil {
+			log.Fatalf("Failed to load keys: %v", err)
+		}
+	}
+
+	// Accept JWTs from the identity provider alongside API keys
+	if cfg.JWTJWKS != "" {
+		jwks, err := auth.NewJWKS(cfg.JWTJWKS)
+		if err != nil {
+			log.Fatalf("Failed to load JWKS: %v", err)
+		}
+		go jwks.Run(refreshCtx, cfg.JWTJWKSRefresh)
+		authMiddleware.SetJWT(auth.NewJWTVerifier(jwks, cfg.JWTIssuer, cfg.JWTAudience, cfg.JWTClaims))
+	}
+
+	// Reload keys, access policy and cache settings on SIGHUP and when the config files change
+	configReloader := &reloader{config: cfg, authMiddleware: authMiddleware, snapshotService: snapshotService}
+	reloadSignal := make(chan os.Signal, 1)
+	signal.Notify(reloadSignal, syscall.SIGHUP)
+	go func() {
+		for range reloadSignal {
+			configReloader.reload("SIGHUP")
+		}
+	}()
+	if files := cfg.Files(); len(files) > 0 && cfg.ConfigWatchInterval > 0 {
+		go config.Watch(refreshCtx, cfg.ConfigWatchInterval, files, func() {
+			configReloader.reload("file changed")
+		})
+	}
+
 	// Initialize API handlers
 	handler := api.NewHandler(snapshotService, authMiddleware)
+	handler.SetMetrics(appMetrics)
+	handler.SetFreshness(freshnessChecker)
+	handler.SetWebhooks(webhooks)
+	handler.SetEvents(eventBroker)
+	handler.SetUsage(usageTracker)
+	handler.SetMirrors(cfg.Mirrors)
+	if keyStore != nil {
+		handler.SetKeyStore(keyStore)
+	}
+
+	// Sign download URLs of snapshot types that are not public
+	if cfg.URLSigningKeyFile != "" {
+		if cfg.StorageBackend != "gcs" {
+			log.Fatalf("URL_SIGNING_KEY_FILE signs GCS URLs and needs STORAGE_BACKEND=gcs, got %s", cfg.StorageBackend)
+		}
+		signer, err := signedurl.LoadSigner(cfg.URLSigningKeyFile, cfg.URLSigningExpiry)
+		if err != nil {
+			log.Fatalf("Failed to load URL signing key: %v", err)
+		}
+		handler.SetURLSigner(signer)
+	}
+
+	// Throttle anonymous clients per IP and API keys per tier
+	if cfg.RateLimitEnabled {
+		tiers := make(map[string]ratelimit.Limit, len(cfg.RateLimitTiers))
+		for tier, limit := range cfg.RateLimitTiers {
+			tiers[tier] = ratelimit.FromConfig(limit)
+		}
+		rateLimiter := ratelimit.NewLimiter(ratelimit.NewMemoryBackend(), ratelimit.FromConfig(cfg.RateLimitAnonymous), tiers)
+		if err := rateLimiter.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+		rateLimiter.SetMetrics(appMetrics)
+		handler.SetRateLimiter(rateLimiter)
+	}
+
+	// Stream snapshots through the service for clients that cannot reach the bucket
+	if cfg.DownloadProxyEnabled {
+		// The proxy fetches snapshots by their URL, which is relative for a local
+		// directory without STORAGE_PUBLIC_URL
+		if publicURL, err := url.Parse(lister.PublicURL()); err != nil || publicURL.Host == "" {
+			log.Fatalf("DOWNLOAD_PROXY_ENABLED needs STORAGE_PUBLIC_URL to be an absolute URL for the %s storage backend", cfg.StorageBackend)
+		}
+		var bandwidth *ratelimit.Bandwidth
+		if cfg.DownloadBandwidth > 0 {
+			bandwidth = ratelimit.NewBandwidth(cfg.DownloadBandwidth)
+		}
+		handler.SetDownloadProxy(bandwidth)
+	}
 
 	// Setup HTTP server
 	server := &http.Server{
@@ -36,6 +241,8 @@ func main() {
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	// Event streams never go idle, so end them when shutdown starts
+	server.RegisterOnShutdown(eventBroker.Close)
 
 	// Start server in goroutine
 	go func() {
@@ -52,6 +259,10 @@ func main() {
 
 	log.Println("Shutting down server...")
 
+	stopRefresh()
+	<-refreshDone
+	<-webhooksDone
+
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -60,5 +271,15 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Save the usage of the last requests
+	stopUsage()
+	<-usageDone
+	if usageStore != nil {
+		usageStore.Close()
+	}
+	if keyStore != nil {
+		keyStore.Close()
+	}
+
 	log.Println("Server exited")
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/service"
+)
+
+// reloader applies a freshly loaded configuration to the running server. API keys,
+// the access policy and cache settings take effect immediately; everything else
+// is logged as requiring a restart.
+type reloader struct {
+	mutex           sync.Mutex
+	config          *config.Config
+	authMiddleware  *auth.Middleware
+	snapshotService *service.SnapshotService
+}
+
+// reload loads the configuration again and swaps it in. An invalid configuration
+// is rejected and the current one stays active.
+func (r *reloader) reload(reason string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	next, err := config.LoadStrict()
+	if err != nil {
+		log.Printf("Rejected config reload (%s), keeping the current config: %v", reason, err)
+		return
+	}
+
+	changes := config.Changes(r.config, next)
+	if len(changes) == 0 {
+		log.Printf("Config reload (%s): no changes", reason)
+		return
+	}
+
+	r.authMiddleware.Reload(next)
+	r.snapshotService.SetCacheOptions(next.RefreshInterval, next.StaleAfter)
+	r.snapshotService.SetHistoryDepth(next.HistoryDepth)
+	r.config = next
+
+	log.Printf("Config reloaded (%s):", reason)
+	for _, change := range changes {
+		log.Printf("  %s", change)
+	}
+}
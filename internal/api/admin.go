@@ -0,0 +1,63 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/taraxa/snapshots-api/internal/usage"
+	"github.com/taraxa/snapshots-api/internal/webhook"
+)
+
+// deliveriesResponse lists webhook deliveries
+type deliveriesResponse struct {
+	Deliveries []webhook.Delivery `json:"deliveries"`
+}
+
+// usageResponse lists the usage of every API key seen by one replica
+type usageResponse struct {
+	// Replica is the host name of the replica the usage was counted on
+	Replica string         `json:"replica"`
+	Usage   []usage.Record `json:"usage"`
+}
+
+// replicaHeader names the replica that served a per-replica response
+const replicaHeader = "X-Snapshots-Replica"
+
+// listWebhookDeliveries handles GET /admin/webhooks/deliveries
+func (h *Handler) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", webhook.StatusPending, webhook.StatusDelivered, webhook.StatusFailed:
+	default:
+		writeError(w, http.StatusBadRequest, "bad_request", "status must be pending, delivered or failed")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, deliveriesResponse{Deliveries: h.webhooks.Deliveries(status)})
+}
+
+// listUsage handles GET /admin/usage, as JSON or with ?format=csv as a CSV export
+func (h *Handler) listUsage(w http.ResponseWriter, r *http.Request) {
+	records := h.usage.Records()
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Usage is counted per replica; name the replica so partial totals are not
+	// mistaken for the whole
+	replica, _ := os.Hostname()
+	w.Header().Set(replicaHeader, replica)
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, usageResponse{Replica: replica, Usage: records})
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="usage.csv"`)
+		if err := usage.WriteCSV(w, records); err != nil {
+			log.Printf("Error writing usage export: %v", err)
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "bad_request", "format must be json or csv")
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/usage"
+	"github.com/taraxa/snapshots-api/internal/webhook"
+)
+
+func TestHandler_ListWebhookDeliveries(t *testing.T) {
+	cfg := &config.Config{APIKeys: []string{"valid-key"}, AdminAPIKeys: []string{"admin-key"}}
+	handler := NewHandler(&MockSnapshotService{}, auth.NewMiddleware(cfg))
+
+	// Without a dispatcher the endpoint does not exist
+	rr := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(rr, httptest.NewRequest("GET", "/admin/webhooks/deliveries", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without webhooks, got %v", rr.Code)
+	}
+
+	dispatcher := webhook.NewDispatcher([]string{"http://hooks.invalid"}, "secret")
+	dispatcher.Publish([]webhook.Event{{ID: "event-1", Type: webhook.EventSnapshotPublished}})
+	handler.SetWebhooks(dispatcher)
+	routes := handler.Routes()
+
+	tests := []struct {
+		name           string
+		query          string
+		authHeader     string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{name: "no key", authHeader: "", expectedStatus: http.StatusUnauthorized},
+		{name: "regular API key", authHeader: "Bearer valid-key", expectedStatus: http.StatusUnauthorized},
+		{name: "admin key", authHeader: "Bearer admin-key", expectedStatus: http.StatusOK, expectedCount: 1},
+		{name: "status filter", query: "?status=failed", authHeader: "Bearer admin-key", expectedStatus: http.StatusOK, expectedCount: 0},
+		{name: "invalid status", query: "?status=unknown", authHeader: "Bearer admin-key", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/webhooks/deliveries"+tt.query, nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.expectedStatus)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			var response deliveriesResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if len(response.Deliveries) != tt.expectedCount {
+				t.Fatalf("Expected %d deliveries, got %d", tt.expectedCount, len(response.Deliveries))
+			}
+			if tt.expectedCount > 0 && (response.Deliveries[0].EventID != "event-1" || response.Deliveries[0].Status != webhook.StatusPending) {
+				t.Errorf("Unexpected delivery: %+v", response.Deliveries[0])
+			}
+		})
+	}
+}
+
+func TestHandler_ListUsage(t *testing.T) {
+	cfg := &config.Config{APIKeys: []string{"valid-key"}, AdminAPIKeys: []string{"admin-key"}}
+	handler := NewHandler(&MockSnapshotService{}, auth.NewMiddleware(cfg))
+	handler.SetUsage(usage.NewTracker([]models.Network{models.NetworkMainnet}))
+	routes := handler.Routes()
+
+	request := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+		return rr
+	}
+
+	request("/v1/networks/mainnet/snapshots/full/latest", "valid-key")
+	request("/v1/networks/mainnet/snapshots/full/latest/download", "valid-key")
+	request("/v1/networks/mainnet/snapshots/light/latest", "")
+
+	if rr := request("/admin/usage", "valid-key"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a regular API key, got %v", rr.Code)
+	}
+
+	rr := request("/admin/usage", "admin-key")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var response usageResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(response.Usage) != 1 {
+		t.Fatalf("Expected usage of one key, got %+v", response.Usage)
+	}
+	if hostname, _ := os.Hostname(); response.Replica != hostname || rr.Header().Get("X-Snapshots-Replica") != hostname {
+		t.Errorf("Expected usage to name replica %q, got %q", hostname, response.Replica)
+	}
+	// The rejected admin request counts as well
+	if record := response.Usage[0]; record.Key != "env-1" || record.Requests != 3 || record.Downloads != 1 || record.Types["full"] != 2 {
+		t.Errorf("Unexpected usage record: %+v", record)
+	}
+
+	rr = request("/admin/usage?format=csv", "admin-key")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("Expected CSV export, got %v %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(rr.Body.String(), "key,owner,requests") || !strings.Contains(rr.Body.String(), "\nenv-1,,3,1,") {
+		t.Errorf("Unexpected CSV export:\n%s", rr.Body.String())
+	}
+
+	if rr := request("/admin/usage?format=xml", "admin-key"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown format, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cacheMaxAge is how long clients may reuse a snapshot response without revalidating
+const cacheMaxAge = "max-age=300" // 5 minutes
+
+// writeCacheable writes value as a JSON response with validators, answering
+// conditional requests with 304 Not Modified. Authenticated responses are marked
+// private so shared caches never hand full snapshots to anonymous clients.
+func writeCacheable(w http.ResponseWriter, r *http.Request, value interface{}, lastModified time.Time, authenticated bool) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
+	header := w.Header()
+	header.Set("ETag", computeETag(body, authenticated))
+	header.Add("Vary", "Authorization")
+	if authenticated {
+		header.Set("Cache-Control", "private, "+cacheMaxAge)
+	} else {
+		header.Set("Cache-Control", "public, "+cacheMaxAge)
+	}
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, header.Get("ETag"), lastModified) {
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// computeETag returns a strong ETag over the response body. The view is part of the
+// hash so authenticated and filtered responses never share a tag, even when equal.
+func computeETag(body []byte, authenticated bool) string {
+	view := "public"
+	if authenticated {
+		view = "authenticated"
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(view))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// lastModified returns the Last-Modified time of a response. Responses with signed
+// URLs have none, because If-Modified-Since would keep serving a cached copy after
+// its URLs expire; their ETag changes as they are re-signed.
+func lastModified(modified time.Time, signed bool) time.Time {
+	if signed {
+		return time.Time{}
+	}
+	return modified
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since only when
+// no If-None-Match header is present (RFC 9110, section 13.2.2)
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(ifModifiedSince)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler_ConditionalRequests(t *testing.T) {
+	handler, _ := createTestHandler([]string{"valid-key"})
+	routes := handler.Routes()
+
+	serve := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+		return rr
+	}
+
+	paths := []string{
+		"/?network=mainnet",
+		"/v1/networks/mainnet/snapshots",
+		"/v1/networks/mainnet/snapshots/light/latest",
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			public := serve(path, nil)
+			authenticated := serve(path, map[string]string{"Authorization": "Bearer valid-key"})
+
+			for _, rr := range []*httptest.ResponseRecorder{public, authenticated} {
+				if rr.Code != http.StatusOK {
+					t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+				}
+				if rr.Header().Get("ETag") == "" {
+					t.Error("Expected an ETag")
+				}
+				if rr.Header().Get("Vary") != "Authorization" {
+					t.Errorf("Expected Vary: Authorization, got %q", rr.Header().Get("Vary"))
+				}
+				if rr.Header().Get("Last-Modified") != "Sun, 06 Jul 2025 14:30:00 GMT" {
+					t.Errorf("Unexpected Last-Modified %q", rr.Header().Get("Last-Modified"))
+				}
+			}
+
+			if public.Header().Get("Cache-Control") != "public, max-age=300" {
+				t.Errorf("Expected public caching, got %q", public.Header().Get("Cache-Control"))
+			}
+			if authenticated.Header().Get("Cache-Control") != "private, max-age=300" {
+				t.Errorf("Expected private caching for authenticated responses, got %q", authenticated.Header().Get("Cache-Control"))
+			}
+			if public.Header().Get("ETag") == authenticated.Header().Get("ETag") {
+				t.Error("Expected distinct ETags for authenticated and filtered views")
+			}
+
+			etag := public.Header().Get("ETag")
+			tests := []struct {
+				name           string
+				headers        map[string]string
+				expectedStatus int
+			}{
+				{name: "matching etag", headers: map[string]string{"If-None-Match": etag}, expectedStatus: http.StatusNotModified},
+				{name: "etag in list", headers: map[string]string{"If-None-Match": `"other", W/` + etag}, expectedStatus: http.StatusNotModified},
+				{name: "wildcard", headers: map[string]string{"If-None-Match": "*"}, expectedStatus: http.StatusNotModified},
+				{name: "stale etag", headers: map[string]string{"If-None-Match": `"other"`}, expectedStatus: http.StatusOK},
+				{name: "etag of another view", headers: map[string]string{"If-None-Match": authenticated.Header().Get("ETag")}, expectedStatus: http.StatusOK},
+				{name: "not modified since", headers: map[string]string{"If-Modified-Since": "Sun, 06 Jul 2025 14:30:00 GMT"}, expectedStatus: http.StatusNotModified},
+				{name: "modified since", headers: map[string]string{"If-Modified-Since": "Sun, 06 Jul 2025 14:29:59 GMT"}, expectedStatus: http.StatusOK},
+				{
+					name:           "if-none-match takes precedence",
+					headers:        map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": "Mon, 07 Jul 2025 00:00:00 GMT"},
+					expectedStatus: http.StatusOK,
+				},
+			}
+
+			for _, tt := range tests {
+				rr := serve(path, tt.headers)
+				if rr.Code != tt.expectedStatus {
+					t.Errorf("%s: expected status %d, got %d", tt.name, tt.expectedStatus, rr.Code)
+				}
+				if rr.Code == http.StatusNotModified {
+					if rr.Body.Len() != 0 {
+						t.Errorf("%s: expected empty body for 304", tt.name)
+					}
+					if rr.Header().Get("ETag") != etag {
+						t.Errorf("%s: expected ETag on 304", tt.name)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/parser"
+	"github.com/taraxa/snapshots-api/internal/ratelimit"
+	"github.com/taraxa/snapshots-api/internal/service"
+)
+
+// snapshotParser validates the filenames requested from the download proxy
+var snapshotParser = parser.NewSnapshotParser()
+
+// downloadProxy streams snapshots from the bucket through the service
+type downloadProxy struct {
+	client    *http.Client
+	bandwidth *ratelimit.Bandwidth
+}
+
+// newDownloadClient returns a client without an overall timeout, since multi-GB
+// downloads take long, that still gives up on an unresponsive bucket
+func newDownloadClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+			MaxIdleConnsPerHost:   16,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+}
+
+// proxiedHeaders are the upstream response headers passed on to the client
+var proxiedHeaders = []string{"Content-Length", "Content-Range", "Content-Type", "ETag", "Last-Modified"}
+
+// proxyDownload handles GET /v1/download/{filename} by streaming the snapshot from
+// the bucket. Range and If-Range are supported so interrupted downloads can resume.
+func (h *Handler) proxyDownload(w http.ResponseWriter, r *http.Request) {
+	filename := r.PathValue("filename")
+	snapshot, err := snapshotParser.ParseSnapshot(filename, "")
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not_found", "unknown snapshot file")
+		return
+	}
+
+	if !h.authorize(w, r, snapshot.Network, snapshot.Type) {
+		return
+	}
+
+	info, err := h.snapshotService.FindByFilename(snapshot.Network, snapshot.Type, filename)
+	if errors.Is(err, service.ErrSnapshotNotFound) {
+		writeError(w, http.StatusNotFound, "not_found", "unknown snapshot file")
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up snapshot %s: %v", filename, err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to fetch snapshots")
+		return
+	}
+
+	// Private buckets are read through a signed URL
+	upstream, _ := h.signInfo(snapshot.Network, snapshot.Type, info)
+	etag, lastModified := validators(info)
+
+	rangeHeader, ifRange := r.Header.Get("Range"), r.Header.Get("If-Range")
+	if rangeHeader == "" {
+		ifRange = ""
+	} else if matches, ok := ifRangeMatches(ifRange, etag, lastModified); ok {
+		// Evaluated against the listing; on a mismatch the client holds a different
+		// version and gets the whole object instead of a part
+		ifRange = ""
+		if !matches {
+			rangeHeader = ""
+		}
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, upstream.URL, nil)
+	if err != nil {
+		log.Printf("Error proxying snapshot %s: %v", filename, err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to fetch snapshot")
+		return
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	if ifRange != "" {
+		// Validators the listing does not know about are left to the bucket
+		req.Header.Set("If-Range", ifRange)
+	}
+
+	resp, err := h.downloads.client.Do(req)
+	if err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("Error fetching snapshot %s: %v", filename, err)
+			writeError(w, http.StatusBadGateway, "bad_gateway", "failed to fetch snapshot from storage")
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+	case http.StatusNotFound:
+		writeError(w, http.StatusNotFound, "not_found", "snapshot no longer exists in storage")
+		return
+	default:
+		log.Printf("Error fetching snapshot %s: storage returned status %d", filename, resp.StatusCode)
+		writeError(w, http.StatusBadGateway, "bad_gateway", "failed to fetch snapshot from storage")
+		return
+	}
+
+	header := w.Header()
+	for _, name := range proxiedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	header.Set("Cache-Control", "private")
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The length and range of the upstream body do not describe the error written instead
+		header.Del("Content-Length")
+		header.Del("Content-Range")
+		header.Set("Content-Type", "application/json")
+		w.WriteHeader(resp.StatusCode)
+		w.Write([]byte(`{"error": "range_not_satisfiable", "message": "requested range is outside the snapshot"}`))
+		return
+	}
+
+	// Count a download once, not for every range a resuming client requests
+	if resp.StatusCode == http.StatusOK || strings.HasPrefix(rangeHeader, "bytes=0-") {
+		h.usage.RecordDownload(r)
+	}
+
+	// Downloads outlive the server write timeout
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		log.Printf("Error clearing write deadline: %v", err)
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	body := h.downloads.bandwidth.Reader(r.Context(), h.downloadClient(r), resp.Body)
+	if _, err := io.Copy(w, body); err != nil && !errors.Is(err, context.Canceled) && r.Context().Err() == nil {
+		log.Printf("Error streaming snapshot %s: %v", filename, err)
+	}
+}
+
+// downloadClient identifies the caller whose bandwidth a download counts against
+func (h *Handler) downloadClient(r *http.Request) string {
+	if principal := h.authMiddleware.Principal(r); principal != nil {
+		return "key:" + principal.ID
+	}
+	return "ip:" + h.rateLimiter.ClientIP(r)
+}
+
+// validators returns the ETag and modification time of a snapshot, derived from
+// the listing so they are stable across requests and replicas
+func validators(info *models.SnapshotInfo) (string, time.Time) {
+	var etag string
+	if info.MD5 != nil && info.MD5.Hex != "" {
+		etag = `"` + info.MD5.Hex + `"`
+	}
+	lastModified, _ := time.Parse(time.RFC3339, info.Updated)
+	return etag, lastModified
+}
+
+// ifRangeMatches evaluates an If-Range header, a strong ETag or an exact date,
+// against the validators of the listing. ok is false when the listing lacks the
+// validator the header uses; a missing or weak If-Range never matches.
+func ifRangeMatches(ifRange, etag string, lastModified time.Time) (matches, ok bool) {
+	switch {
+	case ifRange == "":
+		return true, true
+	case strings.HasPrefix(ifRange, `"`):
+		if etag == "" {
+			return false, false
+		}
+		return ifRange == etag, true
+	case strings.HasPrefix(ifRange, "W/"):
+		return false, true
+	}
+
+	date, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false, true
+	}
+	if lastModified.IsZero() {
+		return false, false
+	}
+	return lastModified.Truncate(time.Second).Equal(date), true
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/service"
+	"github.com/taraxa/snapshots-api/internal/usage"
+)
+
+// newFakeObjectServer serves snapshot objects like a bucket, with Range and If-Range
+// support from http.ServeContent
+func newFakeObjectServer(t *testing.T, content []byte, updated time.Time) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/taraxa-snapshot/") || strings.Contains(r.URL.Path, "-block-12344-") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("ETag", `"upstream-etag"`)
+		http.ServeContent(w, r, "", updated, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHandler_ProxyDownload(t *testing.T) {
+	content := make([]byte, 256*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	digest := md5.Sum(content)
+	etag := `"` + hex.EncodeToString(digest[:]) + `"`
+	updated := time.Date(2025, 7, 6, 14, 45, 0, 0, time.UTC)
+	server := newFakeObjectServer(t, content, updated)
+
+	handler, mockService := createTestHandler([]string{"valid-api-key"})
+	// The history holds two snapshots of block 12345 and one of block 12344, all taken on July 6
+	mockService.FindByFilenameFunc = func(network models.Network, snapshotType models.SnapshotType, filename string) (*models.SnapshotInfo, error) {
+		snapshot, err := snapshotParser.ParseSnapshot(filename, "")
+		if err != nil || (snapshot.Block != 12345 && snapshot.Block != 12344) || snapshot.Timestamp.Day() != 6 {
+			return nil, service.ErrSnapshotNotFound
+		}
+		return &models.SnapshotInfo{
+			Block:   snapshot.Block,
+			URL:     server.URL + "/taraxa-snapshot/" + filename,
+			Updated: updated.Format(time.RFC3339),
+			MD5:     &models.Checksum{Hex: hex.EncodeToString(digest[:])},
+		}, nil
+	}
+	tracker := usage.NewTracker([]models.Network{models.NetworkMainnet})
+	handler.SetUsage(tracker)
+	handler.SetDownloadProxy(nil)
+	routes := handler.Routes()
+
+	const lightFile = "/v1/download/mainnet-light-db-block-12345-20250706-143000.tar.gz"
+	const fullFile = "/v1/download/mainnet-full-db-block-12345-20250706-143000.tar.gz"
+
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		headers         map[string]string
+		expectedStatus  int
+		expectedBody    []byte
+		expectedRange   string
+		expectRecording bool
+	}{
+		{name: "light without auth", path: lightFile, expectedStatus: http.StatusOK, expectedBody: content},
+		{name: "full requires auth", path: fullFile, expectedStatus: http.StatusUnauthorized},
+		{
+			name:            "full with auth",
+			path:            fullFile,
+			headers:         map[string]string{"Authorization": "Bearer valid-api-key"},
+			expectedStatus:  http.StatusOK,
+			expectedBody:    content,
+			expectRecording: true,
+		},
+		{
+			name:            "first range counts as a download",
+			path:            fullFile,
+			headers:         map[string]string{"Authorization": "Bearer valid-api-key", "Range": "bytes=0-99"},
+			expectedStatus:  http.StatusPartialContent,
+			expectedBody:    content[:100],
+			expectedRange:   fmt.Sprintf("bytes 0-99/%d", len(content)),
+			expectRecording: true,
+		},
+		{
+			name:           "resume",
+			path:           fullFile,
+			headers:        map[string]string{"Authorization": "Bearer valid-api-key", "Range": "bytes=1000-"},
+			expectedStatus: http.StatusPartialContent,
+			expectedBody:   content[1000:],
+			expectedRange:  fmt.Sprintf("bytes 1000-%d/%d", len(content)-1, len(content)),
+		},
+		{
+			name:           "resume with matching etag",
+			path:           lightFile,
+			headers:        map[string]string{"Range": "bytes=1000-1999", "If-Range": etag},
+			expectedStatus: http.StatusPartialContent,
+			expectedBody:   content[1000:2000],
+			expectedRange:  fmt.Sprintf("bytes 1000-1999/%d", len(content)),
+		},
+		{
+			name:           "resume with matching date",
+			path:           lightFile,
+			headers:        map[string]string{"Range": "bytes=1000-1999", "If-Range": updated.Format(http.TimeFormat)},
+			expectedStatus: http.StatusPartialContent,
+			expectedBody:   content[1000:2000],
+			expectedRange:  fmt.Sprintf("bytes 1000-%d/%d", 1999, len(content)),
+		},
+		{
+			name:           "resume of a changed object restarts",
+			path:           lightFile,
+			headers:        map[string]string{"Range": "bytes=1000-1999", "If-Range": `"stale"`},
+			expectedStatus: http.StatusOK,
+			expectedBody:   content,
+		},
+		{
+			name:           "range outside the object",
+			path:           lightFile,
+			headers:        map[string]string{"Range": fmt.Sprintf("bytes=%d-", len(content)+10)},
+			expectedStatus: http.StatusRequestedRangeNotSatisfiable,
+		},
+		{name: "head", method: http.MethodHead, path: lightFile, expectedStatus: http.StatusOK, expectedBody: []byte{}},
+		{name: "not a snapshot", path: "/v1/download/keys.yaml", expectedStatus: http.StatusNotFound},
+		{name: "unknown snapshot", path: "/v1/download/mainnet-light-db-block-99999-20250706-143000.tar.gz", expectedStatus: http.StatusNotFound},
+		{name: "older snapshot at the block", path: "/v1/download/mainnet-light-db-block-12345-20250706-000000.tar.gz", expectedStatus: http.StatusOK, expectedBody: content},
+		{name: "unknown snapshot at the block", path: "/v1/download/mainnet-light-db-block-12345-20250707-000000.tar.gz", expectedStatus: http.StatusNotFound},
+		{name: "missing from storage", path: "/v1/download/mainnet-light-db-block-12344-20250706-143000.tar.gz", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := totalDownloads(tracker)
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, tt.expectedStatus, rr.Body.String())
+			}
+			if tt.expectedBody != nil && !bytes.Equal(rr.Body.Bytes(), tt.expectedBody) {
+				t.Errorf("Expected %d bytes of body, got %d", len(tt.expectedBody), rr.Body.Len())
+			}
+			if got := rr.Header().Get("Content-Range"); tt.expectedRange != "" && got != tt.expectedRange {
+				t.Errorf("Expected Content-Range %q, got %q", tt.expectedRange, got)
+			}
+			if rr.Code == http.StatusOK || rr.Code == http.StatusPartialContent {
+				if rr.Header().Get("ETag") != etag || rr.Header().Get("Accept-Ranges") != "bytes" || rr.Header().Get("Last-Modified") != updated.Format(http.TimeFormat) {
+					t.Errorf("Expected validators from the listing, got %v", rr.Header())
+				}
+				if !strings.Contains(rr.Header().Get("Content-Disposition"), "attachment") {
+					t.Errorf("Expected an attachment, got %q", rr.Header().Get("Content-Disposition"))
+				}
+			}
+			if rr.Code == http.StatusRequestedRangeNotSatisfiable {
+				if rr.Header().Get("Content-Length") != "" || rr.Header().Get("Content-Range") != "" || !strings.Contains(rr.Body.String(), "range_not_satisfiable") {
+					t.Errorf("Expected a JSON error without upstream framing headers, got %v %s", rr.Header(), rr.Body.String())
+				}
+			}
+			if recorded := totalDownloads(tracker) > before; recorded != tt.expectRecording {
+				t.Errorf("Expected download recorded: %v, got %v", tt.expectRecording, recorded)
+			}
+		})
+	}
+}
+
+func TestHandler_ProxyDownload_UpstreamValidators(t *testing.T) {
+	content := []byte(strings.Repeat("snapshot", 1000))
+	server := newFakeObjectServer(t, content, time.Time{})
+
+	// Without an MD5 in the listing, the ETag of the bucket is passed on and If-Range
+	// is left to the bucket to evaluate
+	handler, mockService := createTestHandler(nil)
+	mockService.FindByFilenameFunc = func(network models.Network, snapshotType models.SnapshotType, filename string) (*models.SnapshotInfo, error) {
+		return &models.SnapshotInfo{Block: 12345, URL: server.URL + "/taraxa-snapshot/" + filename}, nil
+	}
+	handler.SetDownloadProxy(nil)
+	routes := handler.Routes()
+
+	for ifRange, expectedStatus := range map[string]int{`"upstream-etag"`: http.StatusPartialContent, `"stale"`: http.StatusOK} {
+		req := httptest.NewRequest("GET", "/v1/download/mainnet-light-db-block-12345-20250706-143000.tar.gz", nil)
+		req.Header.Set("Range", "bytes=8-15")
+		req.Header.Set("If-Range", ifRange)
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != expectedStatus {
+			t.Errorf("If-Range %s: expected status %d, got %d", ifRange, expectedStatus, rr.Code)
+		}
+		if rr.Header().Get("ETag") != `"upstream-etag"` {
+			t.Errorf("Expected the ETag of the bucket, got %q", rr.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestHandler_ProxyDownload_Disabled(t *testing.T) {
+	handler, _ := createTestHandler(nil)
+	rr := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/download/mainnet-light-db-block-12345-20250706-143000.tar.gz", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without the download proxy, got %d", rr.Code)
+	}
+}
+
+func TestIfRangeMatches(t *testing.T) {
+	updated := time.Date(2025, 7, 6, 14, 45, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		ifRange      string
+		etag         string
+		lastModified time.Time
+		matches      bool
+		ok           bool
+	}{
+		{name: "no header", matches: true, ok: true},
+		{name: "matching etag", ifRange: `"abc"`, etag: `"abc"`, matches: true, ok: true},
+		{name: "different etag", ifRange: `"old"`, etag: `"abc"`, ok: true},
+		{name: "unknown etag", ifRange: `"abc"`, ok: false},
+		{name: "weak etag", ifRange: `W/"abc"`, etag: `"abc"`, ok: true},
+		{name: "matching date", ifRange: updated.Format(http.TimeFormat), lastModified: updated, matches: true, ok: true},
+		{name: "older date", ifRange: updated.Add(-time.Hour).Format(http.TimeFormat), lastModified: updated, ok: true},
+		{name: "unknown date", ifRange: updated.Format(http.TimeFormat), ok: false},
+		{name: "malformed", ifRange: "yesterday", lastModified: updated, ok: true},
+	}
+
+	for _, tt := range tests {
+		matches, ok := ifRangeMatches(tt.ifRange, tt.etag, tt.lastModified)
+		if matches != tt.matches || ok != tt.ok {
+			t.Errorf("%s: got (%v, %v), expected (%v, %v)", tt.name, matches, ok, tt.matches, tt.ok)
+		}
+	}
+}
+
+func totalDownloads(tracker *usage.Tracker) int64 {
+	var total int64
+	for _, record := range tracker.Records() {
+		total += record.Downloads
+	}
+	return total
+}
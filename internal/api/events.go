@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/events"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// heartbeatInterval is how often an idle event stream sends a comment to keep proxies from closing it
+var heartbeatInterval = 15 * time.Second
+
+// errStreamUnauthorized ends an event stream whose key is no longer valid
+var errStreamUnauthorized = errors.New("key no longer valid")
+
+// streamEvents handles GET /v1/events?network= as a Server-Sent Events stream of snapshot changes
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	network := r.URL.Query().Get("network")
+	if network == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "network parameter is required")
+		return
+	}
+	if !h.snapshotService.IsValidNetwork(network) {
+		writeError(w, http.StatusNotFound, "not_found", fmt.Sprintf("unknown network %q", network))
+		return
+	}
+
+	choice, ok := h.preferredMirror(w, r)
+	if !ok {
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	principal := h.authMiddleware.Principal(r)
+
+	// Streams outlive the server write timeout
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		log.Printf("Error clearing write deadline: %v", err)
+	}
+
+	subscription, backlog := h.events.Subscribe(models.Network(network), lastEventID)
+	defer h.events.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// authorized re-checks the key that opened the stream, so a key revoked, expired or
+	// rescoped while the stream is open gets no further snapshots
+	authorized := func() bool {
+		if principal == nil {
+			return true
+		}
+		id := principal.ID
+		if principal = h.authMiddleware.Resolve(r); principal == nil {
+			log.Printf("Closing event stream of API key %s: key no longer valid", id)
+			return false
+		}
+		return true
+	}
+
+	// sent is the filtered view of the last event, compared before signing so that
+	// changes the caller cannot see are not sent as an identical event
+	var sent *models.NetworkSnapshots
+	send := func(event events.Event) error {
+		if !authorized() {
+			return errStreamUnauthorized
+		}
+		filtered := h.authMiddleware.Policy().Filter(principal, event.Network, event.Snapshots)
+		if sent != nil && reflect.DeepEqual(sent, filtered) {
+			return nil
+		}
+		sent = filtered
+
+		snapshots, _ := h.signSnapshots(event.Network, filtered)
+		snapshots = h.mirrorSnapshots(choice, snapshots)
+		data, err := json.Marshal(snapshots)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: snapshots\ndata: %s\n\n", event.ID, data); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+
+	for _, event := range backlog {
+		if err := send(event); err != nil {
+			return
+		}
+	}
+	if len(backlog) == 0 {
+		// Confirm the stream is open before the first change arrives
+		if _, err := fmt.Fprint(w, ": connected\n\n"); err != nil || controller.Flush() != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if !authorized() {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil || controller.Flush() != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/events"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// readEvent reads one SSE frame, skipping comments, and returns its fields
+func readEvent(t *testing.T, reader *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			fields["comment"] = strings.TrimSpace(line[1:])
+			return fields
+		}
+		name, value, _ := strings.Cut(line, ": ")
+		fields[name] = value
+	}
+}
+
+func TestHandler_StreamEvents(t *testing.T) {
+	handler, _ := createTestHandler([]string{"valid-key"})
+	broker := events.NewBroker()
+	handler.SetEvents(broker)
+
+	previous := map[models.Network]*models.NetworkSnapshots{}
+	current := map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: {
+			Full:  &models.SnapshotInfo{Block: 100, URL: "https://example.com/full-100"},
+			Light: &models.SnapshotInfo{Block: 100, URL: "https://example.com/light-100"},
+		},
+	}
+	broker.Publish(previous, current, time.Now())
+	_, backlog := broker.Subscribe(models.NetworkMainnet, "")
+
+	server := httptest.NewServer(handler.Routes())
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		authHeader   string
+		expectedFull bool
+	}{
+		{name: "unauthenticated gets light only", authHeader: "", expectedFull: false},
+		{name: "authenticated gets full", authHeader: "Bearer valid-key", expectedFull: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", server.URL+"/v1/events?network=mainnet", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.Header.Get("Content-Type") != "text/event-stream" {
+				t.Errorf("Expected text/event-stream, got %s", resp.Header.Get("Content-Type"))
+			}
+
+			event := readEvent(t, bufio.NewReader(resp.Body))
+			if event["id"] != backlog[0].ID || event["event"] != "snapshots" {
+				t.Errorf("Unexpected initial event: %v", event)
+			}
+			if hasFull := strings.Contains(event["data"], "full-100"); hasFull != tt.expectedFull {
+				t.Errorf("Expected full snapshot in data: %v, got %s", tt.expectedFull, event["data"])
+			}
+			if !strings.Contains(event["data"], "light-100") {
+				t.Errorf("Expected light snapshot in data, got %s", event["data"])
+			}
+		})
+	}
+}
+
+func TestHandler_StreamEvents_ResumeAndHeartbeat(t *testing.T) {
+	handler, _ := createTestHandler([]string{})
+	broker := events.NewBroker()
+	handler.SetEvents(broker)
+
+	defer func(interval time.Duration) { heartbeatInterval = interval }(heartbeatInterval)
+	heartbeatInterval = 20 * time.Millisecond
+
+	first := map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: {Light: &models.SnapshotInfo{Block: 100}},
+	}
+	broker.Publish(map[models.Network]*models.NetworkSnapshots{}, first, time.Now())
+	_, backlog := broker.Subscribe(models.NetworkMainnet, "")
+
+	server := httptest.NewServer(handler.Routes())
+	defer server.Close()
+
+	// Up to date clients get no replay, only heartbeats until something changes
+	req, _ := http.NewRequest("GET", server.URL+"/v1/events?network=mainnet", nil)
+	req.Header.Set("Last-Event-ID", backlog[0].ID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	if event := readEvent(t, reader); event["comment"] != "connected" {
+		t.Errorf("Expected connected comment, got %v", event)
+	}
+	if event := readEvent(t, reader); event["comment"] != "heartbeat" {
+		t.Errorf("Expected heartbeat, got %v", event)
+	}
+
+	second := map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: {Light: &models.SnapshotInfo{Block: 200}},
+	}
+	broker.Publish(first, second, time.Now())
+
+	for {
+		event := readEvent(t, reader)
+		if event["comment"] == "heartbeat" {
+			continue
+		}
+		if event["id"] == "" || event["id"] == backlog[0].ID || !strings.Contains(event["data"], `"block":200`) {
+			t.Errorf("Unexpected change event: %v", event)
+		}
+		break
+	}
+
+	// Closing the broker ends the stream
+	broker.Close()
+	for {
+		if _, err := reader.ReadString('\n'); err != nil {
+			break
+		}
+	}
+}
+
+func TestHandler_StreamEvents_SkipsHiddenChanges(t *testing.T) {
+	handler, _ := createTestHandler([]string{"valid-key"})
+	broker := events.NewBroker()
+	handler.SetEvents(broker)
+
+	light := &models.SnapshotInfo{Block: 100, URL: "https://example.com/light-100"}
+	first := map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: {Full: &models.SnapshotInfo{Block: 100, URL: "https://example.com/full-100"}, Light: light},
+	}
+	broker.Publish(map[models.Network]*models.NetworkSnapshots{}, first, time.Now())
+
+	server := httptest.NewServer(handler.Routes())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/events?network=mainnet")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	if event := readEvent(t, reader); !strings.Contains(event["data"], "light-100") {
+		t.Fatalf("Expected light snapshot in data, got %v", event)
+	}
+
+	// A new full snapshot changes nothing an anonymous client can see
+	second := map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: {Full: &models.SnapshotInfo{Block: 200, URL: "https://example.com/full-200"}, Light: light},
+	}
+	broker.Publish(first, second, time.Now())
+	third := map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: {
+			Full:  second[models.NetworkMainnet].Full,
+			Light: &models.SnapshotInfo{Block: 200, URL: "https://example.com/light-200"},
+		},
+	}
+	broker.Publish(second, third, time.Now())
+
+	if event := readEvent(t, reader); !strings.Contains(event["data"], "light-200") {
+		t.Errorf("Expected the next event to be the light change, got %v", event)
+	}
+}
+
+func TestHandler_StreamEvents_RevokedKey(t *testing.T) {
+	handler, _ := createTestHandler([]string{"valid-key"})
+	broker := events.NewBroker()
+	handler.SetEvents(broker)
+
+	first := map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: {Full: &models.SnapshotInfo{Block: 100, URL: "https://example.com/full-100"}},
+	}
+	broker.Publish(map[models.Network]*models.NetworkSnapshots{}, first, time.Now())
+
+	server := httptest.NewServer(handler.Routes())
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/v1/events?network=mainnet", nil)
+	req.Header.Set("Authorization", "Bearer valid-key")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+
+	if event := readEvent(t, reader); !strings.Contains(event["data"], "full-100") {
+		t.Fatalf("Expected full snapshot in data, got %v", event)
+	}
+
+	// Once the key is removed the stream ends before the next snapshot is sent
+	handler.authMiddleware.Reload(&config.Config{})
+	second := map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: {Full: &models.SnapshotInfo{Block: 200, URL: "https://example.com/full-200"}},
+	}
+	broker.Publish(first, second, time.Now())
+
+	rest, _ := io.ReadAll(reader)
+	if strings.Contains(string(rest), "full-200") {
+		t.Errorf("Expected no snapshots after the key was revoked, got %s", rest)
+	}
+}
+
+func TestHandler_StreamEvents_InvalidNetwork(t *testing.T) {
+	handler, _ := createTestHandler([]string{})
+	handler.SetEvents(events.NewBroker())
+
+	tests := []struct {
+		query          string
+		expectedStatus int
+	}{
+		{query: "", expectedStatus: http.StatusBadRequest},
+		{query: "?network=unknown", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.Routes().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/events"+tt.query, nil))
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%q: expected status %d, got %d", tt.query, tt.expectedStatus, rr.Code)
+		}
+	}
+}
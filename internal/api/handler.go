@@ -2,18 +2,37 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/events"
+	"github.com/taraxa/snapshots-api/internal/freshness"
+	"github.com/taraxa/snapshots-api/internal/keystore"
+	"github.com/taraxa/snapshots-api/internal/metrics"
 	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/ratelimit"
 	"github.com/taraxa/snapshots-api/internal/service"
+	"github.com/taraxa/snapshots-api/internal/signedurl"
+	"github.com/taraxa/snapshots-api/internal/usage"
+	"github.com/taraxa/snapshots-api/internal/webhook"
 )
 
 // Handler holds the API handlers
 type Handler struct {
 	snapshotService service.SnapshotServiceInterface
 	authMiddleware  *auth.Middleware
+	metrics         *metrics.Metrics
+	freshness       *freshness.Checker
+	webhooks        *webhook.Dispatcher
+	events          *events.Broker
+	rateLimiter     *ratelimit.Limiter
+	usage           *usage.Tracker
+	keyStore        keystore.Store
+	urlSigner       *signedurl.Signer
+	downloads       *downloadProxy
+	mirrors         []models.Mirror
 }
 
 // NewHandler creates a new API handler
@@ -24,15 +43,109 @@ func NewHandler(snapshotService service.SnapshotServiceInterface, authMiddleware
 	}
 }
 
+// SetMetrics enables request instrumentation and the /metrics endpoint
+func (h *Handler) SetMetrics(m *metrics.Metrics) {
+	h.metrics = m
+}
+
+// SetFreshness enables the /v1/status freshness report
+func (h *Handler) SetFreshness(checker *freshness.Checker) {
+	h.freshness = checker
+}
+
+// SetWebhooks enables the webhook delivery history admin endpoint
+func (h *Handler) SetWebhooks(dispatcher *webhook.Dispatcher) {
+	h.webhooks = dispatcher
+}
+
+// SetEvents enables the /v1/events stream
+func (h *Handler) SetEvents(broker *events.Broker) {
+	h.events = broker
+}
+
+// SetRateLimiter enables per-IP and per-key request throttling
+func (h *Handler) SetRateLimiter(limiter *ratelimit.Limiter) {
+	h.rateLimiter = limiter
+}
+
+// SetUsage enables per-key usage accounting and the /admin/usage endpoint
+func (h *Handler) SetUsage(tracker *usage.Tracker) {
+	h.usage = tracker
+}
+
+// SetKeyStore enables the /admin/keys API for managing keys at runtime. The
+// auth middleware must use the same store.
+func (h *Handler) SetKeyStore(store keystore.Store) {
+	h.keyStore = store
+}
+
+// SetURLSigner returns signed download URLs for snapshot types that are not public
+func (h *Handler) SetURLSigner(signer *signedurl.Signer) {
+	h.urlSigner = signer
+}
+
+// SetDownloadProxy enables /v1/download, which streams snapshots through the
+// service. A nil bandwidth leaves downloads unthrottled.
+func (h *Handler) SetDownloadProxy(bandwidth *ratelimit.Bandwidth) {
+	h.downloads = &downloadProxy{client: newDownloadClient(), bandwidth: bandwidth}
+}
+
+// SetMirrors lets clients pick one of the mirrors listed with every snapshot as
+// its URL, by name with ?mirror= or by region with the X-Client-Region header
+func (h *Handler) SetMirrors(mirrors []models.Mirror) {
+	h.mirrors = mirrors
+}
+
 // Routes sets up the HTTP routes
 func (h *Handler) Routes() http.Handler {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/", h.getSnapshots)
+	// Legacy query-string endpoint; {$} restricts it to the root path so unknown paths 404
+	mux.HandleFunc("/{$}", h.getSnapshots)
 	mux.HandleFunc("/health", h.health)
 	mux.HandleFunc("/ready", h.ready)
 
-	return mux
+	// Versioned resource API
+	mux.HandleFunc("GET /v1/networks", h.listNetworks)
+	mux.HandleFunc("GET /v1/networks/{network}/snapshots", h.getNetworkSnapshots)
+	mux.HandleFunc("GET /v1/networks/{network}/snapshots/{type}", h.listSnapshots)
+	mux.HandleFunc("GET /v1/networks/{network}/snapshots/{type}/latest", h.getLatestSnapshot)
+	mux.HandleFunc("GET /v1/networks/{network}/snapshots/{type}/latest/download", h.downloadLatestSnapshot)
+	mux.HandleFunc("GET /v1/networks/{network}/snapshots/{type}/lookup", h.findSnapshot)
+	mux.HandleFunc("GET /v1/networks/{network}/snapshots/{type}/{block}", h.getSnapshotByBlock)
+
+	if h.freshness != nil {
+		mux.HandleFunc("GET /v1/status", h.getStatus)
+	}
+	if h.events != nil {
+		mux.HandleFunc("GET /v1/events", h.streamEvents)
+	}
+	if h.downloads != nil {
+		mux.HandleFunc("GET /v1/download/{filename}", h.proxyDownload)
+	}
+
+	// Admin API
+	if h.webhooks != nil {
+		mux.HandleFunc("GET /admin/webhooks/deliveries", h.authMiddleware.RequireAdmin(h.listWebhookDeliveries))
+	}
+	if h.usage != nil {
+		mux.HandleFunc("GET /admin/usage", h.authMiddleware.RequireAdmin(h.listUsage))
+	}
+	if h.keyStore != nil {
+		mux.HandleFunc("GET /admin/keys", h.authMiddleware.RequireAdmin(h.listKeys))
+		mux.HandleFunc("POST /admin/keys", h.authMiddleware.RequireAdmin(h.createKey))
+		mux.HandleFunc("GET /admin/keys/{id}", h.authMiddleware.RequireAdmin(h.getKey))
+		mux.HandleFunc("PATCH /admin/keys/{id}", h.authMiddleware.RequireAdmin(h.patchKey))
+		mux.HandleFunc("POST /admin/keys/{id}/revoke", h.authMiddleware.RequireAdmin(h.revokeKey))
+		mux.HandleFunc("POST /admin/keys/{id}/rotate", h.authMiddleware.RequireAdmin(h.rotateKey))
+	}
+
+	if h.metrics != nil {
+		mux.Handle("GET /metrics", h.metrics.Handler())
+	}
+
+	// Clients that keep failing to authenticate are rejected before their credentials are verified
+	return h.rateLimiter.AuthGuard(h.authMiddleware.Authenticate(h.metrics.Middleware(h.rateLimiter.Middleware(h.usage.Middleware(mux)))))
 }
 
 // getSnapshots handles GET requests for snapshot data
@@ -55,27 +168,51 @@ func (h *Handler) getSnapshots(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check authentication
-	authenticated := h.authMiddleware.IsAuthenticated(r)
+	choice, err := h.parseMirrorChoice(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Get snapshots with authentication filter
-	snapshots, err := h.snapshotService.GetSnapshotsWithAuth(models.Network(network), authenticated)
+	lookup, err := parseSnapshotLookup(r.URL.Query())
 	if err != nil {
-		log.Printf("Error fetching snapshots for network %s: %v", network, err)
-		http.Error(w, "failed to fetch snapshots", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Cache-Control", "public, max-age=300") // 5 minutes
+	principal := h.authMiddleware.Principal(r)
 
-	// Encode and send response
-	if err := json.NewEncoder(w).Encode(snapshots); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+	snapshots, err := h.snapshotService.GetSnapshots(models.Network(network))
+	if err != nil {
+		log.Printf("Error fetching snapshots for network %s (key %s): %v", network, principal, err)
+		http.Error(w, "failed to fetch snapshots", http.StatusInternalServerError)
 		return
 	}
+
+	// Only return the snapshot types the access policy grants the caller
+	snapshots = h.authMiddleware.Policy().Filter(principal, models.Network(network), snapshots)
+
+	if snapshots.Stale {
+		w.Header().Set("X-Snapshots-Stale", "true")
+	}
+
+	// With at_block or before, return the matching snapshot of each type instead of the latest ones
+	if lookup != nil {
+		snapshots, err = h.lookupSnapshots(models.Network(network), snapshots, lookup)
+		if errors.Is(err, service.ErrSnapshotNotFound) {
+			http.Error(w, "no snapshot matches the query", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error looking up snapshots for network %s (key %s): %v", network, principal, err)
+			http.Error(w, "failed to fetch snapshots", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	snapshots, signed := h.signSnapshots(models.Network(network), snapshots)
+	snapshots = h.mirrorSnapshots(choice, snapshots)
+	writeCacheable(w, r, snapshots, lastModified(snapshots.LastModified(), signed), principal != nil)
 }
 
 // health handles health check requests
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/taraxa/snapshots-api/internal/auth"
 	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/metrics"
 	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/ratelimit"
 )
 
 func createTestHandler(apiKeys []string) (*Handler, *MockSnapshotService) {
@@ -101,11 +104,11 @@ func TestHandler_GetSnapshots(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mock behavior
 			if tt.mockError != nil {
-				mockService.GetSnapshotsWithAuthFunc = func(network models.Network, authenticated bool) (*models.NetworkSnapshots, error) {
+				mockService.GetSnapshotsFunc = func(network models.Network) (*models.NetworkSnapshots, error) {
 					return nil, tt.mockError
 				}
 			} else {
-				mockService.GetSnapshotsWithAuthFunc = nil // Use default
+				mockService.GetSnapshotsFunc = nil // Use default
 			}
 
 			req, err := http.NewRequest("GET", "/"+tt.queryParams, nil)
@@ -182,6 +185,41 @@ func TestHandler_GetSnapshots(t *testing.T) {
 	}
 }
 
+func TestHandler_GetSnapshots_Stale(t *testing.T) {
+	handler, mockService := createTestHandler([]string{})
+
+	mockService.GetSnapshotsFunc = func(network models.Network) (*models.NetworkSnapshots, error) {
+		return &models.NetworkSnapshots{
+			Light: &models.SnapshotInfo{Block: 12345, Timestamp: "2025-07-06 14:30"},
+			Stale: true,
+		}, nil
+	}
+
+	req, err := http.NewRequest("GET", "/?network=mainnet", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.getSnapshots(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	if stale := rr.Header().Get("X-Snapshots-Stale"); stale != "true" {
+		t.Errorf("Expected X-Snapshots-Stale header to be 'true', got %q", stale)
+	}
+
+	var result models.NetworkSnapshots
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !result.Stale {
+		t.Error("Expected stale field in response body")
+	}
+}
+
 func TestHandler_GetSnapshots_InvalidMethods(t *testing.T) {
 	handler, _ := createTestHandler([]string{})
 
@@ -385,3 +423,81 @@ func TestHandler_Routes(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_Metrics(t *testing.T) {
+	handler, _ := createTestHandler([]string{})
+
+	// Without metrics the endpoint does not exist
+	rr := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without metrics, got %v", rr.Code)
+	}
+
+	handler.SetMetrics(metrics.New())
+	routes := handler.Routes()
+
+	routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/networks", nil))
+
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	expected := `snapshots_api_http_requests_total{method="GET",route="GET /v1/networks",status="200"} 1`
+	if !strings.Contains(rr.Body.String(), expected) {
+		t.Errorf("Expected %q in /metrics output", expected)
+	}
+}
+
+func TestHandler_RateLimit(t *testing.T) {
+	handler, _ := createTestHandler([]string{"valid-key"})
+	appMetrics := metrics.New()
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryBackend(), ratelimit.Limit{Rate: 1, Burst: 1}, map[string]ratelimit.Limit{
+		config.DefaultTier: {Rate: 1, Burst: 2},
+	})
+	limiter.SetMetrics(appMetrics)
+	handler.SetMetrics(appMetrics)
+	handler.SetRateLimiter(limiter)
+	routes := handler.Routes()
+
+	request := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/networks", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := request(""); rr.Code != http.StatusOK {
+		t.Fatalf("Expected first anonymous request to pass, got %v", rr.Code)
+	}
+	if rr := request(""); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After, got %v %v", rr.Code, rr.Header())
+	}
+
+	// API keys have their own, larger bucket
+	for i := 0; i < 2; i++ {
+		if rr := request("valid-key"); rr.Code != http.StatusOK {
+			t.Fatalf("Expected API key request %d to pass, got %v", i+1, rr.Code)
+		}
+	}
+	if rr := request("valid-key"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 for the API key, got %v", rr.Code)
+	}
+
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	for _, expected := range []string{
+		`snapshots_api_rate_limited_requests_total{client="anonymous"} 1`,
+		`snapshots_api_rate_limited_requests_total{client="key"} 1`,
+	} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("Expected %q in /metrics output", expected)
+		}
+	}
+}
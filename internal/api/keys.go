@@ -0,0 +1,342 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/keyhash"
+	"github.com/taraxa/snapshots-api/internal/keystore"
+)
+
+// Sources of the keys listed by the admin API. Every key lives in the key store;
+// keys imported from the configuration at startup are listed as config.
+const (
+	keySourceConfig = "config"
+	keySourceStore  = "store"
+)
+
+// keyIDPattern restricts key ids to characters that are safe in paths and logs
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// keyResponse describes an API key without its secret. Key is only set right
+// after the key was created or rotated.
+type keyResponse struct {
+	ID        string     `json:"id"`
+	Owner     string     `json:"owner,omitempty"`
+	Prefix    string     `json:"prefix,omitempty"`
+	Networks  []string   `json:"networks,omitempty"`
+	Types     []string   `json:"types,omitempty"`
+	Groups    []string   `json:"groups,omitempty"`
+	Tier      string     `json:"tier,omitempty"`
+	ExpiresAt *time.Time `json:"expires-at,omitempty"`
+	CreatedAt *time.Time `json:"created-at,omitempty"`
+	Disabled  bool       `json:"disabled"`
+	Source    string     `json:"source"`
+	Key       string     `json:"key,omitempty"`
+}
+
+// keysResponse lists API keys
+type keysResponse struct {
+	Keys []keyResponse `json:"keys"`
+}
+
+// createKeyRequest is the body of POST /admin/keys
+type createKeyRequest struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	Networks  []string  `json:"networks"`
+	Types     []string  `json:"types"`
+	Groups    []string  `json:"groups"`
+	Tier      string    `json:"tier"`
+	ExpiresAt time.Time `json:"expires-at"`
+}
+
+// updateKeyRequest is the body of PATCH /admin/keys/{id}; a null expires-at removes the expiry
+type updateKeyRequest struct {
+	ExpiresAt json.RawMessage `json:"expires-at"`
+}
+
+func newKeyResponse(key config.APIKey, source string) keyResponse {
+	response := keyResponse{
+		ID:       key.ID,
+		Owner:    key.Owner,
+		Prefix:   key.Prefix,
+		Networks: key.Networks,
+		Types:    key.Types,
+		Groups:   key.Groups,
+		Tier:     key.Tier,
+		Disabled: key.Disabled,
+		Source:   source,
+	}
+	if !key.ExpiresAt.IsZero() {
+		response.ExpiresAt = &key.ExpiresAt
+	}
+	if !key.CreatedAt.IsZero() {
+		response.CreatedAt = &key.CreatedAt
+	}
+	return response
+}
+
+// listKeys handles GET /admin/keys, listing the keys in the key store
+func (h *Handler) listKeys(w http.ResponseWriter, r *http.Request) {
+	stored, err := h.keyStore.List()
+	if err != nil {
+		log.Printf("Error listing API keys: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to list keys")
+		return
+	}
+
+	response := keysResponse{Keys: []keyResponse{}}
+	for _, key := range stored {
+		response.Keys = append(response.Keys, newKeyResponse(key, h.keySource(key.ID)))
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, response)
+}
+
+// getKey handles GET /admin/keys/{id}
+func (h *Handler) getKey(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.loadStoredKey(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, newKeyResponse(key, h.keySource(key.ID)))
+}
+
+// createKey handles POST /admin/keys. The response carries the only copy of the new key.
+func (h *Handler) createKey(w http.ResponseWriter, r *http.Request) {
+	var request createKeyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid JSON body")
+		return
+	}
+	if err := h.validateKeyRequest(request); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	// Two keys with one id would share usage records and rate limit buckets
+	for _, configured := range h.authMiddleware.ConfigKeys() {
+		if configured.ID == request.ID {
+			writeError(w, http.StatusConflict, "conflict", fmt.Sprintf("key %s already exists in the configuration", request.ID))
+			return
+		}
+	}
+
+	secret, prefix, hash, err := newSecret()
+	if err != nil {
+		log.Printf("Error generating API key: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to generate key")
+		return
+	}
+
+	key := config.APIKey{
+		ID:        request.ID,
+		Owner:     request.Owner,
+		Hash:      hash,
+		Prefix:    prefix,
+		Networks:  request.Networks,
+		Types:     request.Types,
+		Groups:    request.Groups,
+		Tier:      request.Tier,
+		ExpiresAt: request.ExpiresAt,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := h.keyStore.Create(key); err != nil {
+		if errors.Is(err, keystore.ErrExists) {
+			writeError(w, http.StatusConflict, "conflict", fmt.Sprintf("key %s already exists", key.ID))
+			return
+		}
+		log.Printf("Error creating API key %s: %v", key.ID, err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to create key")
+		return
+	}
+	if !h.refreshKeys(w) {
+		return
+	}
+
+	log.Printf("Created API key %s (%s)", key.ID, key.Owner)
+	response := newKeyResponse(key, keySourceStore)
+	response.Key = secret
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// revokeKey handles POST /admin/keys/{id}/revoke
+func (h *Handler) revokeKey(w http.ResponseWriter, r *http.Request) {
+	response, ok := h.updateKey(w, r, "Revoked", func(key *config.APIKey) error {
+		key.Disabled = true
+		return nil
+	})
+	if ok {
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// rotateKey handles POST /admin/keys/{id}/rotate, replacing the secret of a key.
+// The old secret stops working immediately.
+func (h *Handler) rotateKey(w http.ResponseWriter, r *http.Request) {
+	secret, prefix, hash, err := newSecret()
+	if err != nil {
+		log.Printf("Error generating API key: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to generate key")
+		return
+	}
+
+	response, ok := h.updateKey(w, r, "Rotated", func(key *config.APIKey) error {
+		key.Hash, key.Prefix = hash, prefix
+		return nil
+	})
+	if ok {
+		response.Key = secret
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// patchKey handles PATCH /admin/keys/{id}, which sets or clears the expiry of a key
+func (h *Handler) patchKey(w http.ResponseWriter, r *http.Request) {
+	var request updateKeyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid JSON body")
+		return
+	}
+	if len(request.ExpiresAt) == 0 {
+		writeError(w, http.StatusBadRequest, "bad_request", "expires-at is required; use null to remove the expiry")
+		return
+	}
+
+	var expiresAt time.Time
+	if string(request.ExpiresAt) != "null" {
+		if err := json.Unmarshal(request.ExpiresAt, &expiresAt); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", "expires-at must be an RFC 3339 timestamp or null")
+			return
+		}
+	}
+
+	response, ok := h.updateKey(w, r, "Updated expiry of", func(key *config.APIKey) error {
+		key.ExpiresAt = expiresAt
+		return nil
+	})
+	if ok {
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// updateKey applies update to the stored key named by the {id} path value and
+// returns the updated key. It writes an error response and returns false on failure.
+func (h *Handler) updateKey(w http.ResponseWriter, r *http.Request, action string, update func(key *config.APIKey) error) (keyResponse, bool) {
+	id := r.PathValue("id")
+	var updated config.APIKey
+	err := h.keyStore.Update(id, func(key *config.APIKey) error {
+		if err := update(key); err != nil {
+			return err
+		}
+		updated = *key
+		return nil
+	})
+	if errors.Is(err, keystore.ErrNotFound) {
+		h.writeKeyNotFound(w, id)
+		return keyResponse{}, false
+	}
+	if err != nil {
+		log.Printf("Error updating API key %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to update key")
+		return keyResponse{}, false
+	}
+	if !h.refreshKeys(w) {
+		return keyResponse{}, false
+	}
+
+	log.Printf("%s API key %s (%s)", action, updated.ID, updated.Owner)
+	w.Header().Set("Cache-Control", "no-store")
+	return newKeyResponse(updated, h.keySource(updated.ID)), true
+}
+
+// loadStoredKey returns a key from the key store, writing a 404 if it does not exist
+func (h *Handler) loadStoredKey(w http.ResponseWriter, id string) (config.APIKey, bool) {
+	key, err := h.keyStore.Get(id)
+	if errors.Is(err, keystore.ErrNotFound) {
+		h.writeKeyNotFound(w, id)
+		return config.APIKey{}, false
+	}
+	if err != nil {
+		log.Printf("Error loading API key %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to load key")
+		return config.APIKey{}, false
+	}
+	return key, true
+}
+
+// writeKeyNotFound explains that keys added to the configuration after startup
+// are not imported into the key store
+func (h *Handler) writeKeyNotFound(w http.ResponseWriter, id string) {
+	if h.keySource(id) == keySourceConfig {
+		writeError(w, http.StatusConflict, "conflict", fmt.Sprintf("key %s was added to the configuration after startup and is not in the key store", id))
+		return
+	}
+	writeError(w, http.StatusNotFound, "not_found", fmt.Sprintf("key %s does not exist", id))
+}
+
+// keySource tells whether a key id comes from the configuration
+func (h *Handler) keySource(id string) string {
+	for _, key := range h.authMiddleware.ConfigKeys() {
+		if key.ID == id {
+			return keySourceConfig
+		}
+	}
+	return keySourceStore
+}
+
+// refreshKeys makes key store changes visible to authentication
+func (h *Handler) refreshKeys(w http.ResponseWriter) bool {
+	if err := h.authMiddleware.RefreshKeys(); err != nil {
+		log.Printf("Error refreshing API keys: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "key saved but not yet active")
+		return false
+	}
+	return true
+}
+
+// validateKeyRequest checks a new key against the known networks, types and tiers
+func (h *Handler) validateKeyRequest(request createKeyRequest) error {
+	if !keyIDPattern.MatchString(request.ID) {
+		return errors.New("id must be 1-64 letters, digits, '.', '_' or '-'")
+	}
+	for _, network := range request.Networks {
+		if !h.snapshotService.IsValidNetwork(network) {
+			return fmt.Errorf("unknown network %s", network)
+		}
+	}
+	for _, snapshotType := range request.Types {
+		if _, ok := parseSnapshotType(snapshotType); !ok {
+			return fmt.Errorf("unknown snapshot type %s", snapshotType)
+		}
+	}
+	if request.Tier != "" && !h.rateLimiter.HasTier(request.Tier) {
+		return fmt.Errorf("unknown rate limit tier %s", request.Tier)
+	}
+	if !request.ExpiresAt.IsZero() && !request.ExpiresAt.After(time.Now()) {
+		return errors.New("expires-at must be in the future")
+	}
+	return nil
+}
+
+// newSecret generates a key and its SHA-256 hash. Generated keys carry 160 random
+// bits, so a fast hash is as safe as a slow one and keeps lookups cheap.
+func newSecret() (secret, prefix, hash string, err error) {
+	if secret, prefix, err = keyhash.Generate(); err != nil {
+		return "", "", "", err
+	}
+	if hash, err = keyhash.Hash(keyhash.SHA256, secret); err != nil {
+		return "", "", "", err
+	}
+	return secret, prefix, hash, nil
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/keystore"
+)
+
+func TestHandler_Keys(t *testing.T) {
+	store, err := keystore.OpenBoltStore(filepath.Join(t.TempDir(), "keys.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	cfg := &config.Config{APIKeys: []string{"valid-key"}, AdminAPIKeys: []string{"admin-key"}}
+	authMiddleware := auth.NewMiddleware(cfg)
+	if err := authMiddleware.SetKeyStore(store); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(&MockSnapshotService{}, authMiddleware)
+	handler.SetKeyStore(store)
+	routes := handler.Routes()
+
+	request := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder, value interface{}) {
+		t.Helper()
+		if err := json.Unmarshal(rr.Body.Bytes(), value); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+	}
+	canSeeFull := func(key string) bool {
+		return request("GET", "/v1/networks/mainnet/snapshots/full/latest", key, "").Code == http.StatusOK
+	}
+
+	if rr := request("GET", "/admin/keys", "valid-key", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a regular API key, got %v", rr.Code)
+	}
+
+	// Create
+	rr := request("POST", "/admin/keys", "admin-key", `{"id": "partner", "owner": "ops@partner.io", "networks": ["mainnet"], "expires-at": "2099-01-01T00:00:00Z"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %v: %s", rr.Code, rr.Body.String())
+	}
+	var created keyResponse
+	decode(rr, &created)
+	if !strings.HasPrefix(created.Key, created.Prefix+"_") || created.Source != keySourceStore || created.ExpiresAt == nil || created.CreatedAt == nil {
+		t.Errorf("Unexpected created key: %+v", created)
+	}
+	if !canSeeFull(created.Key) {
+		t.Error("Expected the new key to work immediately")
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "duplicate id", body: `{"id": "partner"}`, expectedStatus: http.StatusConflict},
+		{name: "configured id", body: `{"id": "env-1"}`, expectedStatus: http.StatusConflict},
+		{name: "invalid id", body: `{"id": "a/b"}`, expectedStatus: http.StatusBadRequest},
+		{name: "unknown network", body: `{"id": "a", "networks": ["moonnet"]}`, expectedStatus: http.StatusBadRequest},
+		{name: "unknown type", body: `{"id": "a", "types": ["archive"]}`, expectedStatus: http.StatusBadRequest},
+		{name: "past expiry", body: `{"id": "a", "expires-at": "2000-01-01T00:00:00Z"}`, expectedStatus: http.StatusBadRequest},
+		{name: "malformed body", body: `{`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := request("POST", "/admin/keys", "admin-key", tt.body); rr.Code != tt.expectedStatus {
+				t.Errorf("Expected %v, got %v: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	// List never exposes secrets
+	rr = request("GET", "/admin/keys", "admin-key", "")
+	var listed keysResponse
+	decode(rr, &listed)
+	if len(listed.Keys) != 2 || listed.Keys[0].Source != keySourceConfig || listed.Keys[1].ID != "partner" {
+		t.Errorf("Unexpected key list: %+v", listed.Keys)
+	}
+	if strings.Contains(rr.Body.String(), created.Key) || strings.Contains(rr.Body.String(), "valid-key") || strings.Contains(rr.Body.String(), "sha256:") {
+		t.Error("Expected the key list not to contain secrets")
+	}
+
+	// Rotate
+	rr = request("POST", "/admin/keys/partner/rotate", "admin-key", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %v", rr.Code)
+	}
+	var rotated keyResponse
+	decode(rr, &rotated)
+	if rotated.Key == "" || rotated.Key == created.Key {
+		t.Fatalf("Expected a new key, got %+v", rotated)
+	}
+	if canSeeFull(created.Key) || !canSeeFull(rotated.Key) {
+		t.Error("Expected only the rotated key to work")
+	}
+
+	// Set and clear the expiry
+	rr = request("PATCH", "/admin/keys/partner", "admin-key", `{"expires-at": "2098-06-01T00:00:00Z"}`)
+	var patched keyResponse
+	decode(rr, &patched)
+	if rr.Code != http.StatusOK || patched.ExpiresAt == nil || patched.ExpiresAt.Year() != 2098 {
+		t.Errorf("Expected updated expiry, got %v %+v", rr.Code, patched)
+	}
+	rr = request("PATCH", "/admin/keys/partner", "admin-key", `{"expires-at": null}`)
+	patched = keyResponse{}
+	decode(rr, &patched)
+	if rr.Code != http.StatusOK || patched.ExpiresAt != nil {
+		t.Errorf("Expected expiry to be removed, got %v %+v", rr.Code, patched)
+	}
+	if rr := request("PATCH", "/admin/keys/partner", "admin-key", `{}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without expires-at, got %v", rr.Code)
+	}
+
+	// Revoke
+	if rr := request("POST", "/admin/keys/partner/revoke", "admin-key", ""); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %v", rr.Code)
+	}
+	if canSeeFull(rotated.Key) {
+		t.Error("Expected the revoked key to be rejected")
+	}
+	rr = request("GET", "/admin/keys/partner", "admin-key", "")
+	var revoked keyResponse
+	decode(rr, &revoked)
+	if !revoked.Disabled {
+		t.Errorf("Expected the key to be disabled, got %+v", revoked)
+	}
+
+	// Unknown keys, and configured keys which were imported into the store
+	if rr := request("POST", "/admin/keys/unknown/revoke", "admin-key", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %v", rr.Code)
+	}
+	if !canSeeFull("valid-key") {
+		t.Error("Expected the imported key to work")
+	}
+	if rr := request("POST", "/admin/keys/env-1/revoke", "admin-key", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 for an imported key, got %v", rr.Code)
+	}
+	if canSeeFull("valid-key") {
+		t.Error("Expected the revoked imported key to be rejected")
+	}
+}
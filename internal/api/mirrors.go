@@ -0,0 +1,141 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// mirrorRegionHeader lets clients hint the region they download from, so no GeoIP
+// lookup is needed to send them to a nearby mirror
+const mirrorRegionHeader = "X-Client-Region"
+
+// mirrorChoice is the mirror a client prefers, either a mirror by name or any mirror
+// of a region. The zero value keeps the bucket URL.
+type mirrorChoice struct {
+	name   string
+	region string
+}
+
+// parseMirrorChoice reads the mirror query parameter and the region header. An
+// unknown mirror name is an error; an unknown region falls back to the bucket URL.
+// The region header is added to Vary since it changes the response.
+func (h *Handler) parseMirrorChoice(w http.ResponseWriter, r *http.Request) (mirrorChoice, error) {
+	if len(h.mirrors) > 0 {
+		w.Header().Add("Vary", mirrorRegionHeader)
+	}
+
+	if name := r.URL.Query().Get("mirror"); name != "" {
+		names := make([]string, 0, len(h.mirrors))
+		for _, mirror := range h.mirrors {
+			if mirror.Name == name {
+				return mirrorChoice{name: name}, nil
+			}
+			names = append(names, mirror.Name)
+		}
+		if len(names) == 0 {
+			return mirrorChoice{}, fmt.Errorf("unknown mirror %q: no mirrors are configured", name)
+		}
+		return mirrorChoice{}, fmt.Errorf("unknown mirror %q. Available mirrors: %s", name, strings.Join(names, ", "))
+	}
+
+	if len(h.mirrors) == 0 {
+		return mirrorChoice{}, nil
+	}
+	return mirrorChoice{region: strings.ToLower(strings.TrimSpace(r.Header.Get(mirrorRegionHeader)))}, nil
+}
+
+// preferredMirror parses the mirror preference of a v1 request. It writes an error
+// response and returns false for an unknown mirror.
+func (h *Handler) preferredMirror(w http.ResponseWriter, r *http.Request) (mirrorChoice, bool) {
+	choice, err := h.parseMirrorChoice(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return mirrorChoice{}, false
+	}
+	return choice, true
+}
+
+// mirrorInfo returns a copy of info whose URL points at the chosen mirror. info is
+// returned as it is when no mirror of the choice hosts it.
+func (h *Handler) mirrorInfo(choice mirrorChoice, info *models.SnapshotInfo) *models.SnapshotInfo {
+	if info == nil || len(info.Mirrors) == 0 {
+		return info
+	}
+
+	name := choice.name
+	if name == "" {
+		name = h.regionMirror(choice.region, info)
+	}
+	if name == "" {
+		return info
+	}
+	for _, mirror := range info.Mirrors {
+		if mirror.Name == name {
+			chosen := *info
+			chosen.URL = mirror.URL
+			return &chosen
+		}
+	}
+	return info
+}
+
+// mirrorInfos points a list of snapshots at the chosen mirror into a new slice
+func (h *Handler) mirrorInfos(choice mirrorChoice, infos []models.SnapshotInfo) []models.SnapshotInfo {
+	if len(infos) == 0 || choice == (mirrorChoice{}) {
+		return infos
+	}
+
+	chosen := make([]models.SnapshotInfo, len(infos))
+	for i := range infos {
+		chosen[i] = *h.mirrorInfo(choice, &infos[i])
+	}
+	return chosen
+}
+
+// mirrorSnapshots returns a copy of snapshots pointed at the chosen mirror
+func (h *Handler) mirrorSnapshots(choice mirrorChoice, snapshots *models.NetworkSnapshots) *models.NetworkSnapshots {
+	if choice == (mirrorChoice{}) {
+		return snapshots
+	}
+
+	chosen := *snapshots
+	chosen.Full = h.mirrorInfo(choice, snapshots.Full)
+	chosen.PreviousFull = h.mirrorInfos(choice, snapshots.PreviousFull)
+	chosen.Light = h.mirrorInfo(choice, snapshots.Light)
+	chosen.PreviousLight = h.mirrorInfos(choice, snapshots.PreviousLight)
+	return &chosen
+}
+
+// regionMirror picks the mirror of region that serves a snapshot by weighted
+// rendezvous hashing of its filename. Every request and replica picks the same
+// mirror, so responses stay cacheable, while the mirrors of a region share the
+// snapshots in proportion to their weights.
+func (h *Handler) regionMirror(region string, info *models.SnapshotInfo) string {
+	if region == "" {
+		return ""
+	}
+
+	filename := path.Base(info.Mirrors[0].URL)
+	var best string
+	var bestScore float64
+	for _, mirror := range h.mirrors {
+		if mirror.Region != region || mirror.Weight <= 0 {
+			continue
+		}
+		sum := sha256.Sum256([]byte(mirror.Name + "/" + filename))
+		// Map the hash to a uniform value in (0, 1)
+		uniform := (float64(binary.BigEndian.Uint64(sum[:8])>>11) + 0.5) / (1 << 53)
+		score := float64(mirror.Weight) / -math.Log(uniform)
+		if best == "" || score > bestScore {
+			best, bestScore = mirror.Name, score
+		}
+	}
+	return best
+}
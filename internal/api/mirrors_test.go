@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/signedurl"
+)
+
+var testMirrors = []models.Mirror{
+	{Name: "sg", Region: "asia", BaseURL: "https://sg.example.com", Weight: 1},
+	{Name: "tokyo", Region: "asia", BaseURL: "https://tokyo.example.com", Weight: 1},
+	{Name: "fra", Region: "europe", BaseURL: "https://fra.example.com", Weight: 1},
+}
+
+// withMirrors returns a copy of info listing its URL on testMirrors, like the parser does
+func withMirrors(info models.SnapshotInfo) models.SnapshotInfo {
+	info.Mirrors = nil
+	for _, mirror := range testMirrors {
+		info.Mirrors = append(info.Mirrors, models.MirrorURL{Name: mirror.Name, Region: mirror.Region, URL: mirror.BaseURL + "/" + path.Base(info.URL)})
+	}
+	return info
+}
+
+// publicSnapshots makes every snapshot type public
+var publicSnapshots = &config.Config{AccessPolicy: map[string]config.AccessRule{
+	"*/full":  {Access: config.AccessPublic},
+	"*/light": {Access: config.AccessPublic},
+}}
+
+// createMirrorHandler returns a handler whose snapshots are hosted on testMirrors
+func createMirrorHandler(t *testing.T, cfg *config.Config) *Handler {
+	t.Helper()
+	mockService := &MockSnapshotService{}
+	defaults := &MockSnapshotService{}
+	mockService.GetSnapshotsFunc = func(network models.Network) (*models.NetworkSnapshots, error) {
+		snapshots, _ := defaults.GetSnapshots(network)
+		full, light := withMirrors(*snapshots.Full), withMirrors(*snapshots.Light)
+		snapshots.Full, snapshots.Light = &full, &light
+		for i := range snapshots.PreviousFull {
+			snapshots.PreviousFull[i] = withMirrors(snapshots.PreviousFull[i])
+		}
+		for i := range snapshots.PreviousLight {
+			snapshots.PreviousLight[i] = withMirrors(snapshots.PreviousLight[i])
+		}
+		return snapshots, nil
+	}
+	mockService.FindByBlockFunc = func(network models.Network, snapshotType models.SnapshotType, block int64, above bool) (*models.SnapshotInfo, error) {
+		info, err := defaults.FindByBlock(network, snapshotType, block, above)
+		if err != nil {
+			return nil, err
+		}
+		mirrored := withMirrors(*info)
+		return &mirrored, nil
+	}
+
+	handler := NewHandler(mockService, auth.NewMiddleware(cfg))
+	handler.SetMirrors(testMirrors)
+	return handler
+}
+
+func TestHandler_Mirrors(t *testing.T) {
+	routes := createMirrorHandler(t, publicSnapshots).Routes()
+	filename := "mainnet-full-db-block-12345-20250706-143000.tar.gz"
+
+	tests := []struct {
+		name           string
+		path           string
+		region         string
+		expectedStatus int
+		expectedHosts  []string
+	}{
+		{name: "no preference keeps the bucket", path: "/v1/networks/mainnet/snapshots/full/latest", expectedStatus: http.StatusOK, expectedHosts: []string{"storage.googleapis.com"}},
+		{name: "mirror by name", path: "/v1/networks/mainnet/snapshots/full/latest?mirror=fra", expectedStatus: http.StatusOK, expectedHosts: []string{"fra.example.com"}},
+		{name: "mirror by region", path: "/v1/networks/mainnet/snapshots/full/latest", region: "Asia", expectedStatus: http.StatusOK, expectedHosts: []string{"sg.example.com", "tokyo.example.com"}},
+		{name: "name wins over region", path: "/v1/networks/mainnet/snapshots/full/latest?mirror=fra", region: "asia", expectedStatus: http.StatusOK, expectedHosts: []string{"fra.example.com"}},
+		{name: "unknown region keeps the bucket", path: "/v1/networks/mainnet/snapshots/full/latest", region: "mars", expectedStatus: http.StatusOK, expectedHosts: []string{"storage.googleapis.com"}},
+		{name: "by block", path: "/v1/networks/mainnet/snapshots/full/12345?mirror=sg", expectedStatus: http.StatusOK, expectedHosts: []string{"sg.example.com"}},
+		{name: "unknown mirror", path: "/v1/networks/mainnet/snapshots/full/latest?mirror=nowhere", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.region != "" {
+				req.Header.Set("X-Client-Region", tt.region)
+			}
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var info models.SnapshotInfo
+			if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+				t.Fatal(err)
+			}
+			var matched bool
+			for _, host := range tt.expectedHosts {
+				matched = matched || info.URL == "https://"+host+"/"+filename || strings.HasPrefix(info.URL, "https://"+host+"/taraxa-snapshot/")
+			}
+			if !matched {
+				t.Errorf("Expected a URL on %v, got %s", tt.expectedHosts, info.URL)
+			}
+			if len(info.Mirrors) != len(testMirrors) {
+				t.Errorf("Expected %d mirrors, got %+v", len(testMirrors), info.Mirrors)
+			}
+			if !strings.Contains(strings.Join(rr.Header().Values("Vary"), ","), "X-Client-Region") {
+				t.Errorf("Expected Vary to include X-Client-Region, got %v", rr.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestHandler_MirrorsListingsAndRedirect(t *testing.T) {
+	routes := createMirrorHandler(t, publicSnapshots).Routes()
+
+	// The legacy endpoint points every snapshot at the chosen mirror
+	req := httptest.NewRequest("GET", "/?network=mainnet&mirror=tokyo", nil)
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	var snapshots models.NetworkSnapshots
+	if err := json.Unmarshal(rr.Body.Bytes(), &snapshots); err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range append([]models.SnapshotInfo{*snapshots.Full, *snapshots.Light}, snapshots.PreviousFull...) {
+		if !strings.HasPrefix(info.URL, "https://tokyo.example.com/") {
+			t.Errorf("Expected URL on tokyo mirror, got %s", info.URL)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/?network=mainnet&mirror=nowhere", nil)
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown mirror, got %d", rr.Code)
+	}
+
+	// Region choices are stable across requests
+	var first string
+	for i := 0; i < 3; i++ {
+		req = httptest.NewRequest("GET", "/v1/networks/mainnet/snapshots/light/latest/download", nil)
+		req.Header.Set("X-Client-Region", "asia")
+		rr = httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusFound {
+			t.Fatalf("Expected redirect, got %d", rr.Code)
+		}
+		location := rr.Header().Get("Location")
+		if !strings.HasPrefix(location, "https://sg.example.com/") && !strings.HasPrefix(location, "https://tokyo.example.com/") {
+			t.Errorf("Expected redirect to an asia mirror, got %s", location)
+		}
+		if first == "" {
+			first = location
+		} else if location != first {
+			t.Errorf("Expected the same mirror on every request, got %s and %s", first, location)
+		}
+	}
+}
+
+func TestHandler_MirrorsNotConfigured(t *testing.T) {
+	handler, _ := createTestHandler([]string{"valid-key"})
+	routes := handler.Routes()
+
+	req := httptest.NewRequest("GET", "/v1/networks/mainnet/snapshots/full/latest?mirror=sg", nil)
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without mirrors, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/networks/mainnet/snapshots/full/latest", nil)
+	req.Header.Set("Authorization", "Bearer valid-key")
+	req.Header.Set("X-Client-Region", "asia")
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+	if vary := rr.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Authorization" {
+		t.Errorf("Expected Vary: Authorization only, got %v", vary)
+	}
+}
+
+func TestHandler_MirrorsOfSignedTypes(t *testing.T) {
+	handler := createMirrorHandler(t, &config.Config{APIKeys: []string{"valid-key"}})
+	handler.SetURLSigner(signedurl.NewHMACSigner("GOOG1EXAMPLEACCESSID", "secret", time.Hour))
+	routes := handler.Routes()
+
+	// Gated snapshots keep their signed bucket URL and hide the mirrors that would bypass it
+	req := httptest.NewRequest("GET", "/v1/networks/mainnet/snapshots/full/latest?mirror=fra", nil)
+	req.Header.Set("Authorization", "Bearer valid-key")
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	var info models.SnapshotInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(info.URL, "X-Goog-Signature=") || info.Mirrors != nil {
+		t.Errorf("Expected a signed bucket URL without mirrors, got %+v", info)
+	}
+}
+
+func TestRegionMirror_Weights(t *testing.T) {
+	handler := &Handler{mirrors: []models.Mirror{
+		{Name: "sg", Region: "asia", Weight: 3},
+		{Name: "tokyo", Region: "asia", Weight: 1},
+		{Name: "backup", Region: "asia", Weight: 0},
+	}}
+
+	counts := make(map[string]int)
+	for block := 0; block < 4000; block++ {
+		info := &models.SnapshotInfo{Mirrors: []models.MirrorURL{{URL: fmt.Sprintf("https://sg.example.com/mainnet-full-db-block-%d-20250706-143000.tar.gz", block)}}}
+		counts[handler.regionMirror("asia", info)]++
+	}
+
+	if counts["backup"] != 0 {
+		t.Errorf("Expected no snapshots on a mirror with weight 0, got %d", counts["backup"])
+	}
+	if share := float64(counts["sg"]) / 4000; share < 0.7 || share > 0.8 {
+		t.Errorf("Expected sg to serve about 75%% of snapshots, got %.2f", share)
+	}
+}
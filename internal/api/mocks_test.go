@@ -1,15 +1,23 @@
 package api
 
 import (
+	"fmt"
+	"path"
+	"time"
+
 	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/service"
 )
 
 // MockSnapshotService is a mock implementation for testing
 type MockSnapshotService struct {
-	GetSnapshotsFunc         func(network models.Network) (*models.NetworkSnapshots, error)
-	GetSnapshotsWithAuthFunc func(network models.Network, authenticated bool) (*models.NetworkSnapshots, error)
-	IsValidNetworkFunc       func(network string) bool
-	GetAllNetworksFunc       func() []models.Network
+	GetSnapshotsFunc   func(network models.Network) (*models.NetworkSnapshots, error)
+	FindByBlockFunc    func(network models.Network, snapshotType models.SnapshotType, block int64, above bool) (*models.SnapshotInfo, error)
+	FindByFilenameFunc func(network models.Network, snapshotType models.SnapshotType, filename string) (*models.SnapshotInfo, error)
+	FindBeforeFunc     func(network models.Network, snapshotType models.SnapshotType, before time.Time) (*models.SnapshotInfo, error)
+	ListSnapshotsFunc  func(network models.Network, snapshotType models.SnapshotType, cursor int64, limit int) (*models.SnapshotPage, error)
+	IsValidNetworkFunc func(network string) bool
+	GetAllNetworksFunc func() []models.Network
 }
 
 func (m *MockSnapshotService) GetSnapshots(network models.Network) (*models.NetworkSnapshots, error) {
@@ -55,52 +63,87 @@ func (m *MockSnapshotService) GetSnapshots(network models.Network) (*models.Netw
 	}, nil
 }
 
-func (m *MockSnapshotService) GetSnapshotsWithAuth(network models.Network, authenticated bool) (*models.NetworkSnapshots, error) {
-	if m.GetSnapshotsWithAuthFunc != nil {
-		return m.GetSnapshotsWithAuthFunc(network, authenticated)
+func (m *MockSnapshotService) FindByBlock(network models.Network, snapshotType models.SnapshotType, block int64, above bool) (*models.SnapshotInfo, error) {
+	if m.FindByBlockFunc != nil {
+		return m.FindByBlockFunc(network, snapshotType, block, above)
 	}
-	// Default implementation - returns full snapshots only if authenticated
-	result := &models.NetworkSnapshots{
-		Light: &models.SnapshotInfo{
-			Block:     12345,
-			Timestamp: "2025-07-06 14:30",
-			URL:       "https://storage.googleapis.com/taraxa-snapshot/mainnet-light-db-block-12345-20250706-143000.tar.gz",
-		},
-		PreviousLight: []models.SnapshotInfo{
-			{
-				Block:     12344,
-				Timestamp: "2025-07-05 14:30",
-				URL:       "https://storage.googleapis.com/taraxa-snapshot/mainnet-light-db-block-12344-20250705-143000.tar.gz",
-			},
-			{
-				Block:     12343,
-				Timestamp: "2025-07-04 14:30",
-				URL:       "https://storage.googleapis.com/taraxa-snapshot/mainnet-light-db-block-12343-20250704-143000.tar.gz",
-			},
-		},
+	// Default implementation - searches blocks 12345, 12344 and 12343
+	for _, candidate := range mockBlocks(above) {
+		if (above && candidate >= block) || (!above && candidate <= block) {
+			return mockSnapshotInfo(network, snapshotType, candidate), nil
+		}
 	}
+	return nil, service.ErrSnapshotNotFound
+}
 
-	if authenticated {
-		result.Full = &models.SnapshotInfo{
-			Block:     12345,
-			Timestamp: "2025-07-06 14:30",
-			URL:       "https://storage.googleapis.com/taraxa-snapshot/mainnet-full-db-block-12345-20250706-143000.tar.gz",
+func (m *MockSnapshotService) FindByFilename(network models.Network, snapshotType models.SnapshotType, filename string) (*models.SnapshotInfo, error) {
+	if m.FindByFilenameFunc != nil {
+		return m.FindByFilenameFunc(network, snapshotType, filename)
+	}
+	// Default implementation - knows the files of blocks 12345, 12344 and 12343
+	for _, candidate := range mockBlocks(false) {
+		info := mockSnapshotInfo(network, snapshotType, candidate)
+		if path.Base(info.URL) == filename {
+			return info, nil
 		}
-		result.PreviousFull = []models.SnapshotInfo{
-			{
-				Block:     12344,
-				Timestamp: "2025-07-05 14:30",
-				URL:       "https://storage.googleapis.com/taraxa-snapshot/mainnet-full-db-block-12344-20250705-143000.tar.gz",
-			},
-			{
-				Block:     12343,
-				Timestamp: "2025-07-04 14:30",
-				URL:       "https://storage.googleapis.com/taraxa-snapshot/mainnet-full-db-block-12343-20250704-143000.tar.gz",
-			},
+	}
+	return nil, service.ErrSnapshotNotFound
+}
+
+func (m *MockSnapshotService) FindBefore(network models.Network, snapshotType models.SnapshotType, before time.Time) (*models.SnapshotInfo, error) {
+	if m.FindBeforeFunc != nil {
+		return m.FindBeforeFunc(network, snapshotType, before)
+	}
+	// Default implementation - snapshot 12345 was taken at 2025-07-06 14:30, one block per day before that
+	for _, candidate := range mockBlocks(false) {
+		taken := time.Date(2025, 7, 6, 14, 30, 0, 0, time.UTC).AddDate(0, 0, int(candidate-12345))
+		if !taken.After(before) {
+			return mockSnapshotInfo(network, snapshotType, candidate), nil
 		}
 	}
+	return nil, service.ErrSnapshotNotFound
+}
+
+func (m *MockSnapshotService) ListSnapshots(network models.Network, snapshotType models.SnapshotType, cursor int64, limit int) (*models.SnapshotPage, error) {
+	if m.ListSnapshotsFunc != nil {
+		return m.ListSnapshotsFunc(network, snapshotType, cursor, limit)
+	}
+	// Default implementation - pages through blocks 12345, 12344 and 12343
+	if limit <= 0 {
+		limit = service.DefaultPageLimit
+	}
+	page := &models.SnapshotPage{Snapshots: []models.SnapshotInfo{}}
+	for _, block := range mockBlocks(false) {
+		if cursor > 0 && block >= cursor {
+			continue
+		}
+		if len(page.Snapshots) == limit {
+			next := page.Snapshots[len(page.Snapshots)-1].Block
+			page.NextCursor = &next
+			break
+		}
+		page.Snapshots = append(page.Snapshots, *mockSnapshotInfo(network, snapshotType, block))
+	}
+	return page, nil
+}
+
+// mockBlocks returns the blocks known to the mock, newest first unless ascending is set
+func mockBlocks(ascending bool) []int64 {
+	if ascending {
+		return []int64{12343, 12344, 12345}
+	}
+	return []int64{12345, 12344, 12343}
+}
 
-	return result, nil
+// mockSnapshotInfo builds the SnapshotInfo the mock returns for a block
+func mockSnapshotInfo(network models.Network, snapshotType models.SnapshotType, block int64) *models.SnapshotInfo {
+	taken := time.Date(2025, 7, 6, 14, 30, 0, 0, time.UTC).AddDate(0, 0, int(block-12345))
+	return &models.SnapshotInfo{
+		Block:     block,
+		Timestamp: taken.Format("2006-01-02 15:04"),
+		URL: fmt.Sprintf("https://storage.googleapis.com/taraxa-snapshot/%s-%s-db-block-%d-%s.tar.gz",
+			network, snapshotType, block, taken.Format("20060102-150405")),
+	}
 }
 
 func (m *MockSnapshotService) IsValidNetwork(network string) bool {
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"log"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// gated reports whether download URLs of a snapshot type need a signature because
+// the access policy does not make them public
+func (h *Handler) gated(network models.Network, snapshotType models.SnapshotType) bool {
+	return h.urlSigner != nil && h.authMiddleware.Policy().Rule(network, snapshotType).Access != config.AccessPublic
+}
+
+// signInfo returns a copy of info with a signed URL when its type is gated. The
+// cached snapshot is never modified. Mirrors are left out of signed copies since
+// their URLs would bypass the signature.
+func (h *Handler) signInfo(network models.Network, snapshotType models.SnapshotType, info *models.SnapshotInfo) (*models.SnapshotInfo, bool) {
+	if info == nil || info.URL == "" || !h.gated(network, snapshotType) {
+		return info, false
+	}
+
+	signedURL, err := h.urlSigner.Sign(info.URL)
+	if err != nil {
+		log.Printf("Error signing URL of %s %s snapshot %d: %v", network, snapshotType, info.Block, err)
+		return info, false
+	}
+	signed := *info
+	signed.URL = signedURL
+	signed.Mirrors = nil
+	return &signed, true
+}
+
+// signInfos signs the URLs of a list of snapshots of one type into a new slice
+func (h *Handler) signInfos(network models.Network, snapshotType models.SnapshotType, infos []models.SnapshotInfo) ([]models.SnapshotInfo, bool) {
+	if len(infos) == 0 || !h.gated(network, snapshotType) {
+		return infos, false
+	}
+
+	signed := make([]models.SnapshotInfo, len(infos))
+	var changed bool
+	for i := range infos {
+		info, ok := h.signInfo(network, snapshotType, &infos[i])
+		signed[i] = *info
+		changed = changed || ok
+	}
+	return signed, changed
+}
+
+// signSnapshots returns a copy of snapshots with signed URLs for the gated types and
+// reports whether any URL was signed
+func (h *Handler) signSnapshots(network models.Network, snapshots *models.NetworkSnapshots) (*models.NetworkSnapshots, bool) {
+	if h.urlSigner == nil {
+		return snapshots, false
+	}
+
+	signed := *snapshots
+	var full, previousFull, light, previousLight bool
+	signed.Full, full = h.signInfo(network, models.SnapshotTypeFull, snapshots.Full)
+	signed.PreviousFull, previousFull = h.signInfos(network, models.SnapshotTypeFull, snapshots.PreviousFull)
+	signed.Light, light = h.signInfo(network, models.SnapshotTypeLight, snapshots.Light)
+	signed.PreviousLight, previousLight = h.signInfos(network, models.SnapshotTypeLight, snapshots.PreviousLight)
+	return &signed, full || previousFull || light || previousLight
+}
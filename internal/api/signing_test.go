@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/signedurl"
+)
+
+func TestHandler_SignedURLs(t *testing.T) {
+	mockService := &MockSnapshotService{}
+	shared, _ := mockService.GetSnapshots(models.NetworkMainnet)
+	mockService.GetSnapshotsFunc = func(network models.Network) (*models.NetworkSnapshots, error) {
+		return shared, nil
+	}
+
+	cfg := &config.Config{
+		APIKeys:      []string{"valid-key"},
+		AccessPolicy: map[string]config.AccessRule{"devnet/full": {Access: config.AccessPublic}},
+	}
+	handler := NewHandler(mockService, auth.NewMiddleware(cfg))
+	handler.SetURLSigner(signedurl.NewHMACSigner("GOOG1EXAMPLEACCESSID", "secret", time.Hour))
+	routes := handler.Routes()
+
+	tests := []struct {
+		name         string
+		path         string
+		expectSigned bool
+	}{
+		{name: "full latest", path: "/v1/networks/mainnet/snapshots/full/latest", expectSigned: true},
+		{name: "full history", path: "/v1/networks/mainnet/snapshots/full", expectSigned: true},
+		{name: "full by block", path: "/v1/networks/mainnet/snapshots/full/12344", expectSigned: true},
+		{name: "full lookup", path: "/v1/networks/mainnet/snapshots/full/lookup?at_block=12344", expectSigned: true},
+		{name: "light latest", path: "/v1/networks/mainnet/snapshots/light/latest", expectSigned: false},
+		{name: "public full", path: "/v1/networks/devnet/snapshots/full/latest", expectSigned: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Authorization", "Bearer valid-key")
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+			}
+			if signed := strings.Contains(rr.Body.String(), "X-Goog-Signature="); signed != tt.expectSigned {
+				t.Errorf("Expected signed URLs: %v, body %s", tt.expectSigned, rr.Body.String())
+			}
+		})
+	}
+
+	// Listings sign only the gated type and drop Last-Modified, which would outlive the signatures
+	req := httptest.NewRequest("GET", "/v1/networks/mainnet/snapshots", nil)
+	req.Header.Set("Authorization", "Bearer valid-key")
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	var snapshots models.NetworkSnapshots
+	if err := json.Unmarshal(rr.Body.Bytes(), &snapshots); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(snapshots.Full.URL, "X-Goog-Signature=") || !strings.Contains(snapshots.PreviousFull[1].URL, "X-Goog-Signature=") {
+		t.Errorf("Expected full snapshot URLs to be signed, got %+v", snapshots)
+	}
+	if strings.Contains(snapshots.Light.URL, "X-Goog-Signature=") {
+		t.Errorf("Expected light snapshot URL to stay public, got %s", snapshots.Light.URL)
+	}
+	if rr.Header().Get("Last-Modified") != "" {
+		t.Errorf("Expected no Last-Modified on signed responses, got %s", rr.Header().Get("Last-Modified"))
+	}
+
+	req = httptest.NewRequest("GET", "/v1/networks/mainnet/snapshots/full/latest/download", nil)
+	req.Header.Set("Authorization", "Bearer valid-key")
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+	if location := rr.Header().Get("Location"); !strings.HasPrefix(location, "https://storage.googleapis.com/taraxa-snapshot/mainnet-full-db-block-12345-20250706-143000.tar.gz?X-Goog-Algorithm=GOOG4-HMAC-SHA256&") {
+		t.Errorf("Expected redirect to a signed URL, got %q", location)
+	}
+
+	if strings.Contains(shared.Full.URL, "X-Goog-Signature=") || strings.Contains(shared.PreviousFull[0].URL, "X-Goog-Signature=") {
+		t.Error("Expected the cached snapshots to be left unsigned")
+	}
+}
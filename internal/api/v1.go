@@ -0,0 +1,450 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/service"
+)
+
+// errorResponse is the JSON error body returned by the v1 API
+type errorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// networksResponse lists the supported networks
+type networksResponse struct {
+	Networks []models.Network `json:"networks"`
+}
+
+// writeJSON encodes value as the JSON response body with the given status
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// writeError writes a JSON error body with the given status
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorResponse{Error: code, Message: message})
+}
+
+// writeUnauthorized writes the same 401 response as RequireAuth
+func writeUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeError(w, http.StatusUnauthorized, "unauthorized", "valid API key required in Authorization header")
+}
+
+// parseSnapshotType validates a snapshot type path segment
+func parseSnapshotType(value string) (models.SnapshotType, bool) {
+	switch snapshotType := models.SnapshotType(value); snapshotType {
+	case models.SnapshotTypeFull, models.SnapshotTypeLight:
+		return snapshotType, true
+	default:
+		return "", false
+	}
+}
+
+// listNetworks handles GET /v1/networks
+func (h *Handler) listNetworks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, networksResponse{Networks: h.snapshotService.GetAllNetworks()})
+}
+
+// getStatus handles GET /v1/status with the result of the last freshness check
+func (h *Handler) getStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, h.freshness.Report())
+}
+
+// loadNetworkSnapshots validates the {network} path value and returns its snapshots
+// filtered for the caller. It writes an error response and returns nil on failure.
+func (h *Handler) loadNetworkSnapshots(w http.ResponseWriter, r *http.Request) (*models.NetworkSnapshots, bool) {
+	network := r.PathValue("network")
+	if !h.snapshotService.IsValidNetwork(network) {
+		writeError(w, http.StatusNotFound, "not_found", "unknown network. Supported networks: mainnet, testnet, devnet")
+		return nil, false
+	}
+
+	principal := h.authMiddleware.Principal(r)
+
+	snapshots, err := h.snapshotService.GetSnapshots(models.Network(network))
+	if err != nil {
+		log.Printf("Error fetching snapshots for network %s (key %s): %v", network, principal, err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to fetch snapshots")
+		return nil, false
+	}
+
+	snapshots = h.authMiddleware.Policy().Filter(principal, models.Network(network), snapshots)
+
+	if snapshots.Stale {
+		w.Header().Set("X-Snapshots-Stale", "true")
+	}
+
+	return snapshots, principal != nil
+}
+
+// authorizeType validates the {network} and {type} path values and checks that the
+// access policy lets the caller see that snapshot type. It writes an error response and returns false on failure.
+func (h *Handler) authorizeType(w http.ResponseWriter, r *http.Request) (models.Network, models.SnapshotType, bool) {
+	snapshotType, ok := parseSnapshotType(r.PathValue("type"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "unknown snapshot type. Supported types: full, light")
+		return "", "", false
+	}
+
+	network := r.PathValue("network")
+	if !h.snapshotService.IsValidNetwork(network) {
+		writeError(w, http.StatusNotFound, "not_found", "unknown network. Supported networks: mainnet, testnet, devnet")
+		return "", "", false
+	}
+
+	if !h.authorize(w, r, models.Network(network), snapshotType) {
+		return "", "", false
+	}
+
+	return models.Network(network), snapshotType, true
+}
+
+// authorize checks that the access policy lets the caller see a snapshot type,
+// writing a 401 or 403 response when it does not
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, network models.Network, snapshotType models.SnapshotType) bool {
+	principal := h.authMiddleware.Principal(r)
+	if h.authMiddleware.Policy().Allows(principal, network, snapshotType) {
+		return true
+	}
+
+	if principal == nil {
+		writeUnauthorized(w)
+	} else {
+		log.Printf("Denied %s %s snapshots to API key %s", network, snapshotType, principal)
+		writeError(w, http.StatusForbidden, "forbidden", fmt.Sprintf("API key does not grant access to %s %s snapshots", network, snapshotType))
+	}
+	return false
+}
+
+// loadTypedSnapshots validates the {type} path value and returns the latest and
+// previous snapshots of that type and whether the caller is authenticated.
+// It writes an error response and returns false on failure.
+func (h *Handler) loadTypedSnapshots(w http.ResponseWriter, r *http.Request) (*models.SnapshotInfo, []models.SnapshotInfo, bool, bool) {
+	_, snapshotType, ok := h.authorizeType(w, r)
+	if !ok {
+		return nil, nil, false, false
+	}
+
+	snapshots, authenticated := h.loadNetworkSnapshots(w, r)
+	if snapshots == nil {
+		return nil, nil, false, false
+	}
+
+	if snapshotType == models.SnapshotTypeFull {
+		return snapshots.Full, snapshots.PreviousFull, authenticated, true
+	}
+	return snapshots.Light, snapshots.PreviousLight, authenticated, true
+}
+
+// getNetworkSnapshots handles GET /v1/networks/{network}/snapshots. With at_block or
+// before it returns the snapshot of each type matching the query instead of the latest ones.
+func (h *Handler) getNetworkSnapshots(w http.ResponseWriter, r *http.Request) {
+	lookup, err := parseSnapshotLookup(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	choice, ok := h.preferredMirror(w, r)
+	if !ok {
+		return
+	}
+
+	snapshots, authenticated := h.loadNetworkSnapshots(w, r)
+	if snapshots == nil {
+		return
+	}
+
+	if lookup != nil {
+		var err error
+		snapshots, err = h.lookupSnapshots(models.Network(r.PathValue("network")), snapshots, lookup)
+		if errors.Is(err, service.ErrSnapshotNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "no snapshot matches the query")
+			return
+		}
+		if err != nil {
+			log.Printf("Error looking up snapshots for network %s: %v", r.PathValue("network"), err)
+			writeError(w, http.StatusInternalServerError, "internal_error", "failed to fetch snapshots")
+			return
+		}
+	}
+
+	snapshots, signed := h.signSnapshots(models.Network(r.PathValue("network")), snapshots)
+	snapshots = h.mirrorSnapshots(choice, snapshots)
+	writeCacheable(w, r, snapshots, lastModified(snapshots.LastModified(), signed), authenticated)
+}
+
+// listSnapshots handles GET /v1/networks/{network}/snapshots/{type}?limit=N&cursor=BLOCK,
+// returning the full snapshot history page by page, newest first
+func (h *Handler) listSnapshots(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var limit int
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > service.MaxPageLimit {
+			writeError(w, http.StatusBadRequest, "bad_request", fmt.Sprintf("limit must be between 1 and %d", service.MaxPageLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	var cursor int64
+	if value := query.Get("cursor"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "bad_request", "cursor must be a positive block number")
+			return
+		}
+		cursor = parsed
+	}
+
+	choice, ok := h.preferredMirror(w, r)
+	if !ok {
+		return
+	}
+
+	network, snapshotType, ok := h.authorizeType(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := h.snapshotService.ListSnapshots(network, snapshotType, cursor, limit)
+	if err != nil {
+		log.Printf("Error listing snapshots for network %s: %v", network, err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to fetch snapshots")
+		return
+	}
+	signed := *page
+	signed.Snapshots, _ = h.signInfos(network, snapshotType, page.Snapshots)
+	signed.Snapshots = h.mirrorInfos(choice, signed.Snapshots)
+	page = &signed
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// getLatestSnapshot handles GET /v1/networks/{network}/snapshots/{type}/latest
+func (h *Handler) getLatestSnapshot(w http.ResponseWriter, r *http.Request) {
+	choice, ok := h.preferredMirror(w, r)
+	if !ok {
+		return
+	}
+
+	latest, _, authenticated, ok := h.loadTypedSnapshots(w, r)
+	if !ok {
+		return
+	}
+
+	if latest == nil {
+		writeError(w, http.StatusNotFound, "not_found", "no snapshot available")
+		return
+	}
+
+	network, snapshotType := models.Network(r.PathValue("network")), models.SnapshotType(r.PathValue("type"))
+	latest, signed := h.signInfo(network, snapshotType, latest)
+	latest = h.mirrorInfo(choice, latest)
+	writeCacheable(w, r, latest, lastModified(latest.Time(), signed), authenticated)
+}
+
+// downloadLatestSnapshot handles GET /v1/networks/{network}/snapshots/{type}/latest/download
+// by redirecting to the download URL of the newest snapshot
+func (h *Handler) downloadLatestSnapshot(w http.ResponseWriter, r *http.Request) {
+	choice, ok := h.preferredMirror(w, r)
+	if !ok {
+		return
+	}
+
+	latest, _, _, ok := h.loadTypedSnapshots(w, r)
+	if !ok {
+		return
+	}
+
+	if latest == nil || latest.URL == "" {
+		writeError(w, http.StatusNotFound, "not_found", "no snapshot available")
+		return
+	}
+
+	h.usage.RecordDownload(r)
+
+	network, snapshotType := models.Network(r.PathValue("network")), models.SnapshotType(r.PathValue("type"))
+	latest, _ = h.signInfo(network, snapshotType, latest)
+	latest = h.mirrorInfo(choice, latest)
+
+	// The target changes whenever a new snapshot is published, so never cache the redirect
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, latest.URL, http.StatusFound)
+}
+
+// getSnapshotByBlock handles GET /v1/networks/{network}/snapshots/{type}/{block}
+func (h *Handler) getSnapshotByBlock(w http.ResponseWriter, r *http.Request) {
+	block, err := strconv.ParseInt(r.PathValue("block"), 10, 64)
+	if err != nil || block < 0 {
+		writeError(w, http.StatusBadRequest, "bad_request", "block must be a non-negative integer")
+		return
+	}
+
+	choice, ok := h.preferredMirror(w, r)
+	if !ok {
+		return
+	}
+
+	network, snapshotType, ok := h.authorizeType(w, r)
+	if !ok {
+		return
+	}
+
+	snapshot, err := h.snapshotService.FindByBlock(network, snapshotType, block, false)
+	if errors.Is(err, service.ErrSnapshotNotFound) || (err == nil && snapshot.Block != block) {
+		writeError(w, http.StatusNotFound, "not_found", "no snapshot at the requested block")
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up snapshot for network %s: %v", network, err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to fetch snapshots")
+		return
+	}
+
+	snapshot, _ = h.signInfo(network, snapshotType, snapshot)
+	writeJSON(w, http.StatusOK, h.mirrorInfo(choice, snapshot))
+}
+
+// findSnapshot handles GET /v1/networks/{network}/snapshots/{type}/lookup. Exactly one of
+// at_block (with optional direction=below|above) or before (RFC 3339 or unix seconds) is required.
+func (h *Handler) findSnapshot(w http.ResponseWriter, r *http.Request) {
+	lookup, err := parseSnapshotLookup(r.URL.Query())
+	if err == nil && lookup == nil {
+		err = errLookupRequired
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	choice, ok := h.preferredMirror(w, r)
+	if !ok {
+		return
+	}
+
+	network, snapshotType, ok := h.authorizeType(w, r)
+	if !ok {
+		return
+	}
+
+	snapshot, err := h.lookupSnapshot(network, snapshotType, lookup)
+	if errors.Is(err, service.ErrSnapshotNotFound) {
+		writeError(w, http.StatusNotFound, "not_found", "no snapshot matches the query")
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up snapshot for network %s: %v", network, err)
+		writeError(w, http.StatusInternalServerError, "internal_error", "failed to fetch snapshots")
+		return
+	}
+
+	snapshot, _ = h.signInfo(network, snapshotType, snapshot)
+	writeJSON(w, http.StatusOK, h.mirrorInfo(choice, snapshot))
+}
+
+var errLookupRequired = errors.New("exactly one of at_block or before is required")
+
+// snapshotLookup is a parsed at_block or before query
+type snapshotLookup struct {
+	block  int64
+	above  bool
+	before *time.Time
+}
+
+// parseSnapshotLookup parses the at_block, direction and before query parameters.
+// It returns nil without an error when neither at_block nor before is set.
+func parseSnapshotLookup(query url.Values) (*snapshotLookup, error) {
+	atBlock, before := query.Get("at_block"), query.Get("before")
+	switch {
+	case atBlock == "" && before == "":
+		return nil, nil
+	case atBlock != "" && before != "":
+		return nil, errLookupRequired
+	case before != "":
+		timestamp, err := parseTimestamp(before)
+		if err != nil {
+			return nil, errors.New("before must be an RFC 3339 timestamp or unix seconds")
+		}
+		return &snapshotLookup{before: &timestamp}, nil
+	}
+
+	block, err := strconv.ParseInt(atBlock, 10, 64)
+	if err != nil || block < 0 {
+		return nil, errors.New("at_block must be a non-negative integer")
+	}
+
+	lookup := &snapshotLookup{block: block}
+	switch query.Get("direction") {
+	case "", "below":
+	case "above":
+		lookup.above = true
+	default:
+		return nil, errors.New("direction must be below or above")
+	}
+	return lookup, nil
+}
+
+// lookupSnapshot searches the snapshot history of one type for the snapshot matching lookup
+func (h *Handler) lookupSnapshot(network models.Network, snapshotType models.SnapshotType, lookup *snapshotLookup) (*models.SnapshotInfo, error) {
+	if lookup.before != nil {
+		return h.snapshotService.FindBefore(network, snapshotType, *lookup.before)
+	}
+	return h.snapshotService.FindByBlock(network, snapshotType, lookup.block, lookup.above)
+}
+
+// lookupSnapshots replaces the latest snapshot of every type present in the already
+// filtered snapshots with the one matching lookup, dropping the previous lists.
+// It returns service.ErrSnapshotNotFound when no type has a match.
+func (h *Handler) lookupSnapshots(network models.Network, snapshots *models.NetworkSnapshots, lookup *snapshotLookup) (*models.NetworkSnapshots, error) {
+	find := func(snapshotType models.SnapshotType, latest *models.SnapshotInfo) (*models.SnapshotInfo, error) {
+		if latest == nil {
+			return nil, nil
+		}
+		snapshot, err := h.lookupSnapshot(network, snapshotType, lookup)
+		if errors.Is(err, service.ErrSnapshotNotFound) {
+			return nil, nil
+		}
+		return snapshot, err
+	}
+
+	found := &models.NetworkSnapshots{Stale: snapshots.Stale}
+	var err error
+	if found.Full, err = find(models.SnapshotTypeFull, snapshots.Full); err != nil {
+		return nil, err
+	}
+	if found.Light, err = find(models.SnapshotTypeLight, snapshots.Light); err != nil {
+		return nil, err
+	}
+
+	if found.Full == nil && found.Light == nil {
+		return nil, service.ErrSnapshotNotFound
+	}
+	return found, nil
+}
+
+// parseTimestamp accepts an RFC 3339 timestamp or unix seconds
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
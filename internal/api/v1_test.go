@@ -0,0 +1,679 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/freshness"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+func TestHandler_V1Routes(t *testing.T) {
+	handler, _ := createTestHandler([]string{"valid-api-key"})
+	routes := handler.Routes()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		authHeader     string
+		expectedStatus int
+		expectedBlock  int64
+	}{
+		{
+			name:           "list networks",
+			method:         "GET",
+			path:           "/v1/networks",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "network snapshots",
+			method:         "GET",
+			path:           "/v1/networks/mainnet/snapshots",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown network",
+			method:         "GET",
+			path:           "/v1/networks/invalid/snapshots",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "latest light snapshot",
+			method:         "GET",
+			path:           "/v1/networks/mainnet/snapshots/light/latest",
+			expectedStatus: http.StatusOK,
+			expectedBlock:  12345,
+		},
+		{
+			name:           "latest full snapshot without auth",
+			method:         "GET",
+			path:           "/v1/networks/mainnet/snapshots/full/latest",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "latest full snapshot with auth",
+			method:         "GET",
+			path:           "/v1/networks/mainnet/snapshots/full/latest",
+			authHeader:     "Bearer valid-api-key",
+			expectedStatus: http.StatusOK,
+			expectedBlock:  12345,
+		},
+		{
+			name:           "unknown snapshot type",
+			method:         "GET",
+			path:           "/v1/networks/mainnet/snapshots/archive/latest",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "light snapshot by block",
+			method:         "GET",
+			path:           "/v1/networks/mainnet/snapshots/light/12343",
+			expectedStatus: http.StatusOK,
+			expectedBlock:  12343,
+		},
+		{
+			name:           "snapshot at unknown block",
+			method:         "GET",
+			path:           "/v1/networks/mainnet/snapshots/light/1",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "invalid block",
+			method:         "GET",
+			path:           "/v1/networks/mainnet/snapshots/light/abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "method not allowed",
+			method:         "POST",
+			path:           "/v1/networks",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "unknown route",
+			method:         "GET",
+			path:           "/unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "unknown v1 route",
+			method:         "GET",
+			path:           "/v1/unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+
+			if tt.expectedBlock != 0 {
+				var result models.SnapshotInfo
+				if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if result.Block != tt.expectedBlock {
+					t.Errorf("Expected block %d, got %d", tt.expectedBlock, result.Block)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_ListNetworks(t *testing.T) {
+	handler, _ := createTestHandler([]string{})
+
+	req, err := http.NewRequest("GET", "/v1/networks", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(rr, req)
+
+	var response networksResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if len(response.Networks) != 3 {
+		t.Errorf("Expected 3 networks, got %d", len(response.Networks))
+	}
+}
+
+func TestHandler_GetNetworkSnapshots_ServiceError(t *testing.T) {
+	handler, mockService := createTestHandler([]string{})
+
+	mockService.GetSnapshotsFunc = func(network models.Network) (*models.NetworkSnapshots, error) {
+		return nil, errors.New("service error")
+	}
+
+	req, err := http.NewRequest("GET", "/v1/networks/mainnet/snapshots", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+	}
+
+	var response errorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Error != "internal_error" {
+		t.Errorf("Expected error 'internal_error', got %s", response.Error)
+	}
+}
+
+func TestHandler_DownloadLatestSnapshot(t *testing.T) {
+	handler, mockService := createTestHandler([]string{"valid-api-key"})
+	routes := handler.Routes()
+
+	tests := []struct {
+		name             string
+		path             string
+		authHeader       string
+		emptyResult      bool
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "light snapshot redirects without auth",
+			path:             "/v1/networks/mainnet/snapshots/light/latest/download",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://storage.googleapis.com/taraxa-snapshot/mainnet-light-db-block-12345-20250706-143000.tar.gz",
+		},
+		{
+			name:           "full snapshot requires auth",
+			path:           "/v1/networks/mainnet/snapshots/full/latest/download",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:             "full snapshot redirects with auth",
+			path:             "/v1/networks/mainnet/snapshots/full/latest/download",
+			authHeader:       "Bearer valid-api-key",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://storage.googleapis.com/taraxa-snapshot/mainnet-full-db-block-12345-20250706-143000.tar.gz",
+		},
+		{
+			name:           "no snapshot available",
+			path:           "/v1/networks/devnet/snapshots/light/latest/download",
+			emptyResult:    true,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.emptyResult {
+				mockService.GetSnapshotsFunc = func(network models.Network) (*models.NetworkSnapshots, error) {
+					return &models.NetworkSnapshots{}, nil
+				}
+			} else {
+				mockService.GetSnapshotsFunc = nil // Use default
+			}
+
+			req, err := http.NewRequest("GET", tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+
+			if location := rr.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Expected Location %q, got %q", tt.expectedLocation, location)
+			}
+
+			if tt.expectedStatus == http.StatusFound && rr.Header().Get("Cache-Control") != "no-store" {
+				t.Errorf("Expected redirect to be uncacheable, got Cache-Control %q", rr.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestHandler_FindSnapshot(t *testing.T) {
+	handler, _ := createTestHandler([]string{"valid-api-key"})
+	routes := handler.Routes()
+
+	tests := []struct {
+		name           string
+		path           string
+		authHeader     string
+		expectedStatus int
+		expectedBlock  int64
+	}{
+		{
+			name:           "at block",
+			path:           "/v1/networks/mainnet/snapshots/light/lookup?at_block=12344",
+			expectedStatus: http.StatusOK,
+			expectedBlock:  12344,
+		},
+		{
+			name:           "at block between snapshots",
+			path:           "/v1/networks/mainnet/snapshots/light/lookup?at_block=20000",
+			expectedStatus: http.StatusOK,
+			expectedBlock:  12345,
+		},
+		{
+			name:           "nearest above",
+			path:           "/v1/networks/mainnet/snapshots/light/lookup?at_block=1&direction=above",
+			expectedStatus: http.StatusOK,
+			expectedBlock:  12343,
+		},
+		{
+			name:           "no snapshot below block",
+			path:           "/v1/networks/mainnet/snapshots/light/lookup?at_block=1",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "before RFC 3339 timestamp",
+			path:           "/v1/networks/mainnet/snapshots/light/lookup?before=2025-07-05T20:00:00Z",
+			expectedStatus: http.StatusOK,
+			expectedBlock:  12344,
+		},
+		{
+			name:           "before unix timestamp",
+			path:           "/v1/networks/mainnet/snapshots/light/lookup?before=1751812200",
+			expectedStatus: http.StatusOK,
+			expectedBlock:  12345,
+		},
+		{
+			name:           "full requires auth",
+			path:           "/v1/networks/mainnet/snapshots/full/lookup?at_block=12345",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "full with auth",
+			path:           "/v1/networks/mainnet/snapshots/full/lookup?at_block=12345",
+			authHeader:     "Bearer valid-api-key",
+			expectedStatus: http.StatusOK,
+			expectedBlock:  12345,
+		},
+		{
+			name:           "missing query",
+			path:           "/v1/networks/mainnet/snapshots/light/lookup",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "both queries",
+			path:           "/v1/networks/mainnet/snapshots/light/lookup?at_block=1&before=1751812200",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid direction",
+			path:           "/v1/networks/mainnet/snapshots/light/lookup?at_block=1&direction=sideways",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid timestamp",
+			path:           "/v1/networks/mainnet/snapshots/light/lookup?before=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+
+			if tt.expectedBlock != 0 {
+				var result models.SnapshotInfo
+				if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if result.Block != tt.expectedBlock {
+					t.Errorf("Expected block %d, got %d", tt.expectedBlock, result.Block)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_GetNetworkSnapshots_Lookup(t *testing.T) {
+	handler, _ := createTestHandler([]string{"valid-api-key"})
+	routes := handler.Routes()
+
+	tests := []struct {
+		name           string
+		path           string
+		authHeader     string
+		expectedStatus int
+		expectedFull   int64
+		expectedLight  int64
+	}{
+		{
+			name:           "at block",
+			path:           "/v1/networks/mainnet/snapshots?at_block=12344",
+			expectedStatus: http.StatusOK,
+			expectedLight:  12344,
+		},
+		{
+			name:           "at block with auth",
+			path:           "/v1/networks/mainnet/snapshots?at_block=12344",
+			authHeader:     "Bearer valid-api-key",
+			expectedStatus: http.StatusOK,
+			expectedFull:   12344,
+			expectedLight:  12344,
+		},
+		{
+			name:           "before",
+			path:           "/v1/networks/mainnet/snapshots?before=2025-07-05T20:00:00Z",
+			expectedStatus: http.StatusOK,
+			expectedLight:  12344,
+		},
+		{
+			name:           "no match",
+			path:           "/v1/networks/mainnet/snapshots?at_block=1",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "both queries",
+			path:           "/v1/networks/mainnet/snapshots?at_block=1&before=1751812200",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "legacy at block",
+			path:           "/?network=mainnet&at_block=20000&direction=below",
+			expectedStatus: http.StatusOK,
+			expectedLight:  12345,
+		},
+		{
+			name:           "legacy before with auth",
+			path:           "/?network=mainnet&before=1751812200",
+			authHeader:     "Bearer valid-api-key",
+			expectedStatus: http.StatusOK,
+			expectedFull:   12345,
+			expectedLight:  12345,
+		},
+		{
+			name:           "legacy no match",
+			path:           "/?network=mainnet&at_block=99999&direction=above",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "legacy invalid block",
+			path:           "/?network=mainnet&at_block=-1",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var result models.NetworkSnapshots
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if got := result.Full; (got == nil) != (tt.expectedFull == 0) || (got != nil && got.Block != tt.expectedFull) {
+				t.Errorf("Expected full block %d, got %+v", tt.expectedFull, got)
+			}
+			if got := result.Light; got == nil || got.Block != tt.expectedLight {
+				t.Errorf("Expected light block %d, got %+v", tt.expectedLight, got)
+			}
+			if len(result.PreviousFull) != 0 || len(result.PreviousLight) != 0 {
+				t.Errorf("Expected no previous snapshots in a lookup, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestHandler_ListSnapshots(t *testing.T) {
+	handler, _ := createTestHandler([]string{"valid-api-key"})
+	routes := handler.Routes()
+
+	tests := []struct {
+		name           string
+		path           string
+		authHeader     string
+		expectedStatus int
+		expectedBlocks []int64
+		expectedCursor int64
+	}{
+		{
+			name:           "first page",
+			path:           "/v1/networks/mainnet/snapshots/light?limit=2",
+			expectedStatus: http.StatusOK,
+			expectedBlocks: []int64{12345, 12344},
+			expectedCursor: 12344,
+		},
+		{
+			name:           "next page",
+			path:           "/v1/networks/mainnet/snapshots/light?limit=2&cursor=12344",
+			expectedStatus: http.StatusOK,
+			expectedBlocks: []int64{12343},
+		},
+		{
+			name:           "full requires auth",
+			path:           "/v1/networks/mainnet/snapshots/full",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "full with auth",
+			path:           "/v1/networks/mainnet/snapshots/full",
+			authHeader:     "Bearer valid-api-key",
+			expectedStatus: http.StatusOK,
+			expectedBlocks: []int64{12345, 12344, 12343},
+		},
+		{
+			name:           "invalid limit",
+			path:           "/v1/networks/mainnet/snapshots/light?limit=0",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "limit too large",
+			path:           "/v1/networks/mainnet/snapshots/light?limit=100000",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid cursor",
+			path:           "/v1/networks/mainnet/snapshots/light?cursor=abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var page models.SnapshotPage
+			if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			if len(page.Snapshots) != len(tt.expectedBlocks) {
+				t.Fatalf("Expected %d snapshots, got %d", len(tt.expectedBlocks), len(page.Snapshots))
+			}
+			for i, expected := range tt.expectedBlocks {
+				if page.Snapshots[i].Block != expected {
+					t.Errorf("Expected block %d at position %d, got %d", expected, i, page.Snapshots[i].Block)
+				}
+			}
+
+			if tt.expectedCursor == 0 {
+				if page.NextCursor != nil {
+					t.Errorf("Expected no next cursor, got %d", *page.NextCursor)
+				}
+			} else if page.NextCursor == nil || *page.NextCursor != tt.expectedCursor {
+				t.Errorf("Expected next cursor %d, got %v", tt.expectedCursor, page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestHandler_GetStatus(t *testing.T) {
+	handler, _ := createTestHandler([]string{})
+
+	// Without a checker the endpoint does not exist
+	rr := httptest.NewRecorder()
+	handler.Routes().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/status", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without freshness checker, got %v", rr.Code)
+	}
+
+	now := time.Now()
+	checker := freshness.NewChecker(
+		[]models.Network{models.NetworkMainnet},
+		map[string]freshness.Threshold{"mainnet/full": {MaxAge: time.Hour}},
+	)
+	checker.Check(now, []*models.Snapshot{{
+		Network:   models.NetworkMainnet,
+		Type:      models.SnapshotTypeFull,
+		Block:     12345,
+		Timestamp: now.Add(-2 * time.Hour),
+	}})
+	handler.SetFreshness(checker)
+
+	rr = httptest.NewRecorder()
+	handler.Routes().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/status", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var report freshness.Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if report.Status != freshness.ReportDegraded {
+		t.Errorf("Expected degraded report, got %s", report.Status)
+	}
+	if len(report.Streams) != 2 {
+		t.Fatalf("Expected 2 streams, got %d", len(report.Streams))
+	}
+	if report.Streams[0].Status != freshness.StatusStale || report.Streams[0].LatestBlock != 12345 {
+		t.Errorf("Unexpected mainnet full status: %+v", report.Streams[0])
+	}
+	if report.Streams[1].Status != freshness.StatusUnmonitored {
+		t.Errorf("Expected unmonitored light stream, got %s", report.Streams[1].Status)
+	}
+}
+
+func TestHandler_AccessPolicy(t *testing.T) {
+	cfg := &config.Config{
+		APIKeys:      []string{"valid-key"},
+		APIKeyGroups: map[string][]string{"partners": {"partner-key"}},
+		AccessPolicy: map[string]config.AccessRule{
+			"devnet/full":  {Access: config.AccessPublic},
+			"mainnet/full": {Access: config.AccessGroups, Groups: []string{"partners"}},
+		},
+	}
+	handler := NewHandler(&MockSnapshotService{}, auth.NewMiddleware(cfg))
+	routes := handler.Routes()
+
+	tests := []struct {
+		name           string
+		path           string
+		authHeader     string
+		expectedStatus int
+		expectFull     bool
+	}{
+		{name: "public devnet full", path: "/v1/networks/devnet/snapshots/full/latest", expectedStatus: http.StatusOK, expectFull: true},
+		{name: "devnet listing includes full", path: "/v1/networks/devnet/snapshots", expectedStatus: http.StatusOK, expectFull: true},
+		{name: "legacy devnet includes full", path: "/?network=devnet", expectedStatus: http.StatusOK, expectFull: true},
+		{name: "mainnet full anonymous", path: "/v1/networks/mainnet/snapshots/full/latest", expectedStatus: http.StatusUnauthorized},
+		{name: "mainnet full outside group", path: "/v1/networks/mainnet/snapshots/full/latest", authHeader: "Bearer valid-key", expectedStatus: http.StatusForbidden},
+		{name: "mainnet full in group", path: "/v1/networks/mainnet/snapshots/full/latest", authHeader: "Bearer partner-key", expectedStatus: http.StatusOK, expectFull: true},
+		{name: "mainnet listing outside group", path: "/v1/networks/mainnet/snapshots", authHeader: "Bearer valid-key", expectedStatus: http.StatusOK, expectFull: false},
+		{name: "testnet full keeps default", path: "/v1/networks/testnet/snapshots/full/latest", authHeader: "Bearer valid-key", expectedStatus: http.StatusOK, expectFull: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.expectedStatus)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			if hasFull := strings.Contains(rr.Body.String(), "-full-"); hasFull != tt.expectFull {
+				t.Errorf("Expected full snapshots: %v, body %s", tt.expectFull, rr.Body.String())
+			}
+		})
+	}
+}
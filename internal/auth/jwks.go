@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minJWKSRefresh limits refetches triggered by tokens with an unknown key id
+const minJWKSRefresh = time.Minute
+
+// jwk is a JSON Web Key as published in a JWKS document
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey is a verification key with the JWS algorithm it is used with
+type publicKey struct {
+	algorithm string
+	key       crypto.PublicKey
+}
+
+// JWKS holds the signing keys of an identity provider, loaded from a file or URL
+type JWKS struct {
+	source string
+	client *http.Client
+
+	mutex       sync.RWMutex
+	keys        map[string]publicKey
+	lastRefresh time.Time
+}
+
+// NewJWKS loads the key set from source, an http(s) URL or a file path
+func NewJWKS(source string) (*JWKS, error) {
+	jwks := &JWKS{
+		source: source,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	if err := jwks.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+// Refresh reloads the key set. The current keys are kept if loading fails.
+func (j *JWKS) Refresh(ctx context.Context) error {
+	j.mutex.Lock()
+	j.lastRefresh = time.Now()
+	j.mutex.Unlock()
+
+	return j.reload(ctx)
+}
+
+// reload fetches and parses the key set without touching lastRefresh
+func (j *JWKS) reload(ctx context.Context) error {
+	data, err := j.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("invalid JWKS from %s: %w", j.source, err)
+	}
+
+	j.mutex.Lock()
+	j.keys = keys
+	j.mutex.Unlock()
+	return nil
+}
+
+// Run refreshes the key set every interval until ctx is cancelled
+func (j *JWKS) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.Refresh(ctx); err != nil {
+				log.Printf("Failed to refresh JWKS, keeping the current keys: %v", err)
+			}
+		}
+	}
+}
+
+// key returns the key with the given id, or the only key for tokens without an id.
+// An unknown id triggers a refresh, at most once per minJWKSRefresh, so keys rotated
+// by the provider are picked up early.
+func (j *JWKS) key(ctx context.Context, kid string) (publicKey, bool) {
+	j.mutex.RLock()
+	key, ok := j.lookup(kid)
+	j.mutex.RUnlock()
+	if ok || !j.claimRefresh() {
+		return key, ok
+	}
+
+	if err := j.reload(ctx); err != nil {
+		log.Printf("Failed to refresh JWKS: %v", err)
+		return publicKey{}, false
+	}
+
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.lookup(kid)
+}
+
+// claimRefresh records a refresh now unless the last one was less than minJWKSRefresh
+// ago. The check and the update happen under one lock, so of many concurrent tokens
+// with unknown key ids only one fetches the key set.
+func (j *JWKS) claimRefresh() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if time.Since(j.lastRefresh) < minJWKSRefresh {
+		return false
+	}
+	j.lastRefresh = time.Now()
+	return true
+}
+
+func (j *JWKS) lookup(kid string) (publicKey, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+func (j *JWKS) fetch(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(j.source, "http://") && !strings.HasPrefix(j.source, "https://") {
+		data, err := os.ReadFile(j.source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS from %s: status %d", j.source, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseJWKS parses a JWKS document into verification keys by key id. Encryption
+// keys and unsupported key types are skipped.
+func parseJWKS(data []byte) (map[string]publicKey, error) {
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]publicKey, len(document.Keys))
+	for _, key := range document.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		parsed, err := key.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.Kid, err)
+		}
+		if parsed.key == nil {
+			continue
+		}
+		if key.Alg != "" && key.Alg != parsed.algorithm {
+			return nil, fmt.Errorf("key %q: algorithm %s does not match key type %s", key.Kid, key.Alg, key.Kty)
+		}
+		keys[key.Kid] = parsed
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no RS256, ES256 or EdDSA signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key; unsupported key types return a nil key
+func (k *jwk) publicKey() (publicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return publicKey{}, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return publicKey{}, errors.New("invalid exponent")
+		}
+		if n.BitLen() < 2048 {
+			return publicKey{}, errors.New("RSA keys must have at least 2048 bits")
+		}
+		return publicKey{algorithm: "RS256", key: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return publicKey{}, nil
+		}
+		x, errX := decodeBigInt(k.X)
+		y, errY := decodeBigInt(k.Y)
+		if errX != nil || errY != nil || !elliptic.P256().IsOnCurve(x, y) {
+			return publicKey{}, errors.New("invalid P-256 point")
+		}
+		return publicKey{algorithm: "ES256", key: &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return publicKey{}, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, errors.New("invalid Ed25519 key")
+		}
+		return publicKey{algorithm: "EdDSA", key: ed25519.PublicKey(x)}, nil
+	default:
+		return publicKey{}, nil
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
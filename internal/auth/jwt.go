@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+)
+
+// jwtLeeway tolerates clock skew between the identity provider and this service
+const jwtLeeway = time.Minute
+
+// JWTVerifier validates JWT bearer tokens signed with RS256, ES256 or EdDSA
+type JWTVerifier struct {
+	keys     *JWKS
+	issuer   string
+	audience string
+	claims   config.JWTClaims
+	now      func() time.Time
+}
+
+// NewJWTVerifier creates a verifier for tokens from issuer that are meant for audience.
+// claims names the claims that carry the permissions of the caller.
+func NewJWTVerifier(keys *JWKS, issuer, audience string, claims config.JWTClaims) *JWTVerifier {
+	return &JWTVerifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		claims:   claims,
+		now:      time.Now,
+	}
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// looksLikeJWT reports whether a bearer token is a JWS in compact form rather than an API key
+func looksLikeJWT(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	_, err := decodeHeader(parts[0])
+	return err == nil
+}
+
+func decodeHeader(segment string) (*jwtHeader, error) {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return nil, errors.New("malformed header")
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(data, &header); err != nil || header.Alg == "" {
+		return nil, errors.New("malformed header")
+	}
+	return &header, nil
+}
+
+// Verify checks the signature, expiry, issuer and audience of a token and maps its
+// claims to a principal
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	header, err := decodeHeader(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := v.keys.key(ctx, header.Kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", header.Kid)
+	}
+	// The algorithm comes from the key, never from the token alone
+	if header.Alg != key.algorithm {
+		return nil, fmt.Errorf("algorithm %s does not match key %q", header.Alg, header.Kid)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+	if !verifySignature(key, parts[0]+"."+parts[1], signature) {
+		return nil, errors.New("invalid signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed payload")
+	}
+	var claims map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, errors.New("malformed payload")
+	}
+
+	return v.principal(claims)
+}
+
+func verifySignature(key publicKey, signingInput string, signature []byte) bool {
+	switch publicKey := key.key.(type) {
+	case *rsa.PublicKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS encodes ES256 signatures as the raw 32-byte r and s values
+		if len(signature) != 64 {
+			return false
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(publicKey, digest[:], r, s)
+	case ed25519.PublicKey:
+		return ed25519.Verify(publicKey, []byte(signingInput), signature)
+	default:
+		return false
+	}
+}
+
+// principal validates the registered claims and maps the permission claims
+func (v *JWTVerifier) principal(claims map[string]interface{}) (*Principal, error) {
+	now := v.now()
+
+	expiresAt, ok := numericDate(claims["exp"])
+	if !ok {
+		return nil, errors.New("missing exp claim")
+	}
+	if !now.Before(expiresAt.Add(jwtLeeway)) {
+		return nil, errors.New("token expired")
+	}
+	if notBefore, ok := numericDate(claims["nbf"]); ok && now.Add(jwtLeeway).Before(notBefore) {
+		return nil, errors.New("token not valid yet")
+	}
+
+	if issuer, _ := claims["iss"].(string); issuer != v.issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", issuer)
+	}
+	if !containsString(stringList(claims["aud"]), v.audience) {
+		return nil, fmt.Errorf("token is not meant for audience %q", v.audience)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("missing sub claim")
+	}
+	owner, _ := claims["email"].(string)
+	if owner == "" {
+		owner = subject
+	}
+	tier, _ := claims[v.claims.Tier].(string)
+
+	return &Principal{
+		ID:        "jwt:" + subject,
+		Owner:     owner,
+		Networks:  stringList(claims[v.claims.Networks]),
+		Types:     stringList(claims[v.claims.Types]),
+		Groups:    stringList(claims[v.claims.Groups]),
+		Tier:      tier,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// numericDate reads a JWT NumericDate claim
+func numericDate(value interface{}) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))).UTC(), true
+}
+
+// stringList reads a claim that is a string array or a space-separated string
+func stringList(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		var items []string
+		for _, item := range value {
+			if item, ok := item.(string); ok && item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	default:
+		return nil
+	}
+}
+
+func containsString(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,400 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+const (
+	testIssuer   = "https://idp.example.com/"
+	testAudience = "snapshots-api"
+)
+
+var testClaims = config.JWTClaims{Networks: "networks", Types: "types", Groups: "groups", Tier: "tier"}
+
+// testSigner signs tokens with a locally generated key
+type testSigner struct {
+	kid       string
+	algorithm string
+	key       crypto.Signer
+}
+
+func newTestSigners(t *testing.T) (rsaSigner, ecSigner, edSigner testSigner) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{kid: "rsa", algorithm: "RS256", key: rsaKey},
+		testSigner{kid: "ec", algorithm: "ES256", key: ecKey},
+		testSigner{kid: "ed", algorithm: "EdDSA", key: edKey}
+}
+
+// jwk returns the public key of the signer as a JWK
+func (s testSigner) jwk() map[string]string {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch key := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": s.kid, "use": "sig", "alg": "RS256",
+			"n": encode(key.N.Bytes()), "e": encode(big.NewInt(int64(key.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": s.kid, "crv": "P-256",
+			"x": encode(key.X.FillBytes(make([]byte, 32))), "y": encode(key.Y.FillBytes(make([]byte, 32)))}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": s.kid, "crv": "Ed25519", "x": encode(key)}
+	}
+	return nil
+}
+
+func jwksDocument(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeJWKS(t *testing.T, signers ...testSigner) *JWKS {
+	t.Helper()
+	var keys []map[string]string
+	for _, signer := range signers {
+		keys = append(keys, signer.jwk())
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksDocument(t, keys...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := NewJWKS(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return jwks
+}
+
+// sign builds a compact JWS with the given header algorithm
+func (s testSigner) sign(t *testing.T, algorithm string, claims map[string]interface{}) string {
+	t.Helper()
+	encode := func(value interface{}) string {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signingInput := encode(map[string]string{"alg": algorithm, "kid": s.kid, "typ": "JWT"}) + "." + encode(claims)
+
+	var signature []byte
+	var err error
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		r, s, signErr := ecdsa.Sign(rand.Reader, key, digest[:])
+		signature, err = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...), signErr
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(signingInput))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":      testIssuer,
+		"aud":      testAudience,
+		"sub":      "svc-indexer",
+		"exp":      now.Add(time.Hour).Unix(),
+		"networks": []string{"mainnet"},
+		"types":    "light full",
+		"groups":   []string{"partners"},
+	}
+}
+
+func withClaims(now time.Time, changes map[string]interface{}) map[string]interface{} {
+	claims := validClaims(now)
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	rsaSigner, ecSigner, edSigner := newTestSigners(t)
+	now := time.Now()
+	verifier := NewJWTVerifier(writeJWKS(t, rsaSigner, ecSigner, edSigner), testIssuer, testAudience, testClaims)
+
+	tests := []struct {
+		name     string
+		token    string
+		expected string
+	}{
+		{name: "RS256", token: rsaSigner.sign(t, "RS256", validClaims(now))},
+		{name: "ES256", token: ecSigner.sign(t, "ES256", validClaims(now))},
+		{name: "EdDSA", token: edSigner.sign(t, "EdDSA", validClaims(now))},
+		{name: "audience array", token: rsaSigner.sign(t, "RS256", withClaims(now, map[string]interface{}{"aud": []string{"other", testAudience}}))},
+		{name: "expired within leeway", token: rsaSigner.sign(t, "RS256", withClaims(now, map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}))},
+		{name: "expired", token: rsaSigner.sign(t, "RS256", withClaims(now, map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})), expected: "token expired"},
+		{name: "missing exp", token: rsaSigner.sign(t, "RS256", withClaims(now, map[string]interface{}{"exp": nil})), expected: "missing exp"},
+		{name: "not valid yet", token: rsaSigner.sign(t, "RS256", withClaims(now, map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})), expected: "not valid yet"},
+		{name: "wrong issuer", token: rsaSigner.sign(t, "RS256", withClaims(now, map[string]interface{}{"iss": "https://evil.example.com/"})), expected: "unexpected issuer"},
+		{name: "wrong audience", token: rsaSigner.sign(t, "RS256", withClaims(now, map[string]interface{}{"aud": "other"})), expected: "audience"},
+		{name: "missing subject", token: rsaSigner.sign(t, "RS256", withClaims(now, map[string]interface{}{"sub": nil})), expected: "missing sub"},
+		{name: "algorithm mismatch", token: rsaSigner.sign(t, "ES256", validClaims(now)), expected: "does not match key"},
+		{name: "alg none", token: rsaSigner.sign(t, "none", validClaims(now)), expected: "does not match key"},
+		{name: "unknown key", token: testSigner{kid: "other", key: rsaSigner.key}.sign(t, "RS256", validClaims(now)), expected: "unknown key id"},
+		{name: "tampered payload", token: tamper(rsaSigner.sign(t, "RS256", validClaims(now))), expected: "invalid signature"},
+		{name: "malformed", token: "a.b", expected: "malformed token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := verifier.Verify(context.Background(), tt.token)
+			if tt.expected != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expected) {
+					t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if principal.ID != "jwt:svc-indexer" || principal.Owner != "svc-indexer" {
+				t.Errorf("Unexpected principal: %+v", principal)
+			}
+			if !principal.InScope(models.NetworkMainnet, models.SnapshotTypeLight) || principal.InScope(models.NetworkTestnet, models.SnapshotTypeLight) {
+				t.Errorf("Expected networks and types from claims, got %+v", principal)
+			}
+			if len(principal.Groups) != 1 || principal.Groups[0] != "partners" {
+				t.Errorf("Expected groups [partners], got %v", principal.Groups)
+			}
+		})
+	}
+}
+
+// tamper replaces the payload of a token with different claims, keeping the signature
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`))
+	return strings.Join(parts, ".")
+}
+
+func TestJWTVerifier_ClaimMapping(t *testing.T) {
+	rsaSigner, _, _ := newTestSigners(t)
+	now := time.Now()
+	verifier := NewJWTVerifier(writeJWKS(t, rsaSigner), testIssuer, testAudience, config.JWTClaims{
+		Networks: "snapshot_networks", Types: "types", Groups: "roles", Tier: "plan",
+	})
+
+	token := rsaSigner.sign(t, "RS256", withClaims(now, map[string]interface{}{
+		"snapshot_networks": "testnet",
+		"roles":             []string{"internal"},
+		"plan":              "partner",
+		"email":             "indexer@taraxa.io",
+	}))
+	principal, err := verifier.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(principal.Networks) != 1 || principal.Networks[0] != "testnet" || principal.Groups[0] != "internal" ||
+		principal.Tier != "partner" || principal.Owner != "indexer@taraxa.io" {
+		t.Errorf("Unexpected principal: %+v", principal)
+	}
+	if principal.ExpiresAt.Unix() != now.Add(time.Hour).Unix() {
+		t.Errorf("Expected ExpiresAt from exp, got %v", principal.ExpiresAt)
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaSigner, ecSigner, edSigner := newTestSigners(t)
+
+	keys, err := parseJWKS(jwksDocument(t, rsaSigner.jwk(), ecSigner.jwk(), edSigner.jwk(),
+		map[string]string{"kty": "RSA", "kid": "encryption", "use": "enc"},
+		map[string]string{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+	))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(keys) != 3 || keys["rsa"].algorithm != "RS256" || keys["ec"].algorithm != "ES256" || keys["ed"].algorithm != "EdDSA" {
+		t.Errorf("Expected the three signing keys, got %v", keys)
+	}
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched := ecSigner.jwk()
+	mismatched["alg"] = "RS256"
+	offCurve := ecSigner.jwk()
+	offCurve["y"] = offCurve["x"]
+
+	tests := []struct {
+		name     string
+		key      map[string]string
+		expected string
+	}{
+		{name: "short RSA key", key: testSigner{kid: "small", key: smallKey}.jwk(), expected: "at least 2048 bits"},
+		{name: "algorithm mismatch", key: mismatched, expected: "does not match key type"},
+		{name: "point off the curve", key: offCurve, expected: "invalid P-256 point"},
+		{name: "no signing keys", key: map[string]string{"kty": "oct", "kid": "hmac"}, expected: "no RS256, ES256 or EdDSA signing keys"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseJWKS(jwksDocument(t, tt.key)); err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestJWKS_RefreshOnUnknownKey(t *testing.T) {
+	rsaSigner, ecSigner, _ := newTestSigners(t)
+
+	var document atomic.Value
+	document.Store(jwksDocument(t, rsaSigner.jwk()))
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(document.Load().([]byte))
+	}))
+	defer server.Close()
+
+	jwks, err := NewJWKS(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifier := NewJWTVerifier(jwks, testIssuer, testAudience, testClaims)
+
+	// The provider rotates to a new key
+	document.Store(jwksDocument(t, rsaSigner.jwk(), ecSigner.jwk()))
+	token := ecSigner.sign(t, "ES256", validClaims(time.Now()))
+
+	if _, err := verifier.Verify(context.Background(), token); err == nil {
+		t.Error("Expected unknown key to be rejected right after a refresh")
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("Expected no refetch within a minute of the last refresh, got %d fetches", fetches.Load())
+	}
+
+	jwks.mutex.Lock()
+	jwks.lastRefresh = time.Now().Add(-minJWKSRefresh)
+	jwks.mutex.Unlock()
+
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Errorf("Expected rotated key to be fetched, got %v", err)
+	}
+	if fetches.Load() != 2 {
+		t.Errorf("Expected 2 fetches, got %d", fetches.Load())
+	}
+}
+
+func TestJWKS_ConcurrentUnknownKeys(t *testing.T) {
+	rsaSigner, _, edSigner := newTestSigners(t)
+
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(jwksDocument(t, rsaSigner.jwk()))
+	}))
+	defer server.Close()
+
+	jwks, err := NewJWKS(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	verifier := NewJWTVerifier(jwks, testIssuer, testAudience, testClaims)
+
+	jwks.mutex.Lock()
+	jwks.lastRefresh = time.Now().Add(-minJWKSRefresh)
+	jwks.mutex.Unlock()
+
+	// A burst of tokens with an unknown kid triggers a single refetch
+	token := edSigner.sign(t, "EdDSA", validClaims(time.Now()))
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			verifier.Verify(context.Background(), token)
+		}()
+	}
+	wg.Wait()
+
+	if fetches.Load() != 2 {
+		t.Errorf("Expected 2 fetches, got %d", fetches.Load())
+	}
+}
+
+func TestMiddleware_JWT(t *testing.T) {
+	rsaSigner, _, _ := newTestSigners(t)
+	middleware := NewMiddleware(&config.Config{APIKeys: []string{"static-key"}})
+	middleware.SetJWT(NewJWTVerifier(writeJWKS(t, rsaSigner), testIssuer, testAudience, testClaims))
+
+	now := time.Now()
+	tests := []struct {
+		name     string
+		token    string
+		expected string
+	}{
+		{name: "valid JWT", token: rsaSigner.sign(t, "RS256", validClaims(now)), expected: "jwt:svc-indexer"},
+		{name: "expired JWT", token: rsaSigner.sign(t, "RS256", withClaims(now, map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})), expected: "anonymous"},
+		{name: "static API key", token: "static-key", expected: "env-1"},
+		{name: "unknown API key", token: "other-key", expected: "anonymous"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/snapshots", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			if got := middleware.Principal(req).String(); got != tt.expected {
+				t.Errorf("Expected principal %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	// Reloading the configuration keeps JWTs working
+	middleware.Reload(&config.Config{})
+	req := httptest.NewRequest(http.MethodGet, "/api/snapshots", nil)
+	req.Header.Set("Authorization", "Bearer "+tests[0].token)
+	if middleware.Principal(req) == nil {
+		t.Error("Expected JWT to be accepted after reload")
+	}
+}
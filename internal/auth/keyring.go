@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/keyhash"
+)
+
+// verifiedCacheSize bounds the caches of keys verified against slow hashes
+const verifiedCacheSize = 1024
+
+// rejectedTTL is how long a key that failed slow hash verification is rejected without
+// verifying it again. Rebuilding the keyring clears it, so new keys work right away.
+const rejectedTTL = time.Minute
+
+// Keyring indexes the configured API keys so a presented key is matched in O(1):
+// plaintext and SHA-256 entries by the digest of the presented key, argon2id and
+// bcrypt entries by the public key prefix.
+type Keyring struct {
+	byDigest map[[sha256.Size]byte]*config.APIKey
+	byPrefix map[string][]*config.APIKey
+
+	mutex sync.Mutex
+	// verified remembers keys that matched a slow hash, by digest, so each request
+	// does not pay for argon2id or bcrypt again
+	verified map[[sha256.Size]byte]*config.APIKey
+	// rejected remembers, by digest, when keys carrying a known prefix failed to match,
+	// so repeating an invalid key does not run argon2id or bcrypt on every request
+	rejected map[[sha256.Size]byte]time.Time
+}
+
+// NewKeyring indexes keys for lookup by presented key
+func NewKeyring(keys []config.APIKey) *Keyring {
+	keyring := &Keyring{
+		byDigest: make(map[[sha256.Size]byte]*config.APIKey),
+		byPrefix: make(map[string][]*config.APIKey),
+		verified: make(map[[sha256.Size]byte]*config.APIKey),
+		rejected: make(map[[sha256.Size]byte]time.Time),
+	}
+
+	for _, key := range keys {
+		key := key
+		switch {
+		case key.Hash == "":
+			keyring.byDigest[sha256.Sum256([]byte(key.Key))] = &key
+		case hashAlgorithm(key.Hash) == keyhash.SHA256:
+			digest, _ := keyhash.SHA256Digest(key.Hash)
+			keyring.byDigest[digest] = &key
+		case key.Prefix != "":
+			keyring.byPrefix[key.Prefix] = append(keyring.byPrefix[key.Prefix], &key)
+		}
+	}
+
+	return keyring
+}
+
+// Lookup returns the key entry matching a presented key. Inactive keys are returned
+// too; callers must check Active.
+func (k *Keyring) Lookup(apiKey string) (*config.APIKey, bool) {
+	if apiKey == "" {
+		return nil, false
+	}
+
+	digest := sha256.Sum256([]byte(apiKey))
+	if key, ok := k.byDigest[digest]; ok && key.Matches(apiKey) {
+		return key, true
+	}
+
+	candidates := k.byPrefix[keyhash.Prefix(apiKey)]
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	now := time.Now()
+	k.mutex.Lock()
+	key, ok := k.verified[digest]
+	rejectedAt, rejected := k.rejected[digest]
+	k.mutex.Unlock()
+	if ok {
+		return key, true
+	}
+	if rejected && now.Sub(rejectedAt) < rejectedTTL {
+		return nil, false
+	}
+
+	for _, candidate := range candidates {
+		if candidate.Matches(apiKey) {
+			k.mutex.Lock()
+			if len(k.verified) >= verifiedCacheSize {
+				clear(k.verified)
+			}
+			k.verified[digest] = candidate
+			k.mutex.Unlock()
+			return candidate, true
+		}
+	}
+
+	k.mutex.Lock()
+	if len(k.rejected) >= verifiedCacheSize {
+		clear(k.rejected)
+	}
+	k.rejected[digest] = now
+	k.mutex.Unlock()
+	return nil, false
+}
+
+func hashAlgorithm(hash string) string {
+	algorithm, _ := keyhash.Algorithm(hash)
+	return algorithm
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/keyhash"
+)
+
+func TestKeyring_Lookup(t *testing.T) {
+	mustHash := func(algorithm, key string) string {
+		hash, err := keyhash.Hash(algorithm, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	cfg := &config.Config{
+		APIKeys:      []string{"plain-key", mustHash(keyhash.SHA256, "env-hashed-key")},
+		APIKeyGroups: map[string][]string{"partners": {"partner-key"}},
+		Keys: []config.APIKey{
+			{ID: "sha", Hash: mustHash(keyhash.SHA256, "snap_00000001_sha")},
+			{ID: "argon", Prefix: "snap_00000002", Hash: mustHash(keyhash.Argon2id, "snap_00000002_argon")},
+			{ID: "bcrypt", Prefix: "snap_00000003", Hash: mustHash(keyhash.Bcrypt, "snap_00000003_bcrypt")},
+		},
+	}
+	keyring := NewKeyring(cfg.AllKeys())
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		expectedID string
+	}{
+		{name: "plaintext env key", apiKey: "plain-key", expectedID: "env-1"},
+		{name: "sha256 env key", apiKey: "env-hashed-key", expectedID: "env-2"},
+		{name: "group key", apiKey: "partner-key", expectedID: "group-partners"},
+		{name: "sha256 file key", apiKey: "snap_00000001_sha", expectedID: "sha"},
+		{name: "argon2id file key", apiKey: "snap_00000002_argon", expectedID: "argon"},
+		{name: "argon2id cached", apiKey: "snap_00000002_argon", expectedID: "argon"},
+		{name: "bcrypt file key", apiKey: "snap_00000003_bcrypt", expectedID: "bcrypt"},
+		{name: "wrong secret", apiKey: "snap_00000002_wrong"},
+		{name: "unknown prefix", apiKey: "snap_00000009_argon"},
+		{name: "hash is not a key", apiKey: cfg.APIKeys[1]},
+		{name: "empty", apiKey: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, found := keyring.Lookup(tt.apiKey)
+			if found != (tt.expectedID != "") {
+				t.Fatalf("Expected found=%v, got %v", tt.expectedID != "", found)
+			}
+			if found && key.ID != tt.expectedID {
+				t.Errorf("Expected key %s, got %s", tt.expectedID, key.ID)
+			}
+		})
+	}
+}
+
+func TestKeyring_Lookup_RejectedCache(t *testing.T) {
+	hash, err := keyhash.Hash(keyhash.Argon2id, "snap_00000002_argon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := NewKeyring([]config.APIKey{{ID: "argon", Prefix: "snap_00000002", Hash: hash}})
+
+	if _, found := keyring.Lookup("snap_00000002_wrong"); found {
+		t.Fatal("Expected the wrong secret to be rejected")
+	}
+	digest := sha256.Sum256([]byte("snap_00000002_wrong"))
+	if _, cached := keyring.rejected[digest]; !cached {
+		t.Fatal("Expected the rejected key to be cached")
+	}
+
+	// Cached rejections skip verification, even if the entry would now match
+	keyring.byPrefix["snap_00000002"][0].Hash, _ = keyhash.Hash(keyhash.Argon2id, "snap_00000002_wrong")
+	if _, found := keyring.Lookup("snap_00000002_wrong"); found {
+		t.Error("Expected the cached rejection to be used")
+	}
+
+	// Once the rejection expires the key is verified again
+	keyring.rejected[digest] = time.Now().Add(-rejectedTTL)
+	if key, found := keyring.Lookup("snap_00000002_wrong"); !found || key.ID != "argon" {
+		t.Errorf("Expected the key to be verified again after the TTL, got %v %v", key, found)
+	}
+
+	// Keys without a known prefix never reach the slow path or the cache
+	keyring.Lookup("snap_00000009_other")
+	if _, cached := keyring.rejected[sha256.Sum256([]byte("snap_00000009_other"))]; cached {
+		t.Error("Expected keys with an unknown prefix not to be cached")
+	}
+}
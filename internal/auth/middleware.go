@@ -1,22 +1,134 @@
 package auth
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/keystore"
 )
 
 // Middleware provides authentication functionality
 type Middleware struct {
-	config *config.Config
+	state atomic.Pointer[state]
+
+	// mutex serializes rebuilds of state from config and the key store
+	mutex     sync.Mutex
+	config    *config.Config
+	keyStore  keystore.Store
+	storeKeys []config.APIKey
+	jwt       *JWTVerifier
+
+	// rejectedJWTs counts rejected tokens since lastJWTLog, the unix nanoseconds of
+	// the last "Rejected JWT" log line
+	rejectedJWTs atomic.Int64
+	lastJWTLog   atomic.Int64
+}
+
+// jwtLogInterval limits how often rejected JWTs are logged, so a flood of invalid
+// tokens does not flood the log
+const jwtLogInterval = 10 * time.Second
+
+// state is the key set and policy swapped as a whole on reload, so a request never
+// sees keys from one configuration and the policy from another
+type state struct {
+	config  *config.Config
+	keyring *Keyring
+	policy  *Policy
+	jwt     *JWTVerifier
 }
 
 // NewMiddleware creates a new authentication middleware
 func NewMiddleware(cfg *config.Config) *Middleware {
-	return &Middleware{
-		config: cfg,
+	m := &Middleware{}
+	m.Reload(cfg)
+	return m
+}
+
+// Reload atomically replaces the API keys, admin keys and access policy. Requests
+// in flight finish with the configuration they started with.
+func (m *Middleware) Reload(cfg *config.Config) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.config = cfg
+	m.rebuild()
+}
+
+// SetKeyStore makes store the source of API keys in place of the configuration.
+// Configured keys missing from the store are imported first; after that, keys
+// added to the configuration are ignored and keys are managed through the admin API.
+func (m *Middleware) SetKeyStore(store keystore.Store) error {
+	m.mutex.Lock()
+	imported, err := keystore.Import(store, m.config.AllKeys())
+	if len(imported) > 0 {
+		log.Printf("Imported API keys %s into the key store", strings.Join(imported, ", "))
+	}
+	if err != nil {
+		m.mutex.Unlock()
+		return err
+	}
+	m.keyStore = store
+	m.mutex.Unlock()
+
+	return m.RefreshKeys()
+}
+
+// SetJWT accepts JWT bearer tokens validated by verifier in addition to API keys
+func (m *Middleware) SetJWT(verifier *JWTVerifier) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.jwt = verifier
+	m.rebuild()
+}
+
+// RefreshKeys reloads the keys from the key store; call it after changing the store.
+// Keys are cached between refreshes, which is exact as long as this process is the
+// only one writing the store.
+func (m *Middleware) RefreshKeys() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.keyStore == nil {
+		return nil
+	}
+	keys, err := m.keyStore.List()
+	if err != nil {
+		return fmt.Errorf("failed to load keys from the key store: %w", err)
 	}
+	m.storeKeys = keys
+	m.rebuild()
+	return nil
+}
+
+// ConfigKeys returns the keys from the configuration, which cannot be changed at runtime
+func (m *Middleware) ConfigKeys() []config.APIKey {
+	return m.state.Load().config.AllKeys()
+}
+
+func (m *Middleware) rebuild() {
+	keys := m.config.AllKeys()
+	if m.keyStore != nil {
+		keys = m.storeKeys
+	}
+
+	m.state.Store(&state{
+		config:  m.config,
+		keyring: NewKeyring(keys),
+		policy:  NewPolicy(m.config.AccessPolicy),
+		jwt:     m.jwt,
+	})
+}
+
+// Policy returns the snapshot access policy
+func (m *Middleware) Policy() *Policy {
+	return m.state.Load().policy
 }
 
 // ExtractAPIKey extracts API key from Authorization header
@@ -38,12 +150,78 @@ func (m *Middleware) ExtractAPIKey(r *http.Request) (string, bool) {
 
 // IsAuthenticated checks if the request has a valid API key
 func (m *Middleware) IsAuthenticated(r *http.Request) bool {
+	return m.Principal(r) != nil
+}
+
+// Principal returns the caller of the request, or nil for anonymous requests and
+// invalid, disabled or expired keys. It uses the principal attached by Authenticate
+// when present.
+func (m *Middleware) Principal(r *http.Request) *Principal {
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		return principal
+	}
+	return m.resolve(r)
+}
+
+// Authenticate resolves the API key of every request once and attaches the
+// principal to the request context for handlers and logs
+func (m *Middleware) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), m.resolve(r))))
+	})
+}
+
+// Resolve looks up the caller of r against the current keys, ignoring the principal
+// attached by Authenticate. Long-lived responses use it to notice keys that were
+// revoked, expired or rescoped after the request started.
+func (m *Middleware) Resolve(r *http.Request) *Principal {
+	return m.resolve(r)
+}
+
+// resolve validates the JWT or looks up the API key of a request. Tokens that are
+// not JWTs fall back to the API key lookup.
+func (m *Middleware) resolve(r *http.Request) *Principal {
 	apiKey, found := m.ExtractAPIKey(r)
 	if !found {
-		return false
+		return nil
 	}
 
-	return m.config.IsValidAPIKey(apiKey)
+	current := m.state.Load()
+	if current.jwt != nil && looksLikeJWT(apiKey) {
+		principal, err := current.jwt.Verify(r.Context(), apiKey)
+		if err != nil {
+			m.logRejectedJWT(err)
+			return nil
+		}
+		return principal
+	}
+
+	key, found := current.keyring.Lookup(apiKey)
+	if !found {
+		return nil
+	}
+
+	if now := time.Now(); !key.Active(now) {
+		reason := "disabled"
+		if !key.Disabled {
+			reason = "expired"
+		}
+		log.Printf("Rejected API key %s (%s): %s", key.ID, key.Owner, reason)
+		return nil
+	}
+
+	return newPrincipal(key)
+}
+
+// logRejectedJWT logs a rejected token at most once per jwtLogInterval, together
+// with the number of tokens rejected since the previous line
+func (m *Middleware) logRejectedJWT(err error) {
+	m.rejectedJWTs.Add(1)
+	now, last := time.Now().UnixNano(), m.lastJWTLog.Load()
+	if now-last < int64(jwtLogInterval) || !m.lastJWTLog.CompareAndSwap(last, now) {
+		return
+	}
+	log.Printf("Rejected JWT: %v (%d rejected since the last report)", err, m.rejectedJWTs.Swap(0))
 }
 
 // RequireAuth is a middleware that requires authentication
@@ -59,3 +237,27 @@ func (m *Middleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r)
 	}
 }
+
+// IsAdmin checks if the request has a valid admin API key
+func (m *Middleware) IsAdmin(r *http.Request) bool {
+	apiKey, found := m.ExtractAPIKey(r)
+	if !found {
+		return false
+	}
+
+	return m.state.Load().config.IsValidAdminKey(apiKey)
+}
+
+// RequireAdmin is a middleware that requires an admin API key
+func (m *Middleware) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !m.IsAdmin(r) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "unauthorized", "message": "valid admin API key required in Authorization header"}`))
+			return
+		}
+		next(w, r)
+	}
+}
@@ -3,9 +3,13 @@ package auth
 import (
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
 
 	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/keyhash"
+	"github.com/taraxa/snapshots-api/internal/keystore"
+	"github.com/taraxa/snapshots-api/internal/models"
 )
 
 func TestMiddleware_ExtractAPIKey(t *testing.T) {
@@ -264,3 +268,128 @@ func TestConfig_IsValidAPIKey_EmptyConfig(t *testing.T) {
 		t.Errorf("IsValidAPIKey() with empty config should return false, got %v", result)
 	}
 }
+
+func TestMiddleware_RequireAdmin(t *testing.T) {
+	cfg := &config.Config{APIKeys: []string{"valid-key"}, AdminAPIKeys: []string{"admin-key"}}
+	middleware := NewMiddleware(cfg)
+
+	wrappedHandler := middleware.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		authHeader     string
+		expectedStatus int
+	}{
+		{name: "admin key", authHeader: "Bearer admin-key", expectedStatus: http.StatusOK},
+		{name: "regular API key", authHeader: "Bearer valid-key", expectedStatus: http.StatusUnauthorized},
+		{name: "no key", authHeader: "", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			rr := httptest.NewRecorder()
+			wrappedHandler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Reload(t *testing.T) {
+	middleware := NewMiddleware(&config.Config{APIKeys: []string{"old-key"}, AdminAPIKeys: []string{"old-admin"}})
+
+	request := func(key string) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		return req
+	}
+
+	if !middleware.IsAuthenticated(request("old-key")) || !middleware.IsAdmin(request("old-admin")) {
+		t.Fatal("Expected the initial keys to be valid")
+	}
+
+	middleware.Reload(&config.Config{
+		APIKeys:      []string{"new-key"},
+		AdminAPIKeys: []string{"new-admin"},
+		AccessPolicy: map[string]config.AccessRule{"*/light": {Access: config.AccessAuthenticated}},
+	})
+
+	if middleware.IsAuthenticated(request("old-key")) || middleware.IsAdmin(request("old-admin")) {
+		t.Error("Expected the rotated keys to be rejected")
+	}
+	if !middleware.IsAuthenticated(request("new-key")) || !middleware.IsAdmin(request("new-admin")) {
+		t.Error("Expected the new keys to be valid")
+	}
+	if middleware.Policy().Allows(nil, models.NetworkMainnet, models.SnapshotTypeLight) {
+		t.Error("Expected the reloaded policy to require a key for light snapshots")
+	}
+}
+
+func TestMiddleware_SetKeyStore(t *testing.T) {
+	store, err := keystore.OpenBoltStore(filepath.Join(t.TempDir(), "keys.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	hash, _ := keyhash.Hash(keyhash.SHA256, "snap_00000001_stored")
+	if err := store.Create(config.APIKey{ID: "stored", Prefix: "snap_00000001", Hash: hash}); err != nil {
+		t.Fatal(err)
+	}
+
+	middleware := NewMiddleware(&config.Config{APIKeys: []string{"env-key"}})
+	if err := middleware.SetKeyStore(store); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	request := func(key string) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		return req
+	}
+
+	if principal := middleware.Principal(request("snap_00000001_stored")); principal == nil || principal.ID != "stored" {
+		t.Errorf("Expected the stored key to authenticate, got %+v", principal)
+	}
+	if !middleware.IsAuthenticated(request("env-key")) {
+		t.Error("Expected configured keys to be imported into the store")
+	}
+	if _, err := store.Get("env-1"); err != nil {
+		t.Errorf("Expected the configured key in the store: %v", err)
+	}
+
+	// Store changes apply after a refresh
+	store.Update("stored", func(key *config.APIKey) error {
+		key.Disabled = true
+		return nil
+	})
+	if err := middleware.RefreshKeys(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if middleware.IsAuthenticated(request("snap_00000001_stored")) {
+		t.Error("Expected the revoked key to be rejected")
+	}
+
+	// The store stays the source of keys across config reloads
+	store.Update("stored", func(key *config.APIKey) error {
+		key.Disabled = false
+		return nil
+	})
+	middleware.RefreshKeys()
+	middleware.Reload(&config.Config{APIKeys: []string{"added-key"}})
+	if !middleware.IsAuthenticated(request("snap_00000001_stored")) || !middleware.IsAuthenticated(request("env-key")) {
+		t.Error("Expected stored keys to survive a config reload")
+	}
+	if middleware.IsAuthenticated(request("added-key")) {
+		t.Error("Expected keys added to the configuration to be ignored")
+	}
+}
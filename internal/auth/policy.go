@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// Policy decides which snapshot streams a caller may see. Rules are keyed by
+// "network/type", with "*" matching any network; an exact network wins.
+type Policy struct {
+	rules map[string]config.AccessRule
+}
+
+// defaultRules keeps light snapshots public and full snapshots behind an API key
+var defaultRules = map[string]config.AccessRule{
+	"*/" + string(models.SnapshotTypeLight): {Access: config.AccessPublic},
+	"*/" + string(models.SnapshotTypeFull):  {Access: config.AccessAuthenticated},
+}
+
+// NewPolicy creates a policy from rules layered over the default rules
+func NewPolicy(rules map[string]config.AccessRule) *Policy {
+	merged := make(map[string]config.AccessRule, len(defaultRules)+len(rules))
+	for stream, rule := range defaultRules {
+		merged[stream] = rule
+	}
+	for stream, rule := range rules {
+		merged[stream] = rule
+	}
+	return &Policy{rules: merged}
+}
+
+// Rule returns the rule that applies to a network and snapshot type.
+// Streams without any rule require authentication.
+func (p *Policy) Rule(network models.Network, snapshotType models.SnapshotType) config.AccessRule {
+	if rule, ok := p.rules[streamKey(string(network), snapshotType)]; ok {
+		return rule
+	}
+	if rule, ok := p.rules[streamKey("*", snapshotType)]; ok {
+		return rule
+	}
+	return config.AccessRule{Access: config.AccessAuthenticated}
+}
+
+// Allows reports whether principal may see snapshots of a network and type. A nil
+// principal is an anonymous caller. Non-public streams also require the network and
+// type to be within the key's scopes.
+func (p *Policy) Allows(principal *Principal, network models.Network, snapshotType models.SnapshotType) bool {
+	rule := p.Rule(network, snapshotType)
+	if rule.Access == config.AccessPublic {
+		return true
+	}
+	if principal == nil || !principal.InScope(network, snapshotType) {
+		return false
+	}
+
+	switch rule.Access {
+	case config.AccessAuthenticated:
+		return true
+	case config.AccessGroups:
+		for _, group := range principal.Groups {
+			if slices.Contains(rule.Groups, group) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// Filter returns the snapshots of a network that principal may see
+func (p *Policy) Filter(principal *Principal, network models.Network, snapshots *models.NetworkSnapshots) *models.NetworkSnapshots {
+	return snapshots.Filter(func(snapshotType models.SnapshotType) bool {
+		return p.Allows(principal, network, snapshotType)
+	})
+}
+
+func streamKey(network string, snapshotType models.SnapshotType) string {
+	return fmt.Sprintf("%s/%s", network, snapshotType)
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+func TestPolicy_Allows(t *testing.T) {
+	policy := NewPolicy(map[string]config.AccessRule{
+		"devnet/full":  {Access: config.AccessPublic},
+		"mainnet/full": {Access: config.AccessGroups, Groups: []string{"partners", "internal"}},
+		"*/archive":    {Access: config.AccessPublic},
+	})
+
+	var anonymous *Principal
+	authenticated := &Principal{ID: "key-1"}
+	partner := &Principal{ID: "key-2", Groups: []string{"partners"}}
+	testnetOnly := &Principal{ID: "key-3", Networks: []string{"testnet"}}
+	lightOnly := &Principal{ID: "key-4", Types: []string{"light"}}
+
+	tests := []struct {
+		name         string
+		principal    *Principal
+		network      models.Network
+		snapshotType models.SnapshotType
+		expected     bool
+	}{
+		{"default light is public", anonymous, models.NetworkMainnet, models.SnapshotTypeLight, true},
+		{"default full needs a key", anonymous, models.NetworkTestnet, models.SnapshotTypeFull, false},
+		{"default full with key", authenticated, models.NetworkTestnet, models.SnapshotTypeFull, true},
+		{"network override makes full public", anonymous, models.NetworkDevnet, models.SnapshotTypeFull, true},
+		{"group rule rejects anonymous", anonymous, models.NetworkMainnet, models.SnapshotTypeFull, false},
+		{"group rule rejects other keys", authenticated, models.NetworkMainnet, models.SnapshotTypeFull, false},
+		{"group rule accepts members", partner, models.NetworkMainnet, models.SnapshotTypeFull, true},
+		{"unknown type needs a key", anonymous, models.NetworkMainnet, models.SnapshotType("other"), false},
+		{"network scope allows listed network", testnetOnly, models.NetworkTestnet, models.SnapshotTypeFull, true},
+		{"public stream ignores network scope", testnetOnly, models.NetworkDevnet, models.SnapshotType("archive"), true},
+		{"network scope rejects other gated networks", testnetOnly, models.NetworkMainnet, models.SnapshotTypeFull, false},
+		{"type scope rejects full", lightOnly, models.NetworkTestnet, models.SnapshotTypeFull, false},
+		{"scopes do not hide public streams", lightOnly, models.NetworkDevnet, models.SnapshotTypeFull, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Allows(tt.principal, tt.network, tt.snapshotType); got != tt.expected {
+				t.Errorf("Allows() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPolicy_Filter(t *testing.T) {
+	policy := NewPolicy(map[string]config.AccessRule{
+		"devnet/full": {Access: config.AccessPublic},
+	})
+
+	snapshots := &models.NetworkSnapshots{
+		Full:  &models.SnapshotInfo{Block: 200},
+		Light: &models.SnapshotInfo{Block: 150},
+	}
+
+	if filtered := policy.Filter(nil, models.NetworkMainnet, snapshots); filtered.Full != nil || filtered.Light == nil {
+		t.Errorf("Expected only light mainnet snapshots for anonymous callers, got %+v", filtered)
+	}
+	if filtered := policy.Filter(nil, models.NetworkDevnet, snapshots); filtered.Full == nil || filtered.Light == nil {
+		t.Errorf("Expected all devnet snapshots for anonymous callers, got %+v", filtered)
+	}
+}
+
+func TestMiddleware_Principal(t *testing.T) {
+	cfg := &config.Config{
+		APIKeys:      []string{"plain-key"},
+		APIKeyGroups: map[string][]string{"partners": {"partner-key"}},
+		Keys: []config.APIKey{
+			{ID: "dashboard", Owner: "ops", Key: "file-key", Networks: []string{"mainnet"}, Groups: []string{"internal"}},
+			{ID: "old", Key: "expired-key", ExpiresAt: time.Now().Add(-time.Hour)},
+			{ID: "off", Key: "disabled-key", Disabled: true},
+		},
+	}
+	middleware := NewMiddleware(cfg)
+
+	tests := []struct {
+		name       string
+		authHeader string
+		expectedID string
+		groups     int
+	}{
+		{name: "anonymous", authHeader: ""},
+		{name: "invalid key", authHeader: "Bearer wrong"},
+		{name: "plain key", authHeader: "Bearer plain-key", expectedID: "env-1"},
+		{name: "group key", authHeader: "Bearer partner-key", expectedID: "group-partners", groups: 1},
+		{name: "file key", authHeader: "Bearer file-key", expectedID: "dashboard", groups: 1},
+		{name: "expired key", authHeader: "Bearer expired-key"},
+		{name: "disabled key", authHeader: "Bearer disabled-key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			principal := middleware.Principal(req)
+			if tt.expectedID == "" {
+				if principal != nil {
+					t.Errorf("Expected anonymous caller, got %+v", principal)
+				}
+				return
+			}
+			if principal == nil || principal.ID != tt.expectedID || len(principal.Groups) != tt.groups {
+				t.Errorf("Principal() = %+v, expected id %s with %d groups", principal, tt.expectedID, tt.groups)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Authenticate(t *testing.T) {
+	cfg := &config.Config{Keys: []config.APIKey{{ID: "dashboard", Owner: "ops", Key: "file-key"}}}
+	middleware := NewMiddleware(cfg)
+
+	var principal *Principal
+	var resolved bool
+	handler := middleware.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, resolved = PrincipalFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer file-key")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !resolved || principal == nil || principal.ID != "dashboard" || principal.Owner != "ops" {
+		t.Errorf("Expected dashboard principal in context, got %+v", principal)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !resolved || principal != nil {
+		t.Errorf("Expected resolved anonymous caller, got %+v (resolved %v)", principal, resolved)
+	}
+}
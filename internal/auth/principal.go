@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// Principal is the API key behind an authenticated request
+type Principal struct {
+	ID    string
+	Owner string
+	// Networks and Types limit the key to these networks and snapshot types; empty allows all
+	Networks []string
+	Types    []string
+	Groups   []string
+	// Tier is the rate limit tier of the key
+	Tier      string
+	ExpiresAt time.Time
+}
+
+// newPrincipal builds the principal for a resolved key, leaving out the secret
+func newPrincipal(key *config.APIKey) *Principal {
+	return &Principal{
+		ID:        key.ID,
+		Owner:     key.Owner,
+		Networks:  key.Networks,
+		Types:     key.Types,
+		Groups:    key.Groups,
+		Tier:      key.Tier,
+		ExpiresAt: key.ExpiresAt,
+	}
+}
+
+// InScope reports whether the key may be used for a network and snapshot type
+func (p *Principal) InScope(network models.Network, snapshotType models.SnapshotType) bool {
+	return (len(p.Networks) == 0 || slices.Contains(p.Networks, string(network))) &&
+		(len(p.Types) == 0 || slices.Contains(p.Types, string(snapshotType)))
+}
+
+// String returns the principal ID for logs, or "anonymous" for nil
+func (p *Principal) String() string {
+	if p == nil {
+		return "anonymous"
+	}
+	return p.ID
+}
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a context carrying the resolved principal; nil marks an anonymous request
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal attached by Middleware.Authenticate.
+// ok is false if the request was never resolved.
+func PrincipalFromContext(ctx context.Context) (principal *Principal, ok bool) {
+	principal, ok = ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok
+}
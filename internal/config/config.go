@@ -1,42 +1,323 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
 )
 
 // Config holds application configuration
 type Config struct {
-	Port          int
-	GCPBucketName string
-	GCPBucketURL  string
-	APIKeys       []string
+	// ConfigFile is the KEY=value file that overrides environment variables
+	ConfigFile string
+	// ConfigWatchInterval is how often ConfigFile and APIKeysFile are checked for
+	// changes; zero disables watching
+	ConfigWatchInterval time.Duration
+	Port                int
+	StorageBackend      string
+	StoragePublicURL    string
+	StoragePrefix       string
+	StorageMaxPages     int
+	GCPBucketName       string
+	GCPBucketURL        string
+	S3Endpoint          string
+	S3Bucket            string
+	S3Region            string
+	S3AccessKeyID       string
+	S3SecretAccessKey   string
+	LocalDir            string
+	RefreshInterval     time.Duration
+	StaleAfter          time.Duration
+	HistoryDepth        int
+	MetricsEnabled      bool
+	// FreshnessThresholds are keyed by "network/type"; "*" matches any network
+	FreshnessThresholds map[string]FreshnessThreshold
+	AlertWebhookURL     string
+	AlertSlackURL       string
+	WebhookURLs         []string
+	WebhookSecret       string
+	WebhookMaxAttempts  int
+	WebhookDeadLetter   string
+	APIKeys             []string
+	// APIKeysFile is the YAML or JSON file Keys were loaded from
+	APIKeysFile string
+	Keys        []APIKey
+	// APIKeyGroups maps a group name to the API keys in it
+	APIKeyGroups map[string][]string
+	// AccessPolicy is keyed by "network/type"; "*" matches any network
+	AccessPolicy map[string]AccessRule
+	AdminAPIKeys []string
+	// KeyStoreFile is the BoltDB file of keys managed through the admin API
+	KeyStoreFile string
+	// RateLimitAnonymous applies per client IP to requests without a valid API key
+	RateLimitEnabled   bool
+	RateLimitAnonymous RateLimit
+	// RateLimitTiers are keyed by the tier of an API key; keys without a tier use DefaultTier
+	RateLimitTiers map[string]RateLimit
+	// TrustedProxies are the IPs and CIDRs allowed to set X-Forwarded-For
+	TrustedProxies []string
+	// UsageStoreFile is the BoltDB file per-key usage is persisted to; empty keeps usage in memory
+	UsageStoreFile     string
+	UsageFlushInterval time.Duration
+	// JWTJWKS is the file or URL of the identity provider keys JWT bearer tokens are
+	// validated against; empty accepts API keys only
+	JWTJWKS        string
+	JWTIssuer      string
+	JWTAudience    string
+	JWTClaims      JWTClaims
+	JWTJWKSRefresh time.Duration
+	// URLSigningKeyFile is the service account or HMAC key used to sign download URLs
+	// of snapshot types that are not public; empty returns plain URLs
+	URLSigningKeyFile string
+	URLSigningExpiry  time.Duration
+	// DownloadProxyEnabled serves snapshots through /v1/download for clients that
+	// cannot reach the bucket
+	DownloadProxyEnabled bool
+	// DownloadBandwidth limits proxied downloads per API key or client IP, in bytes
+	// per second; 0 is unlimited
+	DownloadBandwidth int64
+	// Mirrors host copies of the snapshots; their URLs are listed alongside the
+	// bucket URL
+	Mirrors []models.Mirror
+}
+
+// JWTClaims names the JWT claims mapped to the permissions of an API key. Each
+// claim may hold a JSON array or a space-separated string.
+type JWTClaims struct {
+	Networks string
+	Types    string
+	Groups   string
+	Tier     string
+}
+
+// DefaultTier is the rate limit tier of API keys that do not name one
+const DefaultTier = "default"
+
+// RateLimit allows Requests per Period with bursts of up to Requests. Unlimited
+// lifts the limit entirely.
+type RateLimit struct {
+	Requests  int
+	Period    time.Duration
+	Unlimited bool
+}
+
+// Access levels of an AccessRule
+const (
+	AccessPublic        = "public"
+	AccessAuthenticated = "authenticated"
+	AccessGroups        = "groups"
+)
+
+// AccessRule defines who may see a snapshot stream
+type AccessRule struct {
+	Access string
+	// Groups lists the key groups allowed when Access is AccessGroups
+	Groups []string
 }
 
-// Load loads configuration from environment variables with defaults
+// FreshnessThreshold defines when a snapshot stream is considered stale
+type FreshnessThreshold struct {
+	MaxAge        time.Duration
+	MaxBlockStall time.Duration
+}
+
+// Load loads configuration from environment variables with defaults. Invalid
+// values are logged and ignored.
 func Load() *Config {
+	cfg, errs := load()
+	for _, err := range errs {
+		log.Printf("Ignoring %v", err)
+	}
+	return cfg
+}
+
+// LoadStrict loads configuration like Load but fails on invalid values, so a
+// reload never replaces a working configuration with a broken one
+func LoadStrict() (*Config, error) {
+	cfg, errs := load()
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// load reads the environment, overridden by CONFIG_FILE when set, and returns the
+// configuration together with every invalid value it skipped
+func load() (*Config, []error) {
+	var errs []error
+
+	getenv := os.Getenv
+	configFile := os.Getenv("CONFIG_FILE")
+	if configFile != "" {
+		values, err := readConfigFile(configFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CONFIG_FILE: %w", err))
+		} else {
+			getenv = func(name string) string {
+				if value, ok := values[name]; ok {
+					return value
+				}
+				return os.Getenv(name)
+			}
+		}
+	}
+
 	cfg := &Config{
-		Port:          8080,
-		GCPBucketName: "taraxa-snapshot",
-		GCPBucketURL:  "https://storage.googleapis.com/storage/v1/b/taraxa-snapshot/o",
+		ConfigFile:          configFile,
+		ConfigWatchInterval: 10 * time.Second,
+		Port:                8080,
+		StorageBackend:      "gcs",
+		StorageMaxPages:     100,
+		GCPBucketName:       "taraxa-snapshot",
+		GCPBucketURL:        "https://storage.googleapis.com/storage/v1/b/taraxa-snapshot/o",
+		S3Region:            "us-east-1",
+		RefreshInterval:     5 * time.Minute,
+		StaleAfter:          10 * time.Minute,
+		HistoryDepth:        3,
+		MetricsEnabled:      true,
+		WebhookMaxAttempts:  5,
+		RateLimitAnonymous:  RateLimit{Requests: 120, Period: time.Minute},
+		RateLimitTiers:      map[string]RateLimit{DefaultTier: {Requests: 1200, Period: time.Minute}},
+		UsageFlushInterval:  time.Minute,
+		JWTClaims:           JWTClaims{Networks: "networks", Types: "types", Groups: "groups", Tier: "tier"},
+		JWTJWKSRefresh:      15 * time.Minute,
+		URLSigningExpiry:    time.Hour,
+	}
+
+	if watchInterval := getenv("CONFIG_WATCH_INTERVAL"); watchInterval != "" {
+		if d, err := time.ParseDuration(watchInterval); err == nil && d >= 0 {
+			cfg.ConfigWatchInterval = d
+		} else {
+			errs = append(errs, fmt.Errorf("CONFIG_WATCH_INTERVAL: invalid duration %q", watchInterval))
+		}
 	}
 
-	if port := os.Getenv("PORT"); port != "" {
-		if p, err := strconv.Atoi(port); err == nil {
+	if port := getenv("PORT"); port != "" {
+		if p, err := strconv.Atoi(port); err == nil && p > 0 && p <= 65535 {
 			cfg.Port = p
+		} else {
+			errs = append(errs, fmt.Errorf("PORT: invalid port %q", port))
+		}
+	}
+
+	if backend := getenv("STORAGE_BACKEND"); backend != "" {
+		cfg.StorageBackend = strings.ToLower(backend)
+	}
+
+	if publicURL := getenv("STORAGE_PUBLIC_URL"); publicURL != "" {
+		cfg.StoragePublicURL = publicURL
+	}
+
+	// GCP_PREFIX and GCP_MAX_PAGES are the names these settings had when only GCS was supported
+	if prefix := getenvFallback(getenv, "STORAGE_PREFIX", "GCP_PREFIX"); prefix != "" {
+		cfg.StoragePrefix = prefix
+	}
+
+	if maxPages := getenvFallback(getenv, "STORAGE_MAX_PAGES", "GCP_MAX_PAGES"); maxPages != "" {
+		if p, err := strconv.Atoi(maxPages); err == nil && p > 0 {
+			cfg.StorageMaxPages = p
+		} else {
+			errs = append(errs, fmt.Errorf("STORAGE_MAX_PAGES: %q is not a positive integer", maxPages))
 		}
 	}
 
-	if bucketName := os.Getenv("GCP_BUCKET_NAME"); bucketName != "" {
+	if bucketName := getenv("GCP_BUCKET_NAME"); bucketName != "" {
 		cfg.GCPBucketName = bucketName
 	}
 
-	if bucketURL := os.Getenv("GCP_BUCKET_URL"); bucketURL != "" {
+	if bucketURL := getenv("GCP_BUCKET_URL"); bucketURL != "" {
 		cfg.GCPBucketURL = bucketURL
 	}
 
-	if apiKeys := os.Getenv("API_KEYS"); apiKeys != "" {
+	if endpoint := getenv("S3_ENDPOINT"); endpoint != "" {
+		cfg.S3Endpoint = endpoint
+	}
+
+	if bucket := getenv("S3_BUCKET"); bucket != "" {
+		cfg.S3Bucket = bucket
+	}
+
+	if region := getenv("S3_REGION"); region != "" {
+		cfg.S3Region = region
+	}
+
+	cfg.S3AccessKeyID = getenv("S3_ACCESS_KEY_ID")
+	cfg.S3SecretAccessKey = getenv("S3_SECRET_ACCESS_KEY")
+
+	if localDir := getenv("LOCAL_DIR"); localDir != "" {
+		cfg.LocalDir = localDir
+	}
+
+	if interval := getenv("REFRESH_INTERVAL"); interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil && d > 0 {
+			cfg.RefreshInterval = d
+		} else {
+			errs = append(errs, fmt.Errorf("REFRESH_INTERVAL: invalid duration %q", interval))
+		}
+	}
+
+	if staleAfter := getenv("STALE_AFTER"); staleAfter != "" {
+		if d, err := time.ParseDuration(staleAfter); err == nil && d > 0 {
+			cfg.StaleAfter = d
+		} else {
+			errs = append(errs, fmt.Errorf("STALE_AFTER: invalid duration %q", staleAfter))
+		}
+	}
+
+	if depth := getenv("HISTORY_DEPTH"); depth != "" {
+		if d, err := strconv.Atoi(depth); err == nil && d >= 0 {
+			cfg.HistoryDepth = d
+		} else {
+			errs = append(errs, fmt.Errorf("HISTORY_DEPTH: %q is not a non-negative integer", depth))
+		}
+	}
+
+	if metricsEnabled := getenv("METRICS_ENABLED"); metricsEnabled != "" {
+		if enabled, err := strconv.ParseBool(metricsEnabled); err == nil {
+			cfg.MetricsEnabled = enabled
+		} else {
+			errs = append(errs, fmt.Errorf("METRICS_ENABLED: invalid boolean %q", metricsEnabled))
+		}
+	}
+
+	if thresholds := getenv("FRESHNESS_THRESHOLDS"); thresholds != "" {
+		parsed, err := ParseFreshnessThresholds(thresholds)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("FRESHNESS_THRESHOLDS: %w", err))
+		} else {
+			cfg.FreshnessThresholds = parsed
+		}
+	}
+
+	cfg.AlertWebhookURL = getenv("ALERT_WEBHOOK_URL")
+	cfg.AlertSlackURL = getenv("ALERT_SLACK_WEBHOOK_URL")
+
+	if webhookURLs := getenv("WEBHOOK_URLS"); webhookURLs != "" {
+		cfg.WebhookURLs = splitList(webhookURLs)
+	}
+
+	cfg.WebhookSecret = getenv("WEBHOOK_SECRET")
+
+	if attempts := getenv("WEBHOOK_MAX_ATTEMPTS"); attempts != "" {
+		if a, err := strconv.Atoi(attempts); err == nil && a > 0 {
+			cfg.WebhookMaxAttempts = a
+		} else {
+			errs = append(errs, fmt.Errorf("WEBHOOK_MAX_ATTEMPTS: %q is not a positive integer", attempts))
+		}
+	}
+
+	cfg.WebhookDeadLetter = getenv("WEBHOOK_DEAD_LETTER_FILE")
+
+	if apiKeys := getenv("API_KEYS"); apiKeys != "" {
 		cfg.APIKeys = strings.Split(apiKeys, ",")
 		// Trim whitespace from each key
 		for i, key := range cfg.APIKeys {
@@ -44,19 +325,480 @@ func Load() *Config {
 		}
 	}
 
-	return cfg
+	if keysFile := getenv("API_KEYS_FILE"); keysFile != "" {
+		cfg.APIKeysFile = keysFile
+		keys, err := LoadKeysFile(keysFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("API_KEYS_FILE: %w", err))
+		} else {
+			cfg.Keys = keys
+		}
+	}
+
+	if groups := getenv("API_KEY_GROUPS"); groups != "" {
+		parsed, err := ParseAPIKeyGroups(groups)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("API_KEY_GROUPS: %w", err))
+		} else {
+			cfg.APIKeyGroups = parsed
+		}
+	}
+
+	if policy := getenv("ACCESS_POLICY"); policy != "" {
+		parsed, err := ParseAccessPolicy(policy)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ACCESS_POLICY: %w", err))
+		} else {
+			cfg.AccessPolicy = parsed
+		}
+	}
+
+	if adminKeys := getenv("ADMIN_API_KEYS"); adminKeys != "" {
+		cfg.AdminAPIKeys = splitList(adminKeys)
+	}
+
+	cfg.KeyStoreFile = getenv("KEY_STORE_FILE")
+
+	if rateLimitEnabled := getenv("RATE_LIMIT_ENABLED"); rateLimitEnabled != "" {
+		if enabled, err := strconv.ParseBool(rateLimitEnabled); err == nil {
+			cfg.RateLimitEnabled = enabled
+		} else {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_ENABLED: invalid boolean %q", rateLimitEnabled))
+		}
+	}
+
+	if anonymous := getenv("RATE_LIMIT_ANONYMOUS"); anonymous != "" {
+		parsed, err := ParseRateLimit(anonymous)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_ANONYMOUS: %w", err))
+		} else {
+			cfg.RateLimitAnonymous = parsed
+		}
+	}
+
+	if tiers := getenv("RATE_LIMIT_TIERS"); tiers != "" {
+		parsed, err := ParseRateLimitTiers(tiers)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_TIERS: %w", err))
+		} else {
+			for tier, limit := range parsed {
+				cfg.RateLimitTiers[tier] = limit
+			}
+		}
+	}
+
+	if proxies := getenv("TRUSTED_PROXIES"); proxies != "" {
+		parsed := splitList(proxies)
+		if err := validateProxies(parsed); err != nil {
+			errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %w", err))
+		} else {
+			cfg.TrustedProxies = parsed
+		}
+	}
+
+	cfg.UsageStoreFile = getenv("USAGE_STORE_FILE")
+
+	if flushInterval := getenv("USAGE_FLUSH_INTERVAL"); flushInterval != "" {
+		if d, err := time.ParseDuration(flushInterval); err == nil && d > 0 {
+			cfg.UsageFlushInterval = d
+		} else {
+			errs = append(errs, fmt.Errorf("USAGE_FLUSH_INTERVAL: invalid duration %q", flushInterval))
+		}
+	}
+
+	cfg.JWTJWKS = getenv("JWT_JWKS")
+	cfg.JWTIssuer = getenv("JWT_ISSUER")
+	cfg.JWTAudience = getenv("JWT_AUDIENCE")
+	if cfg.JWTJWKS != "" && (cfg.JWTIssuer == "" || cfg.JWTAudience == "") {
+		errs = append(errs, errors.New("JWT_JWKS: JWT_ISSUER and JWT_AUDIENCE are required"))
+		cfg.JWTJWKS = ""
+	}
+
+	if claims := getenv("JWT_CLAIMS"); claims != "" {
+		if err := cfg.JWTClaims.parse(claims); err != nil {
+			errs = append(errs, fmt.Errorf("JWT_CLAIMS: %w", err))
+		}
+	}
+
+	if refresh := getenv("JWT_JWKS_REFRESH"); refresh != "" {
+		if d, err := time.ParseDuration(refresh); err == nil && d > 0 {
+			cfg.JWTJWKSRefresh = d
+		} else {
+			errs = append(errs, fmt.Errorf("JWT_JWKS_REFRESH: invalid duration %q", refresh))
+		}
+	}
+
+	cfg.URLSigningKeyFile = getenv("URL_SIGNING_KEY_FILE")
+
+	// Responses may be cached for five minutes, so URLs must outlive them; GCS
+	// rejects signed URLs valid for more than seven days
+	if expiry := getenv("URL_SIGNING_EXPIRY"); expiry != "" {
+		if d, err := time.ParseDuration(expiry); err == nil && d >= 10*time.Minute && d <= 7*24*time.Hour {
+			cfg.URLSigningExpiry = d
+		} else {
+			errs = append(errs, fmt.Errorf("URL_SIGNING_EXPIRY: %q must be a duration between 10m and 168h", expiry))
+		}
+	}
+
+	if proxyEnabled := getenv("DOWNLOAD_PROXY_ENABLED"); proxyEnabled != "" {
+		if enabled, err := strconv.ParseBool(proxyEnabled); err == nil {
+			cfg.DownloadProxyEnabled = enabled
+		} else {
+			errs = append(errs, fmt.Errorf("DOWNLOAD_PROXY_ENABLED: invalid boolean %q", proxyEnabled))
+		}
+	}
+
+	if bandwidth := getenv("DOWNLOAD_BANDWIDTH"); bandwidth != "" {
+		parsed, err := ParseBandwidth(bandwidth)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("DOWNLOAD_BANDWIDTH: %w", err))
+		} else {
+			cfg.DownloadBandwidth = parsed
+		}
+	}
+
+	if mirrors := getenv("MIRRORS"); mirrors != "" {
+		parsed, err := ParseMirrors(mirrors)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("MIRRORS: %w", err))
+		} else {
+			cfg.Mirrors = parsed
+		}
+	}
+
+	for _, key := range cfg.Keys {
+		if key.Tier != "" && !cfg.hasTier(key.Tier) {
+			errs = append(errs, fmt.Errorf("API_KEYS_FILE: key %s uses unknown rate limit tier %s", key.ID, key.Tier))
+		}
+	}
+
+	return cfg, errs
+}
+
+// getenvFallback returns the value of name, or of the deprecated alias when name is unset
+func getenvFallback(getenv func(string) string, name, alias string) string {
+	if value := getenv(name); value != "" {
+		return value
+	}
+	return getenv(alias)
+}
+
+// parse overrides the claim names from "permission=claim" entries, e.g.
+// "networks=snapshot_networks,groups=roles"
+func (c *JWTClaims) parse(value string) error {
+	parsed := *c
+	for _, entry := range splitList(value) {
+		permission, claim, ok := strings.Cut(entry, "=")
+		permission, claim = strings.TrimSpace(permission), strings.TrimSpace(claim)
+		if !ok || claim == "" {
+			return fmt.Errorf("invalid entry %q: expected permission=claim", entry)
+		}
+		switch permission {
+		case "networks":
+			parsed.Networks = claim
+		case "types":
+			parsed.Types = claim
+		case "groups":
+			parsed.Groups = claim
+		case "tier":
+			parsed.Tier = claim
+		default:
+			return fmt.Errorf("unknown permission %q: expected networks, types, groups or tier", permission)
+		}
+	}
+	*c = parsed
+	return nil
+}
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ParseFreshnessThresholds parses a comma-separated list of
+// "network/type=maxAge[:maxBlockStall]" entries, e.g. "mainnet/full=26h:2h,*/light=2h"
+func ParseFreshnessThresholds(value string) (map[string]FreshnessThreshold, error) {
+	thresholds := make(map[string]FreshnessThreshold)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		stream, limits, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q: expected network/type=duration", entry)
+		}
+		network, snapshotType, ok := strings.Cut(strings.TrimSpace(stream), "/")
+		if !ok || network == "" || snapshotType == "" {
+			return nil, fmt.Errorf("invalid stream %q: expected network/type", stream)
+		}
+
+		maxAge, maxBlockStall, _ := strings.Cut(strings.TrimSpace(limits), ":")
+		var threshold FreshnessThreshold
+		var err error
+		if threshold.MaxAge, err = time.ParseDuration(maxAge); err != nil || threshold.MaxAge <= 0 {
+			return nil, fmt.Errorf("invalid max age %q for %s", maxAge, stream)
+		}
+		if maxBlockStall != "" {
+			if threshold.MaxBlockStall, err = time.ParseDuration(maxBlockStall); err != nil || threshold.MaxBlockStall <= 0 {
+				return nil, fmt.Errorf("invalid max block stall %q for %s", maxBlockStall, stream)
+			}
+		}
+
+		thresholds[strings.ToLower(network)+"/"+strings.ToLower(snapshotType)] = threshold
+	}
+
+	return thresholds, nil
+}
+
+// ParseRateLimit parses "requests/period" where period is s, m, h or a duration,
+// e.g. "60/m" or "10/30s", or "unlimited"
+func ParseRateLimit(value string) (RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "unlimited" {
+		return RateLimit{Unlimited: true}, nil
+	}
+
+	requests, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: expected requests/period or unlimited", value)
+	}
+
+	var limit RateLimit
+	var err error
+	if limit.Requests, err = strconv.Atoi(strings.TrimSpace(requests)); err != nil || limit.Requests <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid request count %q in %q", requests, value)
+	}
+
+	switch period = strings.TrimSpace(period); period {
+	case "s":
+		limit.Period = time.Second
+	case "m":
+		limit.Period = time.Minute
+	case "h":
+		limit.Period = time.Hour
+	default:
+		if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+			return RateLimit{}, fmt.Errorf("invalid period %q in %q", period, value)
+		}
+	}
+
+	return limit, nil
+}
+
+// byteUnits are the units accepted by ParseBandwidth
+var byteUnits = map[string]int64{
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+}
+
+// ParseBandwidth parses a rate in bytes per second such as "50MB", "20MiB/s" or
+// "unlimited", which returns 0
+func ParseBandwidth(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "unlimited" {
+		return 0, nil
+	}
+
+	size := strings.TrimSuffix(value, "/s")
+	split := strings.IndexFunc(size, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if split <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q: expected a size like 50MB or unlimited", value)
+	}
+
+	number, err := strconv.ParseFloat(size[:split], 64)
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(size[split:]))]
+	if err != nil || !ok || number <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q: expected a size like 50MB or unlimited", value)
+	}
+	return int64(number * float64(unit)), nil
 }
 
-// IsValidAPIKey checks if the provided API key is valid
+// mirrorName restricts mirror names to what fits in a query parameter unescaped
+var mirrorName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ParseMirrors parses a comma-separated list of "name=region|baseURL[|weight]"
+// entries, e.g. "sg=asia|https://sg.example.com/snapshots|2,fra=europe|https://fra.example.com".
+// The region may be empty and the weight defaults to 1; a weight of 0 keeps a
+// mirror out of region-based selection.
+func ParseMirrors(value string) ([]models.Mirror, error) {
+	var mirrors []models.Mirror
+	seen := make(map[string]bool)
+
+	for _, entry := range splitList(value) {
+		name, rest, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		fields := strings.Split(rest, "|")
+		if !ok || len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("invalid entry %q: expected name=region|baseURL[|weight]", entry)
+		}
+		if !mirrorName.MatchString(name) {
+			return nil, fmt.Errorf("invalid mirror name %q: expected lowercase letters, digits and dashes", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate mirror %s", name)
+		}
+		seen[name] = true
+
+		mirror := models.Mirror{
+			Name:    name,
+			Region:  strings.ToLower(strings.TrimSpace(fields[0])),
+			BaseURL: strings.TrimSuffix(strings.TrimSpace(fields[1]), "/"),
+			Weight:  1,
+		}
+		if parsed, err := url.Parse(mirror.BaseURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("mirror %s: invalid base URL %q", name, mirror.BaseURL)
+		}
+		if len(fields) == 3 {
+			weight, err := strconv.Atoi(strings.TrimSpace(fields[2]))
+			if err != nil || weight < 0 {
+				return nil, fmt.Errorf("mirror %s: invalid weight %q", name, fields[2])
+			}
+			mirror.Weight = weight
+		}
+		mirrors = append(mirrors, mirror)
+	}
+
+	return mirrors, nil
+}
+
+// ParseRateLimitTiers parses a comma-separated list of "tier=limit" entries,
+// e.g. "default=600/m,partner=6000/m,internal=unlimited"
+func ParseRateLimitTiers(value string) (map[string]RateLimit, error) {
+	tiers := make(map[string]RateLimit)
+
+	for _, entry := range splitList(value) {
+		tier, limit, ok := strings.Cut(entry, "=")
+		tier = strings.TrimSpace(tier)
+		if !ok || tier == "" {
+			return nil, fmt.Errorf("invalid entry %q: expected tier=requests/period", entry)
+		}
+		parsed, err := ParseRateLimit(limit)
+		if err != nil {
+			return nil, fmt.Errorf("tier %s: %w", tier, err)
+		}
+		tiers[tier] = parsed
+	}
+
+	return tiers, nil
+}
+
+// validateProxies checks that every trusted proxy is an IP address or CIDR
+func validateProxies(proxies []string) error {
+	for _, proxy := range proxies {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			return fmt.Errorf("invalid proxy %q: expected an IP address or CIDR", proxy)
+		}
+	}
+	return nil
+}
+
+func (c *Config) hasTier(tier string) bool {
+	_, ok := c.RateLimitTiers[tier]
+	return ok
+}
+
+// ParseAPIKeyGroups parses a comma-separated list of "group:key1|key2" entries
+func ParseAPIKeyGroups(value string) (map[string][]string, error) {
+	groups := make(map[string][]string)
+
+	for _, entry := range splitList(value) {
+		group, keys, ok := strings.Cut(entry, ":")
+		group = strings.TrimSpace(group)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid entry %q: expected group:key1|key2", entry)
+		}
+		for _, key := range strings.Split(keys, "|") {
+			if key = strings.TrimSpace(key); key != "" {
+				groups[group] = append(groups[group], key)
+			}
+		}
+	}
+
+	return groups, nil
+}
+
+// ParseAccessPolicy parses a comma-separated list of "network/type=rule" entries where
+// rule is "public", "authenticated" or "group:name1|name2",
+// e.g. "devnet/full=public,mainnet/full=group:partners"
+func ParseAccessPolicy(value string) (map[string]AccessRule, error) {
+	policy := make(map[string]AccessRule)
+
+	for _, entry := range splitList(value) {
+		stream, rule, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q: expected network/type=rule", entry)
+		}
+		network, snapshotType, ok := strings.Cut(strings.TrimSpace(stream), "/")
+		if !ok || network == "" || snapshotType == "" {
+			return nil, fmt.Errorf("invalid stream %q: expected network/type", stream)
+		}
+
+		var parsed AccessRule
+		switch rule = strings.TrimSpace(rule); {
+		case rule == AccessPublic, rule == AccessAuthenticated:
+			parsed.Access = rule
+		case strings.HasPrefix(rule, "group:"):
+			parsed.Access = AccessGroups
+			for _, group := range strings.Split(strings.TrimPrefix(rule, "group:"), "|") {
+				if group = strings.TrimSpace(group); group != "" {
+					parsed.Groups = append(parsed.Groups, group)
+				}
+			}
+			if len(parsed.Groups) == 0 {
+				return nil, fmt.Errorf("rule %q for %s lists no groups", rule, stream)
+			}
+		default:
+			return nil, fmt.Errorf("invalid rule %q for %s: expected public, authenticated or group:name", rule, stream)
+		}
+
+		policy[strings.ToLower(network)+"/"+strings.ToLower(snapshotType)] = parsed
+	}
+
+	return policy, nil
+}
+
+// IsValidAPIKey checks if the provided API key is valid. It checks every key in
+// constant time; request handling uses the indexed auth.Keyring instead.
 func (c *Config) IsValidAPIKey(apiKey string) bool {
-	if len(c.APIKeys) == 0 {
+	if apiKey == "" {
 		return false
 	}
 
-	for _, key := range c.APIKeys {
-		if key == apiKey && key != "" {
-			return true
+	now := time.Now()
+	valid := false
+	for _, key := range c.AllKeys() {
+		if key.Matches(apiKey) && key.Active(now) {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// IsValidAdminKey checks if the provided key grants access to the admin endpoints
+func (c *Config) IsValidAdminKey(apiKey string) bool {
+	valid := false
+	for _, entry := range c.AdminAPIKeys {
+		key := envKey("admin", entry, nil)
+		if key.Matches(apiKey) && apiKey != "" {
+			valid = true
 		}
 	}
-	return false
+	return valid
 }
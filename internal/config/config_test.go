@@ -0,0 +1,267 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected RateLimit
+		err      bool
+	}{
+		{value: "60/m", expected: RateLimit{Requests: 60, Period: time.Minute}},
+		{value: "10/s", expected: RateLimit{Requests: 10, Period: time.Second}},
+		{value: " 1000 / h ", expected: RateLimit{Requests: 1000, Period: time.Hour}},
+		{value: "5/30s", expected: RateLimit{Requests: 5, Period: 30 * time.Second}},
+		{value: "unlimited", expected: RateLimit{Unlimited: true}},
+		{value: "60", err: true},
+		{value: "0/m", err: true},
+		{value: "ten/m", err: true},
+		{value: "60/fortnight", err: true},
+		{value: "60/-1s", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			limit, err := ParseRateLimit(tt.value)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if limit != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, limit)
+			}
+		})
+	}
+}
+
+func TestParseRateLimitTiers(t *testing.T) {
+	tiers, err := ParseRateLimitTiers("default=600/m, partner=6000/m,internal=unlimited")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tiers) != 3 || tiers["partner"].Requests != 6000 || !tiers["internal"].Unlimited {
+		t.Errorf("Unexpected tiers: %+v", tiers)
+	}
+
+	for _, value := range []string{"=60/m", "partner", "partner=fast"} {
+		if _, err := ParseRateLimitTiers(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestLoad_StorageAliases(t *testing.T) {
+	t.Setenv("GCP_PREFIX", "snapshots/")
+	t.Setenv("GCP_MAX_PAGES", "5")
+
+	cfg := Load()
+	if cfg.StoragePrefix != "snapshots/" || cfg.StorageMaxPages != 5 {
+		t.Errorf("Expected the GCP_ aliases to apply, got prefix %q and max pages %d", cfg.StoragePrefix, cfg.StorageMaxPages)
+	}
+
+	t.Setenv("STORAGE_PREFIX", "other/")
+	if cfg := Load(); cfg.StoragePrefix != "other/" {
+		t.Errorf("Expected STORAGE_PREFIX to win over GCP_PREFIX, got %q", cfg.StoragePrefix)
+	}
+}
+
+func TestLoadStrict_InvalidValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "PORT", value: "http"},
+		{name: "PORT", value: "70000"},
+		{name: "STORAGE_MAX_PAGES", value: "0"},
+		{name: "GCP_MAX_PAGES", value: "many"},
+		{name: "HISTORY_DEPTH", value: "-1"},
+		{name: "METRICS_ENABLED", value: "yes please"},
+		{name: "RATE_LIMIT_ENABLED", value: "on"},
+		{name: "DOWNLOAD_PROXY_ENABLED", value: "maybe"},
+		{name: "WEBHOOK_MAX_ATTEMPTS", value: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+			expected := strings.Replace(tt.name, "GCP_", "STORAGE_", 1)
+			if _, err := LoadStrict(); err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("Expected %s error, got %v", expected, err)
+			}
+		})
+	}
+}
+
+func TestLoad_RateLimit(t *testing.T) {
+	// Off by default: behind a proxy without TRUSTED_PROXIES every client would share one bucket
+	if cfg := Load(); cfg.RateLimitEnabled {
+		t.Error("Expected rate limiting to be disabled by default")
+	}
+
+	t.Setenv("RATE_LIMIT_ENABLED", "true")
+	t.Setenv("RATE_LIMIT_ANONYMOUS", "30/m")
+	t.Setenv("RATE_LIMIT_TIERS", "partner=6000/m")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.1")
+
+	cfg, err := LoadStrict()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !cfg.RateLimitEnabled || cfg.RateLimitAnonymous.Requests != 30 {
+		t.Errorf("Unexpected anonymous limit: %+v", cfg.RateLimitAnonymous)
+	}
+	if cfg.RateLimitTiers[DefaultTier].Requests != 1200 || cfg.RateLimitTiers["partner"].Requests != 6000 {
+		t.Errorf("Expected the default tier to be kept next to partner, got %+v", cfg.RateLimitTiers)
+	}
+	if len(cfg.TrustedProxies) != 2 {
+		t.Errorf("Unexpected trusted proxies: %v", cfg.TrustedProxies)
+	}
+
+	t.Setenv("TRUSTED_PROXIES", "proxy.local")
+	if _, err := LoadStrict(); err == nil || !strings.Contains(err.Error(), "TRUSTED_PROXIES") {
+		t.Errorf("Expected TRUSTED_PROXIES error, got %v", err)
+	}
+
+	t.Setenv("TRUSTED_PROXIES", "")
+	t.Setenv("API_KEYS_FILE", writeKeysFile(t, "keys.yaml", "keys:\n  - {id: a, key: one, tier: gold}"))
+	if _, err := LoadStrict(); err == nil || !strings.Contains(err.Error(), "unknown rate limit tier gold") {
+		t.Errorf("Expected unknown tier error, got %v", err)
+	}
+}
+
+func TestLoad_JWT(t *testing.T) {
+	t.Setenv("JWT_JWKS", "https://idp.example.com/.well-known/jwks.json")
+	t.Setenv("JWT_ISSUER", "https://idp.example.com/")
+	t.Setenv("JWT_AUDIENCE", "snapshots-api")
+	t.Setenv("JWT_CLAIMS", "networks=snapshot_networks, groups=roles")
+
+	cfg, err := LoadStrict()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := JWTClaims{Networks: "snapshot_networks", Types: "types", Groups: "roles", Tier: "tier"}
+	if cfg.JWTClaims != expected {
+		t.Errorf("Expected claims %+v, got %+v", expected, cfg.JWTClaims)
+	}
+	if cfg.JWTJWKSRefresh != 15*time.Minute {
+		t.Errorf("Expected default refresh of 15m, got %v", cfg.JWTJWKSRefresh)
+	}
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{name: "missing audience", env: map[string]string{"JWT_AUDIENCE": ""}, expected: "JWT_ISSUER and JWT_AUDIENCE are required"},
+		{name: "unknown permission", env: map[string]string{"JWT_CLAIMS": "owner=email"}, expected: "unknown permission"},
+		{name: "malformed claims", env: map[string]string{"JWT_CLAIMS": "networks"}, expected: "expected permission=claim"},
+		{name: "invalid refresh", env: map[string]string{"JWT_JWKS_REFRESH": "soon"}, expected: "JWT_JWKS_REFRESH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if _, err := LoadStrict(); err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestLoad_URLSigningExpiry(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		valid    bool
+	}{
+		{value: "", expected: time.Hour, valid: true},
+		{value: "30m", expected: 30 * time.Minute, valid: true},
+		{value: "168h", expected: 168 * time.Hour, valid: true},
+		{value: "5m"},
+		{value: "169h"},
+		{value: "soon"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("URL_SIGNING_EXPIRY", tt.value)
+		cfg, err := LoadStrict()
+		if !tt.valid {
+			if err == nil || !strings.Contains(err.Error(), "URL_SIGNING_EXPIRY") {
+				t.Errorf("%q: expected URL_SIGNING_EXPIRY error, got %v", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.value, err)
+		}
+		if cfg.URLSigningExpiry != tt.expected {
+			t.Errorf("%q: expected %v, got %v", tt.value, tt.expected, cfg.URLSigningExpiry)
+		}
+	}
+}
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+	}{
+		{value: "unlimited", expected: 0},
+		{value: "500B", expected: 500},
+		{value: "50MB", expected: 50_000_000},
+		{value: "20MiB/s", expected: 20 << 20},
+		{value: "1.5 GB", expected: 1_500_000_000},
+		{value: "64kib", expected: 64 << 10},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseBandwidth(tt.value)
+		if err != nil || got != tt.expected {
+			t.Errorf("ParseBandwidth(%q) = %d, %v, expected %d", tt.value, got, err, tt.expected)
+		}
+	}
+
+	for _, value := range []string{"", "50", "MB", "50Mbit", "-5MB", "0MB"} {
+		if _, err := ParseBandwidth(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestParseMirrors(t *testing.T) {
+	mirrors, err := ParseMirrors("sg=Asia|https://sg.example.com/snapshots/|3, fra=europe|https://fra.example.com,edge=|http://edge.example.com|0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []models.Mirror{
+		{Name: "sg", Region: "asia", BaseURL: "https://sg.example.com/snapshots", Weight: 3},
+		{Name: "fra", Region: "europe", BaseURL: "https://fra.example.com", Weight: 1},
+		{Name: "edge", Region: "", BaseURL: "http://edge.example.com", Weight: 0},
+	}
+	if !reflect.DeepEqual(mirrors, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, mirrors)
+	}
+
+	for _, value := range []string{
+		"sg",
+		"sg=asia",
+		"sg=asia|https://sg.example.com|1|2",
+		"SG=asia|https://sg.example.com",
+		"sg=asia|ftp://sg.example.com",
+		"sg=asia|sg.example.com",
+		"sg=asia|https://sg.example.com|-1",
+		"sg=asia|https://sg.example.com,sg=europe|https://fra.example.com",
+	} {
+		if _, err := ParseMirrors(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/keyhash"
+	"gopkg.in/yaml.v3"
+)
+
+// APIKey is an API key with its owner and scopes
+type APIKey struct {
+	ID    string `json:"id" yaml:"id"`
+	Owner string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	// Key is the plaintext key; prefer Hash so secrets never sit in config
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Hash is "sha256:<hex>", a PHC argon2id string or a bcrypt hash of the key
+	Hash string `json:"hash,omitempty" yaml:"hash,omitempty"`
+	// Prefix is the public prefix of a generated key, required for argon2id and bcrypt hashes
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	// Networks and Types restrict the key to the listed networks and snapshot types; empty allows all
+	Networks []string `json:"networks,omitempty" yaml:"networks,omitempty"`
+	Types    []string `json:"types,omitempty" yaml:"types,omitempty"`
+	// Groups are the access policy groups the key belongs to
+	Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	// Tier selects the rate limit from RATE_LIMIT_TIERS; empty uses DefaultTier
+	Tier      string    `json:"tier,omitempty" yaml:"tier,omitempty"`
+	ExpiresAt time.Time `json:"expires-at,omitempty" yaml:"expires-at,omitempty"`
+	Disabled  bool      `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// CreatedAt is set for keys created through the admin API
+	CreatedAt time.Time `json:"created-at,omitempty" yaml:"created-at,omitempty"`
+}
+
+// keysFile is the layout of API_KEYS_FILE
+type keysFile struct {
+	Keys []APIKey `json:"keys" yaml:"keys"`
+}
+
+// Active reports whether the key is enabled and not expired at now
+func (k *APIKey) Active(now time.Time) bool {
+	return !k.Disabled && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// Matches reports whether a presented key is this key, in constant time
+func (k *APIKey) Matches(apiKey string) bool {
+	if k.Hash != "" {
+		return keyhash.Verify(k.Hash, apiKey)
+	}
+	return k.Key != "" && subtle.ConstantTimeCompare([]byte(k.Key), []byte(apiKey)) == 1
+}
+
+// Names reports whether an API_KEY_GROUPS member is this key: its secret, its hash as
+// listed in API_KEYS, or a secret matching the hash. Slow hashes are only verified
+// when the member carries the key's prefix.
+func (k *APIKey) Names(member string) bool {
+	if k.Hash != "" && k.Hash == member {
+		return true
+	}
+	if k.Prefix != "" && keyhash.Prefix(member) != k.Prefix {
+		return false
+	}
+	return k.Matches(member)
+}
+
+// LoadKeysFile reads API keys from a YAML or JSON file, chosen by extension
+// (.json is JSON, anything else YAML)
+func LoadKeysFile(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys file: %w", err)
+	}
+
+	var file keysFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse keys file %s: %w", path, err)
+	}
+
+	if err := validateKeys(file.Keys); err != nil {
+		return nil, fmt.Errorf("invalid keys file %s: %w", path, err)
+	}
+	return file.Keys, nil
+}
+
+// validateKeys checks that every key has a unique ID and exactly one valid secret
+func validateKeys(keys []APIKey) error {
+	ids := make(map[string]bool, len(keys))
+	secrets := make(map[string]bool, len(keys))
+
+	for i, key := range keys {
+		if key.ID == "" {
+			return fmt.Errorf("key %d has no id", i+1)
+		}
+		if ids[key.ID] {
+			return fmt.Errorf("duplicate key id %s", key.ID)
+		}
+		ids[key.ID] = true
+
+		secret := key.Key
+		switch {
+		case key.Key == "" && key.Hash == "":
+			return fmt.Errorf("key %s has no key or hash", key.ID)
+		case key.Key != "" && key.Hash != "":
+			return fmt.Errorf("key %s sets both key and hash", key.ID)
+		case key.Hash != "":
+			algorithm, err := keyhash.Algorithm(key.Hash)
+			if err != nil {
+				return fmt.Errorf("key %s: %w", key.ID, err)
+			}
+			if keyhash.Slow(algorithm) && key.Prefix == "" {
+				return fmt.Errorf("key %s: %s hashes need a prefix", key.ID, algorithm)
+			}
+			secret = key.Hash
+		}
+
+		if secrets[secret] {
+			return fmt.Errorf("key %s reuses the key of another entry", key.ID)
+		}
+		secrets[secret] = true
+	}
+	return nil
+}
+
+// AllKeys returns every configured key: API_KEYS_FILE entries, then API_KEYS, then
+// keys only listed in API_KEY_GROUPS. Environment keys get an ID derived from their
+// position and no scopes; entries of the form sha256:<hex> are hashes.
+func (c *Config) AllKeys() []APIKey {
+	keys := make([]APIKey, 0, len(c.Keys)+len(c.APIKeys))
+	seen := make(map[string]bool)
+
+	for _, key := range c.Keys {
+		secret := key.Key
+		if key.Hash != "" {
+			secret = key.Hash
+		}
+		key.Groups = slices.Clone(key.Groups)
+		keys = append(keys, key)
+		seen[secret] = true
+	}
+
+	for i, entry := range c.APIKeys {
+		if entry == "" || seen[entry] {
+			continue
+		}
+		keys = append(keys, envKey(fmt.Sprintf("env-%d", i+1), entry, nil))
+		seen[entry] = true
+	}
+
+	// Group members are resolved to the key they name, by id or by secret, so a group
+	// listing a key in plaintext applies to its hashed entry too
+	ungrouped := make(map[string][]string)
+	for _, group := range slices.Sorted(maps.Keys(c.APIKeyGroups)) {
+		for _, member := range c.APIKeyGroups[group] {
+			i := slices.IndexFunc(keys, func(key APIKey) bool { return key.ID == member })
+			if i < 0 {
+				i = slices.IndexFunc(keys, func(key APIKey) bool { return key.Names(member) })
+			}
+			if i < 0 {
+				if !slices.Contains(ungrouped[member], group) {
+					ungrouped[member] = append(ungrouped[member], group)
+				}
+				continue
+			}
+			if !slices.Contains(keys[i].Groups, group) {
+				keys[i].Groups = append(keys[i].Groups, group)
+			}
+		}
+	}
+
+	// Keys that only appear in groups, in a stable order
+	for _, entry := range slices.Sorted(maps.Keys(ungrouped)) {
+		groups := ungrouped[entry]
+		keys = append(keys, envKey("group-"+strings.Join(groups, "+"), entry, groups))
+	}
+
+	return keys
+}
+
+// envKey builds the entry for a key from the environment
+func envKey(id, entry string, groups []string) APIKey {
+	if strings.HasPrefix(entry, keyhash.SHA256+":") {
+		return APIKey{ID: id, Hash: entry, Groups: groups}
+	}
+	return APIKey{ID: id, Key: entry, Groups: groups}
+}
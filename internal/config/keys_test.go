@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/keyhash"
+)
+
+func writeKeysFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKeysFile(t *testing.T) {
+	yamlKeys := `
+keys:
+  - id: dashboard
+    owner: ops@taraxa.io
+    key: secret-1
+    networks: [mainnet]
+    types: [light]
+  - id: partner
+    key: secret-2
+    groups: [partners]
+    expires-at: 2030-01-01T00:00:00Z
+    disabled: true
+`
+	jsonKeys := `{"keys": [{"id": "dashboard", "owner": "ops@taraxa.io", "key": "secret-1", "networks": ["mainnet"], "types": ["light"]},
+		{"id": "partner", "key": "secret-2", "groups": ["partners"], "expires-at": "2030-01-01T00:00:00Z", "disabled": true}]}`
+
+	for name, content := range map[string]string{"keys.yaml": yamlKeys, "keys.json": jsonKeys} {
+		t.Run(name, func(t *testing.T) {
+			keys, err := LoadKeysFile(writeKeysFile(t, name, content))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(keys) != 2 {
+				t.Fatalf("Expected 2 keys, got %d", len(keys))
+			}
+			if keys[0].ID != "dashboard" || keys[0].Owner != "ops@taraxa.io" || keys[0].Networks[0] != "mainnet" || keys[0].Types[0] != "light" {
+				t.Errorf("Unexpected first key: %+v", keys[0])
+			}
+			if !keys[1].Disabled || !keys[1].ExpiresAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) || keys[1].Groups[0] != "partners" {
+				t.Errorf("Unexpected second key: %+v", keys[1])
+			}
+		})
+	}
+}
+
+func TestLoadKeysFile_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "missing id", content: "keys:\n  - key: secret", expected: "has no id"},
+		{name: "missing key", content: "keys:\n  - id: a", expected: "has no key"},
+		{name: "duplicate id", content: "keys:\n  - {id: a, key: one}\n  - {id: a, key: two}", expected: "duplicate key id"},
+		{name: "duplicate key", content: "keys:\n  - {id: a, key: one}\n  - {id: b, key: one}", expected: "reuses the key"},
+		{name: "key and hash", content: "keys:\n  - {id: a, key: one, hash: 'sha256:" + strings.Repeat("ab", 32) + "'}", expected: "sets both key and hash"},
+		{name: "invalid hash", content: "keys:\n  - {id: a, hash: 'md5:abc'}", expected: "unsupported hash"},
+		{name: "slow hash without prefix", content: "keys:\n  - {id: a, hash: '$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy'}", expected: "need a prefix"},
+		{name: "malformed", content: "keys: [", expected: "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadKeysFile(writeKeysFile(t, "keys.yaml", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestAPIKey_Active(t *testing.T) {
+	now := time.Date(2025, 7, 6, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		key      APIKey
+		expected bool
+	}{
+		{name: "no expiry", key: APIKey{}, expected: true},
+		{name: "before expiry", key: APIKey{ExpiresAt: now.Add(time.Hour)}, expected: true},
+		{name: "expired", key: APIKey{ExpiresAt: now}, expected: false},
+		{name: "disabled", key: APIKey{Disabled: true}, expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.key.Active(now); got != tt.expected {
+			t.Errorf("%s: Active() = %v, expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestConfig_AllKeys_Groups(t *testing.T) {
+	hash, err := keyhash.Hash(keyhash.SHA256, "partner-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		APIKeys: []string{"env-secret"},
+		APIKeyGroups: map[string][]string{
+			"partners": {"partner-secret", "dashboard", "group-only"},
+			"internal": {"env-secret", "dashboard", "group-only"},
+		},
+		Keys: []APIKey{
+			{ID: "partner", Hash: hash},
+			{ID: "dashboard", Key: "dashboard-secret", Groups: []string{"internal"}},
+		},
+	}
+
+	expected := map[string][]string{
+		"partner":                 {"partners"},
+		"dashboard":               {"internal", "partners"},
+		"env-1":                   {"internal"},
+		"group-internal+partners": {"internal", "partners"},
+	}
+
+	keys := cfg.AllKeys()
+	if len(keys) != len(expected) {
+		t.Fatalf("Expected %d keys, got %+v", len(expected), keys)
+	}
+	for _, key := range keys {
+		if groups, ok := expected[key.ID]; !ok || strings.Join(key.Groups, ",") != strings.Join(groups, ",") {
+			t.Errorf("Key %s: expected groups %v, got %v", key.ID, groups, key.Groups)
+		}
+	}
+	if cfg.Keys[1].Groups[0] != "internal" || len(cfg.Keys[1].Groups) != 1 {
+		t.Errorf("Expected the configured groups to be left unchanged, got %v", cfg.Keys[1].Groups)
+	}
+}
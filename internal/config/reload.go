@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// readConfigFile parses a file of KEY=value lines. Blank lines and lines starting
+// with # are skipped, and values may be wrapped in single or double quotes.
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(strings.TrimPrefix(entry, "export "), "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value", path, line)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// Files returns the files the configuration was read from, which are watched for changes
+func (c *Config) Files() []string {
+	var files []string
+	if c.ConfigFile != "" {
+		files = append(files, c.ConfigFile)
+	}
+	if c.APIKeysFile != "" {
+		files = append(files, c.APIKeysFile)
+	}
+	return files
+}
+
+// Changes describes what differs between two configurations for the reload log.
+// Secrets are never included, only key ids and setting names. Settings that are
+// not reloaded are reported as requiring a restart.
+func Changes(previous, current *Config) []string {
+	var changes []string
+
+	if previous.RefreshInterval != current.RefreshInterval {
+		changes = append(changes, fmt.Sprintf("REFRESH_INTERVAL %s -> %s", previous.RefreshInterval, current.RefreshInterval))
+	}
+	if previous.StaleAfter != current.StaleAfter {
+		changes = append(changes, fmt.Sprintf("STALE_AFTER %s -> %s", previous.StaleAfter, current.StaleAfter))
+	}
+	if previous.HistoryDepth != current.HistoryDepth {
+		changes = append(changes, fmt.Sprintf("HISTORY_DEPTH %d -> %d", previous.HistoryDepth, current.HistoryDepth))
+	}
+
+	// With a key store, configured keys are only imported at startup
+	var ignored string
+	if current.KeyStoreFile != "" {
+		ignored = " (ignored, keys are managed in KEY_STORE_FILE)"
+	}
+	previousKeys, currentKeys := keysByID(previous.AllKeys()), keysByID(current.AllKeys())
+	for _, id := range sortedKeys(currentKeys) {
+		key, found := previousKeys[id]
+		switch {
+		case !found:
+			changes = append(changes, "added API key "+id+ignored)
+		case !reflect.DeepEqual(key, currentKeys[id]):
+			changes = append(changes, "updated API key "+id+ignored)
+		}
+	}
+	for _, id := range sortedKeys(previousKeys) {
+		if _, found := currentKeys[id]; !found {
+			changes = append(changes, "removed API key "+id+ignored)
+		}
+	}
+
+	if !reflect.DeepEqual(previous.AccessPolicy, current.AccessPolicy) {
+		changes = append(changes, "ACCESS_POLICY changed")
+	}
+	if !slices.Equal(previous.AdminAPIKeys, current.AdminAPIKeys) {
+		changes = append(changes, "ADMIN_API_KEYS changed")
+	}
+
+	// Everything else is wired once at startup
+	restart := []struct {
+		name    string
+		changed bool
+	}{
+		{"PORT", previous.Port != current.Port},
+		{"storage settings", previous.StorageBackend != current.StorageBackend || previous.StoragePublicURL != current.StoragePublicURL ||
+			previous.StoragePrefix != current.StoragePrefix || previous.StorageMaxPages != current.StorageMaxPages ||
+			previous.GCPBucketName != current.GCPBucketName || previous.GCPBucketURL != current.GCPBucketURL ||
+			previous.S3Endpoint != current.S3Endpoint || previous.S3Bucket != current.S3Bucket || previous.S3Region != current.S3Region ||
+			previous.S3AccessKeyID != current.S3AccessKeyID || previous.S3SecretAccessKey != current.S3SecretAccessKey ||
+			previous.LocalDir != current.LocalDir},
+		{"METRICS_ENABLED", previous.MetricsEnabled != current.MetricsEnabled},
+		{"alert settings", !reflect.DeepEqual(previous.FreshnessThresholds, current.FreshnessThresholds) ||
+			previous.AlertWebhookURL != current.AlertWebhookURL || previous.AlertSlackURL != current.AlertSlackURL},
+		{"webhook settings", !slices.Equal(previous.WebhookURLs, current.WebhookURLs) || previous.WebhookSecret != current.WebhookSecret ||
+			previous.WebhookMaxAttempts != current.WebhookMaxAttempts || previous.WebhookDeadLetter != current.WebhookDeadLetter},
+		{"API_KEYS_FILE", previous.APIKeysFile != current.APIKeysFile},
+		{"KEY_STORE_FILE", previous.KeyStoreFile != current.KeyStoreFile},
+		{"rate limit settings", previous.RateLimitEnabled != current.RateLimitEnabled || previous.RateLimitAnonymous != current.RateLimitAnonymous ||
+			!reflect.DeepEqual(previous.RateLimitTiers, current.RateLimitTiers) || !slices.Equal(previous.TrustedProxies, current.TrustedProxies)},
+		{"usage settings", previous.UsageStoreFile != current.UsageStoreFile || previous.UsageFlushInterval != current.UsageFlushInterval},
+		{"JWT settings", previous.JWTJWKS != current.JWTJWKS || previous.JWTIssuer != current.JWTIssuer || previous.JWTAudience != current.JWTAudience ||
+			previous.JWTClaims != current.JWTClaims || previous.JWTJWKSRefresh != current.JWTJWKSRefresh},
+		{"URL signing settings", previous.URLSigningKeyFile != current.URLSigningKeyFile || previous.URLSigningExpiry != current.URLSigningExpiry},
+		{"download proxy settings", previous.DownloadProxyEnabled != current.DownloadProxyEnabled || previous.DownloadBandwidth != current.DownloadBandwidth},
+		{"MIRRORS", !slices.Equal(previous.Mirrors, current.Mirrors)},
+	}
+	for _, setting := range restart {
+		if setting.changed {
+			changes = append(changes, setting.name+" changed (restart required)")
+		}
+	}
+
+	return changes
+}
+
+func keysByID(keys []APIKey) map[string]APIKey {
+	byID := make(map[string]APIKey, len(keys))
+	for _, key := range keys {
+		byID[key.ID] = key
+	}
+	return byID
+}
+
+func sortedKeys(keys map[string]APIKey) []string {
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Watch polls files every interval and calls onChange when any of them is modified,
+// created or removed, until ctx is done. Polling the modification time also catches
+// the symlink swaps Kubernetes uses to update mounted ConfigMaps and Secrets.
+func Watch(ctx context.Context, interval time.Duration, files []string, onChange func()) {
+	state := fileState(files)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if current := fileState(files); !slices.Equal(current, state) {
+				state = current
+				onChange()
+			}
+		}
+	}
+}
+
+// fileState returns the modification time and size of each file, or zero values for missing files
+func fileState(files []string) []string {
+	state := make([]string, len(files))
+	for i, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			state[i] = fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return state
+}
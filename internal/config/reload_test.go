@@ -0,0 +1,173 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	path := writeKeysFile(t, "snapshots.env", `
+# API settings
+API_KEYS=key1,key2
+export ACCESS_POLICY="devnet/full=public"
+REFRESH_INTERVAL = '1m'
+EMPTY=
+`)
+
+	values, err := readConfigFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"API_KEYS":         "key1,key2",
+		"ACCESS_POLICY":    "devnet/full=public",
+		"REFRESH_INTERVAL": "1m",
+		"EMPTY":            "",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	if _, err := readConfigFile(writeKeysFile(t, "bad.env", "API_KEYS")); err == nil || !strings.Contains(err.Error(), ":1: expected KEY=value") {
+		t.Errorf("Expected line error, got %v", err)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	t.Setenv("API_KEYS", "env-key")
+	t.Setenv("REFRESH_INTERVAL", "2m")
+
+	t.Run("config file overrides environment", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", writeKeysFile(t, "snapshots.env", "API_KEYS=file-key\nACCESS_POLICY=devnet/full=public\n"))
+
+		cfg, err := LoadStrict()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(cfg.APIKeys) != 1 || cfg.APIKeys[0] != "file-key" {
+			t.Errorf("Expected API keys from the config file, got %v", cfg.APIKeys)
+		}
+		if cfg.RefreshInterval != 2*time.Minute {
+			t.Errorf("Expected REFRESH_INTERVAL from the environment, got %s", cfg.RefreshInterval)
+		}
+		if cfg.AccessPolicy["devnet/full"].Access != AccessPublic {
+			t.Errorf("Expected access policy from the config file, got %v", cfg.AccessPolicy)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "invalid policy", content: "ACCESS_POLICY=devnet/full=everyone", expected: "ACCESS_POLICY"},
+		{name: "invalid interval", content: "REFRESH_INTERVAL=soon", expected: "REFRESH_INTERVAL"},
+		{name: "invalid groups", content: "API_KEY_GROUPS=:key", expected: "API_KEY_GROUPS"},
+		{name: "malformed file", content: "not a setting", expected: "CONFIG_FILE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeKeysFile(t, "snapshots.env", tt.content))
+
+			if _, err := LoadStrict(); err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error mentioning %s, got %v", tt.expected, err)
+			}
+
+			// Load ignores the invalid value and keeps the rest
+			if cfg := Load(); len(cfg.APIKeys) != 1 || cfg.APIKeys[0] != "env-key" {
+				t.Errorf("Expected Load to ignore the invalid value, got API keys %v", cfg.APIKeys)
+			}
+		})
+	}
+
+	t.Run("missing keys file", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", "")
+		t.Setenv("API_KEYS_FILE", "/nonexistent/keys.yaml")
+
+		if _, err := LoadStrict(); err == nil || !strings.Contains(err.Error(), "API_KEYS_FILE") {
+			t.Errorf("Expected API_KEYS_FILE error, got %v", err)
+		}
+	})
+}
+
+func TestChanges(t *testing.T) {
+	previous := &Config{
+		Port:            8080,
+		RefreshInterval: 5 * time.Minute,
+		StaleAfter:      10 * time.Minute,
+		APIKeys:         []string{"key1"},
+		Keys:            []APIKey{{ID: "dashboard", Key: "secret-1"}, {ID: "partner", Key: "secret-2"}},
+	}
+	current := &Config{
+		Port:            9090,
+		RefreshInterval: time.Minute,
+		StaleAfter:      10 * time.Minute,
+		HistoryDepth:    5,
+		APIKeys:         []string{"key1"},
+		Keys:            []APIKey{{ID: "dashboard", Key: "secret-1", Disabled: true}, {ID: "ci", Key: "secret-3"}},
+		AccessPolicy:    map[string]AccessRule{"devnet/full": {Access: AccessPublic}},
+	}
+
+	expected := []string{
+		"REFRESH_INTERVAL 5m0s -> 1m0s",
+		"HISTORY_DEPTH 0 -> 5",
+		"added API key ci",
+		"updated API key dashboard",
+		"removed API key partner",
+		"ACCESS_POLICY changed",
+		"PORT changed (restart required)",
+	}
+	if changes := Changes(previous, current); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %q, got %q", expected, changes)
+	}
+
+	if changes := Changes(previous, previous); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %q", changes)
+	}
+
+	for _, change := range Changes(previous, current) {
+		if strings.Contains(change, "secret") {
+			t.Errorf("Change %q leaks a key", change)
+		}
+	}
+
+	// Key changes do not apply when keys are managed in a key store
+	previous.KeyStoreFile, current.KeyStoreFile = "keys.db", "keys.db"
+	if changes := Changes(previous, current); changes[2] != "added API key ci (ignored, keys are managed in KEY_STORE_FILE)" {
+		t.Errorf("Expected key changes to be ignored, got %q", changes)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := writeKeysFile(t, "keys.yaml", "keys: []")
+
+	changed := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watch(ctx, 5*time.Millisecond, []string{path}, func() {
+		changed <- struct{}{}
+	})
+
+	select {
+	case <-changed:
+		t.Fatal("Unexpected change before the file was modified")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected change notification")
+	}
+}
@@ -0,0 +1,169 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// DefaultBacklog is the number of past events kept for Last-Event-ID resume
+const DefaultBacklog = 256
+
+// subscriberBuffer is the number of events a slow subscriber may fall behind
+// before it is disconnected
+const subscriberBuffer = 16
+
+// Event is a change of the cached snapshots of one network
+type Event struct {
+	// ID is derived from the network and its snapshots, so it is the same on every
+	// replica and across restarts
+	ID        string
+	Time      time.Time
+	Network   models.Network
+	Snapshots *models.NetworkSnapshots
+}
+
+// Subscription delivers events for one network until it is closed
+type Subscription struct {
+	// Events is closed when the subscriber falls behind or the broker shuts down
+	Events  <-chan Event
+	network models.Network
+	events  chan Event
+}
+
+// Broker fans snapshot changes out to subscribers and keeps a backlog for resume
+type Broker struct {
+	mutex       sync.Mutex
+	backlog     []Event
+	backlogSize int
+	latest      map[models.Network]Event
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewBroker creates an event broker
+func NewBroker() *Broker {
+	return &Broker{
+		backlogSize: DefaultBacklog,
+		latest:      make(map[models.Network]Event),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish emits an event for every network whose snapshots differ between previous and current
+func (b *Broker) Publish(previous, current map[models.Network]*models.NetworkSnapshots, now time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for network, snapshots := range current {
+		if old, ok := previous[network]; ok && reflect.DeepEqual(old, snapshots) {
+			continue
+		}
+
+		event := Event{ID: eventID(network, snapshots), Time: now, Network: network, Snapshots: snapshots}
+
+		b.backlog = append(b.backlog, event)
+		if len(b.backlog) > b.backlogSize {
+			b.backlog = b.backlog[len(b.backlog)-b.backlogSize:]
+		}
+		b.latest[network] = event
+
+		for subscription := range b.subscribers {
+			if subscription.network != network {
+				continue
+			}
+			select {
+			case subscription.events <- event:
+			default:
+				b.drop(subscription)
+			}
+		}
+	}
+}
+
+// Subscribe registers a subscriber for network. With lastEventID it returns the events
+// the subscriber missed; if lastEventID is unknown, for example because it was evicted
+// from the backlog or sent by another replica, or without lastEventID, it returns the
+// latest event for the network instead.
+func (b *Broker) Subscribe(network models.Network, lastEventID string) (*Subscription, []Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	events := make(chan Event, subscriberBuffer)
+	subscription := &Subscription{Events: events, network: network, events: events}
+	if b.closed {
+		close(events)
+		return subscription, nil
+	}
+	b.subscribers[subscription] = struct{}{}
+
+	return subscription, b.replay(network, lastEventID)
+}
+
+// Unsubscribe removes a subscriber
+func (b *Broker) Unsubscribe(subscription *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.subscribers[subscription]; ok {
+		b.drop(subscription)
+	}
+}
+
+// Close disconnects every subscriber
+func (b *Broker) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closed = true
+	for subscription := range b.subscribers {
+		b.drop(subscription)
+	}
+}
+
+// replay returns the events of network after lastEventID, or the latest event
+func (b *Broker) replay(network models.Network, lastEventID string) []Event {
+	latest, ok := b.latest[network]
+	if !ok {
+		return nil
+	}
+	if lastEventID == "" {
+		return []Event{latest}
+	}
+	if lastEventID == latest.ID {
+		return nil
+	}
+
+	// IDs repeat when the snapshots return to an earlier state, so resume after the last match
+	for i := len(b.backlog) - 1; i >= 0; i-- {
+		if b.backlog[i].ID != lastEventID || b.backlog[i].Network != network {
+			continue
+		}
+		var missed []Event
+		for _, event := range b.backlog[i+1:] {
+			if event.Network == network {
+				missed = append(missed, event)
+			}
+		}
+		return missed
+	}
+	return []Event{latest}
+}
+
+// eventID hashes the network and its snapshots
+func eventID(network models.Network, snapshots *models.NetworkSnapshots) string {
+	data, _ := json.Marshal(snapshots)
+	sum := sha256.Sum256(append([]byte(network+"\n"), data...))
+	return hex.EncodeToString(sum[:12])
+}
+
+// drop removes a subscriber and closes its channel; the caller must hold the lock
+func (b *Broker) drop(subscription *Subscription) {
+	delete(b.subscribers, subscription)
+	close(subscription.events)
+}
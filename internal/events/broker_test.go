@@ -0,0 +1,148 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+func networkSnapshots(block int64) *models.NetworkSnapshots {
+	return &models.NetworkSnapshots{
+		Light: &models.SnapshotInfo{Block: block, URL: "https://example.com/light"},
+		Full:  &models.SnapshotInfo{Block: block, URL: "https://example.com/full"},
+	}
+}
+
+func TestBroker_Publish(t *testing.T) {
+	broker := NewBroker()
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	subscription, backlog := broker.Subscribe(models.NetworkMainnet, "")
+	if len(backlog) != 0 {
+		t.Fatalf("Expected no backlog before the first refresh, got %d", len(backlog))
+	}
+
+	first := map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: networkSnapshots(100),
+		models.NetworkTestnet: networkSnapshots(50),
+	}
+	broker.Publish(map[models.Network]*models.NetworkSnapshots{}, first, now)
+
+	event := <-subscription.Events
+	if event.Network != models.NetworkMainnet || event.Snapshots.Light.Block != 100 {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	// Identical data does not emit
+	broker.Publish(first, map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: networkSnapshots(100),
+		models.NetworkTestnet: networkSnapshots(50),
+	}, now)
+	select {
+	case event := <-subscription.Events:
+		t.Fatalf("Unexpected event for unchanged snapshots: %+v", event)
+	default:
+	}
+
+	// Changes on other networks are not delivered
+	second := map[models.Network]*models.NetworkSnapshots{
+		models.NetworkMainnet: networkSnapshots(100),
+		models.NetworkTestnet: networkSnapshots(60),
+	}
+	broker.Publish(first, second, now)
+	select {
+	case event := <-subscription.Events:
+		t.Fatalf("Unexpected event for another network: %+v", event)
+	default:
+	}
+
+	broker.Unsubscribe(subscription)
+	if _, ok := <-subscription.Events; ok {
+		t.Error("Expected events channel to be closed after unsubscribe")
+	}
+}
+
+func TestBroker_Resume(t *testing.T) {
+	broker := NewBroker()
+	broker.backlogSize = 3
+	now := time.Now()
+
+	ids := make(map[int64]string)
+	previous := map[models.Network]*models.NetworkSnapshots{}
+	for block := int64(1); block <= 5; block++ {
+		current := map[models.Network]*models.NetworkSnapshots{models.NetworkMainnet: networkSnapshots(block)}
+		broker.Publish(previous, current, now)
+		ids[block] = broker.latest[models.NetworkMainnet].ID
+		previous = current
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		expected    []int64
+	}{
+		{name: "no last event id sends latest", lastEventID: "", expected: []int64{5}},
+		{name: "resume within backlog", lastEventID: ids[3], expected: []int64{4, 5}},
+		{name: "up to date", lastEventID: ids[5], expected: nil},
+		{name: "evicted from backlog sends latest", lastEventID: ids[1], expected: []int64{5}},
+		{name: "unknown id sends latest", lastEventID: "99", expected: []int64{5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscription, backlog := broker.Subscribe(models.NetworkMainnet, tt.lastEventID)
+			defer broker.Unsubscribe(subscription)
+
+			if len(backlog) != len(tt.expected) {
+				t.Fatalf("Expected %d events, got %d", len(tt.expected), len(backlog))
+			}
+			for i, event := range backlog {
+				if event.ID != ids[tt.expected[i]] || event.Snapshots.Light.Block != tt.expected[i] {
+					t.Errorf("Event %d: expected block %d, got %+v", i, tt.expected[i], event)
+				}
+			}
+		})
+	}
+
+	// Another broker publishing the same snapshots, like a replica or a restarted
+	// process, derives the same IDs, so clients resume across them
+	replica := NewBroker()
+	replica.Publish(map[models.Network]*models.NetworkSnapshots{}, previous, now.Add(time.Minute))
+	subscription, backlog := replica.Subscribe(models.NetworkMainnet, ids[5])
+	defer replica.Unsubscribe(subscription)
+	if len(backlog) != 0 {
+		t.Errorf("Expected no replay for an up to date client of another replica, got %d events", len(backlog))
+	}
+}
+
+func TestBroker_SlowSubscriberAndClose(t *testing.T) {
+	broker := NewBroker()
+	slow, _ := broker.Subscribe(models.NetworkMainnet, "")
+	other, _ := broker.Subscribe(models.NetworkDevnet, "")
+
+	previous := map[models.Network]*models.NetworkSnapshots{}
+	for block := int64(1); block <= subscriberBuffer+1; block++ {
+		current := map[models.Network]*models.NetworkSnapshots{models.NetworkMainnet: networkSnapshots(block)}
+		broker.Publish(previous, current, time.Now())
+		previous = current
+	}
+
+	received := 0
+	for range slow.Events {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("Expected %d buffered events before disconnect, got %d", subscriberBuffer, received)
+	}
+
+	broker.Close()
+	if _, ok := <-other.Events; ok {
+		t.Error("Expected subscribers to be closed on shutdown")
+	}
+
+	late, _ := broker.Subscribe(models.NetworkMainnet, "")
+	if _, ok := <-late.Events; ok {
+		t.Error("Expected subscriptions after close to be closed")
+	}
+}
@@ -0,0 +1,307 @@
+package freshness
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/metrics"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// Stream states
+const (
+	StatusFresh       = "fresh"
+	StatusStale       = "stale"
+	StatusUnmonitored = "unmonitored"
+)
+
+// Overall report states
+const (
+	ReportOK       = "ok"
+	ReportDegraded = "degraded"
+)
+
+// notifyTimeout bounds how long a single alert delivery may take
+const notifyTimeout = 10 * time.Second
+
+// DefaultCheckInterval is how often Run re-checks the snapshots of the last refresh
+const DefaultCheckInterval = time.Minute
+
+// Threshold defines when a snapshot stream is considered stale
+type Threshold struct {
+	// MaxAge is the maximum age of the newest snapshot
+	MaxAge time.Duration
+	// MaxBlockStall, if set, is the maximum time the newest block may stay unchanged,
+	// catching producers that keep uploading snapshots of a stuck chain
+	MaxBlockStall time.Duration
+}
+
+// StreamStatus reports the freshness of one network and snapshot type
+type StreamStatus struct {
+	Network         models.Network      `json:"network"`
+	Type            models.SnapshotType `json:"type"`
+	Status          string              `json:"status"`
+	LatestBlock     int64               `json:"latest-block,omitempty"`
+	LatestTimestamp *time.Time          `json:"latest-timestamp,omitempty"`
+	AgeSeconds      int64               `json:"age-seconds,omitempty"`
+	// BlockStallSeconds is the time since the newest block first appeared
+	BlockStallSeconds int64  `json:"block-stall-seconds,omitempty"`
+	MaxAge            string `json:"max-age,omitempty"`
+	MaxBlockStall     string `json:"max-block-stall,omitempty"`
+	Reason            string `json:"reason,omitempty"`
+	// Since is when the stream entered its current status
+	Since time.Time `json:"since"`
+}
+
+// Report is the result of the last freshness check
+type Report struct {
+	Status    string         `json:"status"`
+	CheckedAt time.Time      `json:"checked-at"`
+	Streams   []StreamStatus `json:"streams"`
+}
+
+// Checker evaluates snapshot streams against freshness thresholds and notifies on changes
+type Checker struct {
+	networks   []models.Network
+	thresholds map[string]Threshold
+	notifiers  []Notifier
+	metrics    *metrics.Metrics
+
+	mutex  sync.RWMutex
+	report Report
+	// snapshots are those of the last refresh, re-checked by Run while refreshes fail
+	snapshots []*models.Snapshot
+	// states holds the last status per stream key, used to detect transitions
+	states map[string]StreamStatus
+}
+
+// NewChecker creates a checker for the given networks. Thresholds are keyed by
+// "network/type"; "*" matches any network.
+func NewChecker(networks []models.Network, thresholds map[string]Threshold, notifiers ...Notifier) *Checker {
+	return &Checker{
+		networks:   networks,
+		thresholds: thresholds,
+		notifiers:  notifiers,
+		report:     Report{Status: ReportOK, Streams: []StreamStatus{}},
+		states:     make(map[string]StreamStatus),
+	}
+}
+
+// SetMetrics enables freshness gauges
+func (c *Checker) SetMetrics(m *metrics.Metrics) {
+	c.metrics = m
+}
+
+// Report returns the result of the last check
+func (c *Checker) Report() Report {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	report := c.report
+	report.Streams = append([]StreamStatus(nil), c.report.Streams...)
+	return report
+}
+
+// threshold returns the threshold for a stream, preferring an exact network match
+func (c *Checker) threshold(network models.Network, snapshotType models.SnapshotType) (Threshold, bool) {
+	if threshold, ok := c.thresholds[streamKey(network, snapshotType)]; ok {
+		return threshold, true
+	}
+	threshold, ok := c.thresholds[streamKey("*", snapshotType)]
+	return threshold, ok
+}
+
+// Check evaluates every stream against the snapshots of a refresh and sends
+// alerts for streams that went stale or recovered since the previous check
+func (c *Checker) Check(now time.Time, snapshots []*models.Snapshot) {
+	c.mutex.Lock()
+	c.snapshots = snapshots
+	alerts := c.check(now)
+	c.mutex.Unlock()
+
+	if len(alerts) > 0 {
+		go c.notify(alerts)
+	}
+}
+
+// Run re-checks the snapshots of the last refresh against the current time every
+// interval until ctx is cancelled, so streams go stale even when refreshes fail
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.mutex.Lock()
+			alerts := c.check(now)
+			c.mutex.Unlock()
+
+			if len(alerts) > 0 {
+				go c.notify(alerts)
+			}
+		}
+	}
+}
+
+// check evaluates every stream against the stored snapshots, updates the report and
+// returns the alerts to send. The caller must hold the write lock.
+func (c *Checker) check(now time.Time) []Alert {
+	latest := latestSnapshots(c.snapshots)
+
+	report := Report{Status: ReportOK, CheckedAt: now, Streams: []StreamStatus{}}
+	var alerts []Alert
+
+	for _, network := range c.networks {
+		for _, snapshotType := range []models.SnapshotType{models.SnapshotTypeFull, models.SnapshotTypeLight} {
+			key := streamKey(network, snapshotType)
+			status := c.evaluate(now, network, snapshotType, latest[key])
+
+			previous, seen := c.states[key]
+			if seen && previous.Status == status.Status {
+				status.Since = previous.Since
+			} else {
+				status.Since = now
+			}
+
+			if alert, ok := transition(previous, seen, status, now); ok {
+				alerts = append(alerts, alert)
+			}
+
+			c.states[key] = status
+			report.Streams = append(report.Streams, status)
+			if status.Status == StatusStale {
+				report.Status = ReportDegraded
+			}
+
+			c.metrics.ObserveFreshness(string(network), string(snapshotType),
+				time.Duration(status.AgeSeconds)*time.Second, status.Status == StatusStale)
+		}
+	}
+	c.report = report
+
+	return alerts
+}
+
+// evaluate computes the status of a single stream
+func (c *Checker) evaluate(now time.Time, network models.Network, snapshotType models.SnapshotType, head *streamHead) StreamStatus {
+	status := StreamStatus{
+		Network: network,
+		Type:    snapshotType,
+		Status:  StatusUnmonitored,
+	}
+
+	if head != nil {
+		timestamp := head.latest.Timestamp
+		status.LatestBlock = head.latest.Block
+		status.LatestTimestamp = &timestamp
+		status.AgeSeconds = int64(now.Sub(timestamp).Seconds())
+		status.BlockStallSeconds = int64(now.Sub(head.blockSince).Seconds())
+	}
+
+	threshold, monitored := c.threshold(network, snapshotType)
+	if !monitored {
+		return status
+	}
+
+	status.Status = StatusFresh
+	if threshold.MaxAge > 0 {
+		status.MaxAge = threshold.MaxAge.String()
+	}
+	if threshold.MaxBlockStall > 0 {
+		status.MaxBlockStall = threshold.MaxBlockStall.String()
+	}
+
+	switch {
+	case head == nil:
+		status.Status = StatusStale
+		status.Reason = "no snapshots found"
+	case threshold.MaxAge > 0 && now.Sub(head.latest.Timestamp) > threshold.MaxAge:
+		status.Status = StatusStale
+		status.Reason = fmt.Sprintf("newest snapshot is older than %s", threshold.MaxAge)
+	case threshold.MaxBlockStall > 0 && now.Sub(head.blockSince) > threshold.MaxBlockStall:
+		status.Status = StatusStale
+		status.Reason = fmt.Sprintf("block %d has not advanced for more than %s", head.latest.Block, threshold.MaxBlockStall)
+	}
+
+	return status
+}
+
+// notify delivers alerts to every notifier, logging failures
+func (c *Checker) notify(alerts []Alert) {
+	for _, alert := range alerts {
+		log.Printf("Freshness alert: %s", alert.Message)
+		for _, notifier := range c.notifiers {
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			if err := notifier.Notify(ctx, alert); err != nil {
+				log.Printf("Failed to deliver freshness alert for %s/%s: %v", alert.Network, alert.Type, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// transition returns the alert for a status change, if any. A stream that is stale
+// on the first check alerts; one that is fresh on the first check does not.
+func transition(previous StreamStatus, seen bool, current StreamStatus, now time.Time) (Alert, bool) {
+	alert := Alert{
+		Network:         current.Network,
+		Type:            current.Type,
+		LatestBlock:     current.LatestBlock,
+		LatestTimestamp: current.LatestTimestamp,
+		Reason:          current.Reason,
+		Time:            now,
+	}
+
+	switch {
+	case current.Status == StatusStale && (!seen || previous.Status != StatusStale):
+		alert.State = AlertStale
+		alert.Message = fmt.Sprintf("%s %s snapshots are stale: %s", current.Network, current.Type, current.Reason)
+		return alert, true
+	case seen && previous.Status == StatusStale && current.Status == StatusFresh:
+		alert.State = AlertRecovered
+		alert.Message = fmt.Sprintf("%s %s snapshots recovered: latest block %d", current.Network, current.Type, current.LatestBlock)
+		return alert, true
+	default:
+		return Alert{}, false
+	}
+}
+
+// streamHead is the newest snapshot of a stream and when its block first appeared
+type streamHead struct {
+	latest     *models.Snapshot
+	blockSince time.Time
+}
+
+// latestSnapshots finds the head of every stream
+func latestSnapshots(snapshots []*models.Snapshot) map[string]*streamHead {
+	heads := make(map[string]*streamHead)
+
+	for _, snapshot := range snapshots {
+		key := streamKey(snapshot.Network, snapshot.Type)
+		head, exists := heads[key]
+
+		switch {
+		case !exists || snapshot.Block > head.latest.Block:
+			heads[key] = &streamHead{latest: snapshot, blockSince: snapshot.Timestamp}
+		case snapshot.Block == head.latest.Block:
+			if snapshot.Timestamp.After(head.latest.Timestamp) {
+				head.latest = snapshot
+			}
+			if snapshot.Timestamp.Before(head.blockSince) {
+				head.blockSince = snapshot.Timestamp
+			}
+		}
+	}
+
+	return heads
+}
+
+func streamKey(network models.Network, snapshotType models.SnapshotType) string {
+	return fmt.Sprintf("%s/%s", network, snapshotType)
+}
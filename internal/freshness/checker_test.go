@@ -0,0 +1,259 @@
+package freshness
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// recordingNotifier collects delivered alerts
+type recordingNotifier struct {
+	mu     sync.Mutex
+	alerts []Alert
+	done   chan struct{}
+}
+
+func newRecordingNotifier() *recordingNotifier {
+	return &recordingNotifier{done: make(chan struct{}, 16)}
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, alert Alert) error {
+	n.mu.Lock()
+	n.alerts = append(n.alerts, alert)
+	n.mu.Unlock()
+	n.done <- struct{}{}
+	return nil
+}
+
+// wait blocks until count alerts have been delivered
+func (n *recordingNotifier) wait(t *testing.T, count int) []Alert {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		select {
+		case <-n.done:
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for alert %d", i+1)
+		}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Alert(nil), n.alerts...)
+}
+
+func snapshot(network models.Network, snapshotType models.SnapshotType, block int64, timestamp time.Time) *models.Snapshot {
+	return &models.Snapshot{Network: network, Type: snapshotType, Block: block, Timestamp: timestamp}
+}
+
+func findStream(report Report, network models.Network, snapshotType models.SnapshotType) StreamStatus {
+	for _, stream := range report.Streams {
+		if stream.Network == network && stream.Type == snapshotType {
+			return stream
+		}
+	}
+	return StreamStatus{}
+}
+
+func TestChecker_Check(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		thresholds map[string]Threshold
+		snapshots  []*models.Snapshot
+		expected   string
+		reason     string
+	}{
+		{
+			name:       "fresh within max age",
+			thresholds: map[string]Threshold{"mainnet/full": {MaxAge: 2 * time.Hour}},
+			snapshots:  []*models.Snapshot{snapshot(models.NetworkMainnet, models.SnapshotTypeFull, 100, now.Add(-time.Hour))},
+			expected:   StatusFresh,
+		},
+		{
+			name:       "stale past max age",
+			thresholds: map[string]Threshold{"mainnet/full": {MaxAge: 2 * time.Hour}},
+			snapshots:  []*models.Snapshot{snapshot(models.NetworkMainnet, models.SnapshotTypeFull, 100, now.Add(-3*time.Hour))},
+			expected:   StatusStale,
+			reason:     "older than 2h0m0s",
+		},
+		{
+			name:       "wildcard network",
+			thresholds: map[string]Threshold{"*/full": {MaxAge: 2 * time.Hour}},
+			snapshots:  []*models.Snapshot{snapshot(models.NetworkMainnet, models.SnapshotTypeFull, 100, now.Add(-3*time.Hour))},
+			expected:   StatusStale,
+		},
+		{
+			name: "exact network overrides wildcard",
+			thresholds: map[string]Threshold{
+				"*/full":       {MaxAge: 2 * time.Hour},
+				"mainnet/full": {MaxAge: 4 * time.Hour},
+			},
+			snapshots: []*models.Snapshot{snapshot(models.NetworkMainnet, models.SnapshotTypeFull, 100, now.Add(-3*time.Hour))},
+			expected:  StatusFresh,
+		},
+		{
+			name:       "no snapshots",
+			thresholds: map[string]Threshold{"mainnet/full": {MaxAge: 2 * time.Hour}},
+			expected:   StatusStale,
+			reason:     "no snapshots found",
+		},
+		{
+			name:       "block stalled",
+			thresholds: map[string]Threshold{"mainnet/full": {MaxAge: 2 * time.Hour, MaxBlockStall: 3 * time.Hour}},
+			snapshots: []*models.Snapshot{
+				snapshot(models.NetworkMainnet, models.SnapshotTypeFull, 100, now.Add(-time.Hour)),
+				snapshot(models.NetworkMainnet, models.SnapshotTypeFull, 100, now.Add(-5*time.Hour)),
+				snapshot(models.NetworkMainnet, models.SnapshotTypeFull, 90, now.Add(-9*time.Hour)),
+			},
+			expected: StatusStale,
+			reason:   "block 100 has not advanced",
+		},
+		{
+			name:      "unmonitored without threshold",
+			snapshots: []*models.Snapshot{snapshot(models.NetworkMainnet, models.SnapshotTypeFull, 100, now.Add(-30*24*time.Hour))},
+			expected:  StatusUnmonitored,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker([]models.Network{models.NetworkMainnet}, tt.thresholds)
+			checker.Check(now, tt.snapshots)
+
+			report := checker.Report()
+			if len(report.Streams) != 2 {
+				t.Fatalf("Expected 2 streams, got %d", len(report.Streams))
+			}
+
+			stream := findStream(report, models.NetworkMainnet, models.SnapshotTypeFull)
+			if stream.Status != tt.expected {
+				t.Errorf("Expected status %s, got %s (%s)", tt.expected, stream.Status, stream.Reason)
+			}
+			if !strings.Contains(stream.Reason, tt.reason) {
+				t.Errorf("Expected reason containing %q, got %q", tt.reason, stream.Reason)
+			}
+
+			expectedReport := ReportOK
+			if tt.expected == StatusStale {
+				expectedReport = ReportDegraded
+			}
+			if report.Status != expectedReport {
+				t.Errorf("Expected report status %s, got %s", expectedReport, report.Status)
+			}
+		})
+	}
+}
+
+func TestChecker_Alerts(t *testing.T) {
+	start := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	notifier := newRecordingNotifier()
+	checker := NewChecker(
+		[]models.Network{models.NetworkMainnet},
+		map[string]Threshold{"mainnet/light": {MaxAge: time.Hour}},
+		notifier,
+	)
+
+	snapshots := []*models.Snapshot{snapshot(models.NetworkMainnet, models.SnapshotTypeLight, 100, start)}
+
+	// Fresh on the first check does not alert
+	checker.Check(start.Add(30*time.Minute), snapshots)
+
+	// Going stale alerts once
+	checker.Check(start.Add(2*time.Hour), snapshots)
+	alerts := notifier.wait(t, 1)
+	if alerts[0].State != AlertStale || alerts[0].Network != models.NetworkMainnet || alerts[0].Type != models.SnapshotTypeLight {
+		t.Errorf("Unexpected stale alert: %+v", alerts[0])
+	}
+
+	staleSince := findStream(checker.Report(), models.NetworkMainnet, models.SnapshotTypeLight).Since
+	checker.Check(start.Add(3*time.Hour), snapshots)
+	if since := findStream(checker.Report(), models.NetworkMainnet, models.SnapshotTypeLight).Since; !since.Equal(staleSince) {
+		t.Errorf("Expected since to stay %v while stale, got %v", staleSince, since)
+	}
+
+	// A new snapshot recovers the stream
+	snapshots = append(snapshots, snapshot(models.NetworkMainnet, models.SnapshotTypeLight, 200, start.Add(3*time.Hour)))
+	checker.Check(start.Add(3*time.Hour+time.Minute), snapshots)
+	alerts = notifier.wait(t, 1)
+	if len(alerts) != 2 {
+		t.Fatalf("Expected 2 alerts, got %d", len(alerts))
+	}
+	if alerts[1].State != AlertRecovered || alerts[1].LatestBlock != 200 {
+		t.Errorf("Unexpected recovery alert: %+v", alerts[1])
+	}
+}
+
+func TestChecker_Run(t *testing.T) {
+	notifier := newRecordingNotifier()
+	checker := NewChecker([]models.Network{models.NetworkMainnet},
+		map[string]Threshold{"mainnet/light": {MaxAge: 24 * time.Hour}}, notifier)
+
+	// Fresh at the last successful refresh, but that was two days ago
+	taken := time.Now().Add(-72 * time.Hour)
+	checker.Check(taken.Add(time.Hour), []*models.Snapshot{snapshot(models.NetworkMainnet, models.SnapshotTypeLight, 100, taken)})
+	if status := findStream(checker.Report(), models.NetworkMainnet, models.SnapshotTypeLight).Status; status != StatusFresh {
+		t.Fatalf("Expected fresh after the refresh, got %s", status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checker.Run(ctx, 10*time.Millisecond)
+
+	alerts := notifier.wait(t, 1)
+	if alerts[0].State != AlertStale || alerts[0].Type != models.SnapshotTypeLight {
+		t.Errorf("Expected a stale alert for light snapshots, got %+v", alerts[0])
+	}
+	stream := findStream(checker.Report(), models.NetworkMainnet, models.SnapshotTypeLight)
+	if stream.Status != StatusStale || stream.LatestBlock != 100 {
+		t.Errorf("Expected stale block 100 without a new refresh, got %+v", stream)
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	alert := Alert{
+		Network: models.NetworkTestnet,
+		Type:    models.SnapshotTypeFull,
+		State:   AlertStale,
+		Message: "testnet full snapshots are stale",
+	}
+
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected JSON content type, got %q", r.Header.Get("Content-Type"))
+		}
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode body: %v", err)
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	if err := NewWebhookNotifier(server.URL).Notify(context.Background(), alert); err != nil {
+		t.Fatalf("Webhook notify failed: %v", err)
+	}
+	if body["network"] != "testnet" || body["state"] != AlertStale {
+		t.Errorf("Unexpected webhook payload: %v", body)
+	}
+
+	if err := NewSlackNotifier(server.URL).Notify(context.Background(), alert); err != nil {
+		t.Fatalf("Slack notify failed: %v", err)
+	}
+	if text, _ := body["text"].(string); !strings.Contains(text, alert.Message) {
+		t.Errorf("Expected Slack text to contain message, got %v", body)
+	}
+
+	if err := NewWebhookNotifier(server.URL+"/fail").Notify(context.Background(), alert); err == nil {
+		t.Error("Expected error for non-2xx response")
+	}
+}
@@ -0,0 +1,107 @@
+package freshness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// Alert states
+const (
+	AlertStale     = "stale"
+	AlertRecovered = "recovered"
+)
+
+// Alert is sent when a snapshot stream goes stale or recovers
+type Alert struct {
+	Network         models.Network      `json:"network"`
+	Type            models.SnapshotType `json:"type"`
+	State           string              `json:"state"`
+	Message         string              `json:"message"`
+	Reason          string              `json:"reason,omitempty"`
+	LatestBlock     int64               `json:"latest-block,omitempty"`
+	LatestTimestamp *time.Time          `json:"latest-timestamp,omitempty"`
+	Time            time.Time           `json:"time"`
+}
+
+// Notifier delivers freshness alerts
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// WebhookNotifier posts the alert as JSON to a generic webhook
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: notifyTimeout},
+	}
+}
+
+// Notify posts the alert
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, n.client, n.url, alert)
+}
+
+// slackMessage is the Slack incoming-webhook payload
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// SlackNotifier posts alerts as Slack-compatible incoming-webhook messages
+type SlackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewSlackNotifier creates a notifier posting to a Slack-compatible webhook url
+func NewSlackNotifier(url string) *SlackNotifier {
+	return &SlackNotifier{
+		url:    url,
+		client: &http.Client{Timeout: notifyTimeout},
+	}
+}
+
+// Notify posts the alert message
+func (n *SlackNotifier) Notify(ctx context.Context, alert Alert) error {
+	icon := ":rotating_light:"
+	if alert.State == AlertRecovered {
+		icon = ":white_check_mark:"
+	}
+	return postJSON(ctx, n.client, n.url, slackMessage{Text: fmt.Sprintf("%s %s", icon, alert.Message)})
+}
+
+// postJSON posts payload as JSON and fails on non-2xx responses
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
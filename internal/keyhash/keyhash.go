@@ -0,0 +1,189 @@
+package keyhash
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported hash algorithms
+const (
+	SHA256   = "sha256"
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// keyPrefix starts every generated key; the prefix that identifies a key is
+// keyPrefix followed by prefixLength hex characters
+const (
+	keyPrefix    = "snap_"
+	prefixLength = 8
+	secretBytes  = 20
+)
+
+// Argon2id parameters for new hashes (OWASP recommended minimum)
+const (
+	argon2Memory  = 19 * 1024
+	argon2Time    = 2
+	argon2Threads = 1
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// Generate mints a random key of the form snap_<prefix>_<secret> and returns it with its prefix
+func Generate() (key, prefix string, err error) {
+	buf := make([]byte, prefixLength/2+secretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	prefix = keyPrefix + hex.EncodeToString(buf[:prefixLength/2])
+	return prefix + "_" + hex.EncodeToString(buf[prefixLength/2:]), prefix, nil
+}
+
+// Prefix returns the lookup prefix of a generated key, or "" for keys in another format
+func Prefix(key string) string {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return ""
+	}
+	prefix, _, found := strings.Cut(key[len(keyPrefix):], "_")
+	if !found || len(prefix) != prefixLength {
+		return ""
+	}
+	return keyPrefix + prefix
+}
+
+// Hash hashes key with the given algorithm
+func Hash(algorithm, key string) (string, error) {
+	switch algorithm {
+	case SHA256:
+		digest := sha256.Sum256([]byte(key))
+		return SHA256 + ":" + hex.EncodeToString(digest[:]), nil
+	case Argon2id:
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("failed to generate salt: %w", err)
+		}
+		derived := argon2.IDKey([]byte(key), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(derived)), nil
+	case Bcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(key), bcrypt.DefaultCost)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash key: %w", err)
+		}
+		return string(hash), nil
+	default:
+		return "", fmt.Errorf("unsupported hash algorithm %q", algorithm)
+	}
+}
+
+// Algorithm returns the algorithm of a stored hash, or an error if it is malformed
+func Algorithm(hash string) (string, error) {
+	switch {
+	case strings.HasPrefix(hash, SHA256+":"):
+		if _, err := decodeSHA256(hash); err != nil {
+			return "", err
+		}
+		return SHA256, nil
+	case strings.HasPrefix(hash, "$argon2id$"):
+		if _, err := parseArgon2id(hash); err != nil {
+			return "", err
+		}
+		return Argon2id, nil
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return "", fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+		return Bcrypt, nil
+	default:
+		return "", errors.New("unsupported hash: expected sha256:<hex>, $argon2id$... or bcrypt")
+	}
+}
+
+// Slow reports whether verifying the hash is expensive enough to require a prefix lookup
+func Slow(algorithm string) bool {
+	return algorithm == Argon2id || algorithm == Bcrypt
+}
+
+// SHA256Digest returns the raw digest of a sha256:<hex> hash
+func SHA256Digest(hash string) ([sha256.Size]byte, error) {
+	return decodeSHA256(hash)
+}
+
+// Verify reports whether key matches hash. Comparisons are constant-time.
+func Verify(hash, key string) bool {
+	algorithm, err := Algorithm(hash)
+	if err != nil {
+		return false
+	}
+
+	switch algorithm {
+	case SHA256:
+		expected, _ := decodeSHA256(hash)
+		actual := sha256.Sum256([]byte(key))
+		return subtle.ConstantTimeCompare(expected[:], actual[:]) == 1
+	case Argon2id:
+		params, _ := parseArgon2id(hash)
+		derived := argon2.IDKey([]byte(key), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+		return subtle.ConstantTimeCompare(params.key, derived) == 1
+	case Bcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(key)) == nil
+	default:
+		return false
+	}
+}
+
+func decodeSHA256(hash string) ([sha256.Size]byte, error) {
+	var digest [sha256.Size]byte
+	decoded, err := hex.DecodeString(strings.TrimPrefix(hash, SHA256+":"))
+	if err != nil || len(decoded) != sha256.Size {
+		return digest, errors.New("invalid sha256 hash: expected 64 hex characters")
+	}
+	copy(digest[:], decoded)
+	return digest, nil
+}
+
+// argon2Params are the parts of a PHC-formatted argon2id hash
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// parseArgon2id parses $argon2id$v=19$m=...,t=...,p=...$salt$key
+func parseArgon2id(hash string) (*argon2Params, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, errors.New("invalid argon2id hash: expected $argon2id$v=19$m=,t=,p=$salt$key")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("invalid argon2id hash: unsupported version %q", parts[2])
+	}
+
+	params := &argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters %q", parts[3])
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errors.New("invalid argon2id salt")
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, errors.New("invalid argon2id key")
+	}
+	return params, nil
+}
@@ -0,0 +1,109 @@
+package keyhash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	key, prefix, err := Generate()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(key, prefix+"_") || len(key) != len("snap_")+8+1+40 {
+		t.Errorf("Unexpected key %q with prefix %q", key, prefix)
+	}
+	if Prefix(key) != prefix {
+		t.Errorf("Expected prefix %q, got %q", prefix, Prefix(key))
+	}
+
+	other, _, _ := Generate()
+	if other == key {
+		t.Error("Expected distinct keys")
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{key: "snap_0123abcd_secret", expected: "snap_0123abcd"},
+		{key: "snap_0123_secret", expected: ""},
+		{key: "snap_0123abcd", expected: ""},
+		{key: "legacy-key", expected: ""},
+		{key: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if result := Prefix(tt.key); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHashVerify(t *testing.T) {
+	for _, algorithm := range []string{SHA256, Argon2id, Bcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			hash, err := Hash(algorithm, "snap_0123abcd_secret")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			detected, err := Algorithm(hash)
+			if err != nil || detected != algorithm {
+				t.Errorf("Expected algorithm %s, got %s (%v)", algorithm, detected, err)
+			}
+			if !Verify(hash, "snap_0123abcd_secret") {
+				t.Error("Expected key to match its hash")
+			}
+			if Verify(hash, "snap_0123abcd_other") {
+				t.Error("Expected other key not to match")
+			}
+		})
+	}
+}
+
+func TestVerify_KnownHashes(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "sha256", hash: "sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"},
+		{name: "bcrypt", hash: "$2a$04$9yc04bP0Q./B/.hedHZYPOlW.U/N5nXjqFpTvLfTvX6KfXzScOm4S"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !Verify(tt.hash, "secret") {
+				t.Errorf("Expected %q to verify", tt.hash)
+			}
+		})
+	}
+}
+
+func TestAlgorithm_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"secret",
+		"sha256:abcd",
+		"sha256:" + strings.Repeat("zz", 32),
+		"$argon2id$v=18$m=19456,t=2,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=19456$c2FsdA$a2V5",
+		"$argon2id$v=19$m=19456,t=2,p=1$!!$a2V5",
+		"$2a$10$short",
+	}
+
+	for _, hash := range tests {
+		t.Run(hash, func(t *testing.T) {
+			if _, err := Algorithm(hash); err == nil {
+				t.Errorf("Expected error for %q", hash)
+			}
+			if Verify(hash, "secret") {
+				t.Errorf("Expected %q not to verify", hash)
+			}
+		})
+	}
+}
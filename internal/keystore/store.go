@@ -0,0 +1,159 @@
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/keyhash"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Errors returned by a Store
+var (
+	ErrNotFound = errors.New("key not found")
+	ErrExists   = errors.New("key already exists")
+)
+
+// Store persists API keys managed at runtime. Keys are stored with their hash
+// only; the plaintext is shown once when a key is created or rotated.
+type Store interface {
+	List() ([]config.APIKey, error)
+	Get(id string) (config.APIKey, error)
+	// Create adds a key, failing with ErrExists if the id is taken
+	Create(key config.APIKey) error
+	// Update applies update to a key atomically, failing with ErrNotFound if it does not exist
+	Update(id string, update func(key *config.APIKey) error) error
+	Close() error
+}
+
+// keysBucket holds one JSON APIKey per key id
+var keysBucket = []byte("keys")
+
+// BoltStore keeps API keys in a BoltDB file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens or creates the key database at path
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(keysBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize key store: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// List returns every key ordered by id
+func (s *BoltStore) List() ([]config.APIKey, error) {
+	var keys []config.APIKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(keysBucket).ForEach(func(id, value []byte) error {
+			var key config.APIKey
+			if err := json.Unmarshal(value, &key); err != nil {
+				return fmt.Errorf("invalid key %s: %w", id, err)
+			}
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	return keys, err
+}
+
+// Get returns the key with the given id
+func (s *BoltStore) Get(id string) (config.APIKey, error) {
+	var key config.APIKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(keysBucket).Get([]byte(id))
+		if value == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(value, &key)
+	})
+	return key, err
+}
+
+// Create adds a key
+func (s *BoltStore) Create(key config.APIKey) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keysBucket)
+		if bucket.Get([]byte(key.ID)) != nil {
+			return ErrExists
+		}
+		return put(bucket, key)
+	})
+}
+
+// Update applies update to a key in one transaction
+func (s *BoltStore) Update(id string, update func(key *config.APIKey) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(keysBucket)
+		value := bucket.Get([]byte(id))
+		if value == nil {
+			return ErrNotFound
+		}
+
+		var key config.APIKey
+		if err := json.Unmarshal(value, &key); err != nil {
+			return fmt.Errorf("invalid key %s: %w", id, err)
+		}
+		if err := update(&key); err != nil {
+			return err
+		}
+		key.ID = id
+		return put(bucket, key)
+	})
+}
+
+// Import adds the keys whose id is not yet in store and returns their ids. Plaintext
+// keys are stored as SHA-256 hashes. Keys already in store are left untouched, so
+// changes made through the admin API survive a restart.
+func Import(store Store, keys []config.APIKey) ([]string, error) {
+	var imported []string
+	for _, key := range keys {
+		if key.Hash == "" {
+			hash, err := keyhash.Hash(keyhash.SHA256, key.Key)
+			if err != nil {
+				return imported, fmt.Errorf("failed to hash key %s: %w", key.ID, err)
+			}
+			key.Hash, key.Prefix, key.Key = hash, keyhash.Prefix(key.Key), ""
+		}
+
+		err := store.Create(key)
+		if errors.Is(err, ErrExists) {
+			continue
+		}
+		if err != nil {
+			return imported, fmt.Errorf("failed to import key %s: %w", key.ID, err)
+		}
+		imported = append(imported, key.ID)
+	}
+	return imported, nil
+}
+
+// Close closes the database file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func put(bucket *bolt.Bucket, key config.APIKey) error {
+	// Never persist a plaintext key
+	key.Key = ""
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key.ID), data)
+}
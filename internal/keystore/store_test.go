@@ -0,0 +1,121 @@
+package keystore
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/config"
+)
+
+func openTestStore(t *testing.T) *BoltStore {
+	t.Helper()
+	store, err := OpenBoltStore(filepath.Join(t.TempDir(), "keys.db"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestBoltStore(t *testing.T) {
+	store := openTestStore(t)
+
+	key := config.APIKey{ID: "partner", Owner: "ops@partner.io", Key: "plaintext", Networks: []string{"mainnet"}}
+	if err := store.Create(key); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := store.Create(key); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists, got %v", err)
+	}
+	if err := store.Create(config.APIKey{ID: "dashboard"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stored, err := store.Get("partner")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stored.Owner != "ops@partner.io" || stored.Networks[0] != "mainnet" {
+		t.Errorf("Unexpected key: %+v", stored)
+	}
+	if stored.Key != "" {
+		t.Error("Expected the plaintext key not to be persisted")
+	}
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = store.Update("partner", func(key *config.APIKey) error {
+		key.ExpiresAt = expiresAt
+		key.ID = "renamed"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stored, _ := store.Get("partner"); !stored.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected updated expiry, got %+v", stored)
+	}
+
+	failed := errors.New("rejected")
+	if err := store.Update("partner", func(key *config.APIKey) error {
+		key.Disabled = true
+		return failed
+	}); !errors.Is(err, failed) {
+		t.Errorf("Expected update error, got %v", err)
+	}
+	if stored, _ := store.Get("partner"); stored.Disabled {
+		t.Error("Expected a failed update not to be saved")
+	}
+
+	if _, err := store.Get("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := store.Update("unknown", func(key *config.APIKey) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	keys, err := store.List()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "dashboard" || keys[1].ID != "partner" {
+		t.Errorf("Expected keys ordered by id, got %+v", keys)
+	}
+}
+
+func TestImport(t *testing.T) {
+	store := openTestStore(t)
+
+	if err := store.Create(config.APIKey{ID: "partner", Owner: "ops@partner.io", Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []config.APIKey{
+		{ID: "partner", Key: "partner-key"},
+		{ID: "dashboard", Key: "dashboard-key", Networks: []string{"mainnet"}},
+	}
+	imported, err := Import(store, keys)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(imported) != 1 || imported[0] != "dashboard" {
+		t.Errorf("Expected only dashboard to be imported, got %v", imported)
+	}
+
+	// Keys already in the store keep the changes made through the admin API
+	partner, _ := store.Get("partner")
+	if !partner.Disabled || partner.Hash != "" {
+		t.Errorf("Expected the stored partner key to be unchanged, got %+v", partner)
+	}
+
+	dashboard, _ := store.Get("dashboard")
+	if dashboard.Key != "" || !dashboard.Matches("dashboard-key") || dashboard.Networks[0] != "mainnet" {
+		t.Errorf("Expected the imported key to be hashed, got %+v", dashboard)
+	}
+
+	// Importing again is a no-op
+	if imported, _ := Import(store, keys); len(imported) != 0 {
+		t.Errorf("Expected nothing to import, got %v", imported)
+	}
+}
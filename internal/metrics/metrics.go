@@ -0,0 +1,277 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric exported by the service
+const namespace = "snapshots_api"
+
+// Cache lookup results
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheStale = "stale"
+)
+
+// Metrics holds the Prometheus collectors of the service. All methods are safe
+// to call on a nil *Metrics so instrumentation stays optional.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+
+	fetchDuration prometheus.Histogram
+	fetchErrors   prometheus.Counter
+
+	cacheRequests *prometheus.CounterVec
+
+	listedObjects *prometheus.GaugeVec
+
+	latestBlock     *prometheus.GaugeVec
+	latestTimestamp *prometheus.GaugeVec
+
+	snapshotAge *prometheus.GaugeVec
+	streamStale *prometheus.GaugeVec
+
+	rateLimited *prometheus.CounterVec
+}
+
+// New creates the service metrics on a dedicated registry
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		fetchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_fetch_duration_seconds",
+			Help:      "Duration of storage bucket listings.",
+			Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}),
+		fetchErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "storage_fetch_errors_total",
+			Help:      "Failed storage bucket listings.",
+		}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Snapshot cache lookups by result (hit, miss, stale).",
+		}, []string{"result"}),
+		listedObjects: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "listed_objects",
+			Help:      "Objects in the last storage listing by parse result (parsed, skipped).",
+		}, []string{"result"}),
+		latestBlock: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "latest_snapshot_block",
+			Help:      "Block number of the latest snapshot by network and type.",
+		}, []string{"network", "type"}),
+		latestTimestamp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "latest_snapshot_timestamp_seconds",
+			Help:      "Unix time of the latest snapshot by network and type.",
+		}, []string{"network", "type"}),
+		snapshotAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "latest_snapshot_age_seconds",
+			Help:      "Age of the latest snapshot at the last freshness check by network and type.",
+		}, []string{"network", "type"}),
+		streamStale: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "snapshot_stream_stale",
+			Help:      "Whether the snapshot stream breaches its freshness threshold (1) or not (0).",
+		}, []string{"network", "type"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limited_requests_total",
+			Help:      "Requests rejected by the rate limiter by client kind (anonymous, key).",
+		}, []string{"client"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestsTotal,
+		m.requestDuration,
+		m.fetchDuration,
+		m.fetchErrors,
+		m.cacheRequests,
+		m.listedObjects,
+		m.latestBlock,
+		m.latestTimestamp,
+		m.snapshotAge,
+		m.streamStale,
+		m.rateLimited,
+	)
+
+	return m
+}
+
+// Registry returns the registry the metrics are registered on
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler returns the /metrics HTTP handler
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterCacheAge exports the age of the snapshot cache as reported by ageFunc
+func (m *Metrics) RegisterCacheAge(ageFunc func() time.Duration) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_age_seconds",
+		Help:      "Seconds since the snapshot cache was last refreshed successfully.",
+	}, func() float64 {
+		return ageFunc().Seconds()
+	}))
+}
+
+// ObserveFetch records the duration and outcome of a storage listing
+func (m *Metrics) ObserveFetch(duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.fetchDuration.Observe(duration.Seconds())
+	if err != nil {
+		m.fetchErrors.Inc()
+	}
+}
+
+// ObserveCache records a snapshot cache lookup result
+func (m *Metrics) ObserveCache(result string) {
+	if m == nil {
+		return
+	}
+	m.cacheRequests.WithLabelValues(result).Inc()
+}
+
+// ObserveListing records how many listed objects were parsed as snapshots and how many were skipped
+func (m *Metrics) ObserveListing(parsed, skipped int) {
+	if m == nil {
+		return
+	}
+	m.listedObjects.WithLabelValues("parsed").Set(float64(parsed))
+	m.listedObjects.WithLabelValues("skipped").Set(float64(skipped))
+}
+
+// ObserveLatest records the latest snapshot of a network and type
+func (m *Metrics) ObserveLatest(network, snapshotType string, block int64, timestamp time.Time) {
+	if m == nil {
+		return
+	}
+	m.latestBlock.WithLabelValues(network, snapshotType).Set(float64(block))
+	m.latestTimestamp.WithLabelValues(network, snapshotType).Set(float64(timestamp.Unix()))
+}
+
+// ObserveFreshness records the result of a freshness check for a network and type
+func (m *Metrics) ObserveFreshness(network, snapshotType string, age time.Duration, stale bool) {
+	if m == nil {
+		return
+	}
+	m.snapshotAge.WithLabelValues(network, snapshotType).Set(age.Seconds())
+	value := 0.0
+	if stale {
+		value = 1
+	}
+	m.streamStale.WithLabelValues(network, snapshotType).Set(value)
+}
+
+// ObserveRateLimited counts a request rejected by the rate limiter
+func (m *Metrics) ObserveRateLimited(client string) {
+	if m == nil {
+		return
+	}
+	m.rateLimited.WithLabelValues(client).Inc()
+}
+
+// Middleware records request counts and latency. Routes are labelled with the
+// ServeMux pattern that matched, or "unmatched" for unknown paths, and methods
+// outside the standard set are labelled "other".
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		method := methodLabel(r.Method)
+		status := strconv.Itoa(recorder.status)
+
+		m.requestsTotal.WithLabelValues(route, method, status).Inc()
+		m.requestDuration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// methodLabel bounds the method label, since clients can send any method token
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush forwards to the underlying writer so streaming responses keep working
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
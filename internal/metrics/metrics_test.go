@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_Middleware(t *testing.T) {
+	m := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/networks/{network}/snapshots", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+	handler := m.Middleware(mux)
+
+	requests := []string{
+		"/v1/networks/mainnet/snapshots",
+		"/v1/networks/testnet/snapshots",
+		"/missing",
+		"/unknown",
+	}
+	for _, path := range requests {
+		req := httptest.NewRequest("GET", path, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		route    string
+		status   string
+		expected float64
+	}{
+		{"GET /v1/networks/{network}/snapshots", "200", 2},
+		{"GET /missing", "404", 1},
+		{"unmatched", "404", 1},
+	}
+
+	for _, tt := range tests {
+		got := testutil.ToFloat64(m.requestsTotal.WithLabelValues(tt.route, "GET", tt.status))
+		if got != tt.expected {
+			t.Errorf("requests for route %q status %s = %v, want %v", tt.route, tt.status, got, tt.expected)
+		}
+	}
+
+	if count := testutil.CollectAndCount(m.requestDuration); count != 3 {
+		t.Errorf("Expected 3 latency series, got %d", count)
+	}
+}
+
+func TestMetrics_Middleware_Methods(t *testing.T) {
+	m := New()
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, method := range []string{"GET", "DELETE", "PROPFIND", "X-RANDOM-1", "X-RANDOM-2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+	}
+
+	tests := []struct {
+		method   string
+		expected float64
+	}{
+		{"GET", 1},
+		{"DELETE", 1},
+		{"other", 3},
+		{"PROPFIND", 0},
+	}
+
+	for _, tt := range tests {
+		got := testutil.ToFloat64(m.requestsTotal.WithLabelValues("unmatched", tt.method, "200"))
+		if got != tt.expected {
+			t.Errorf("requests for method %q = %v, want %v", tt.method, got, tt.expected)
+		}
+	}
+}
+
+func TestMetrics_Observe(t *testing.T) {
+	m := New()
+
+	m.ObserveFetch(100*time.Millisecond, nil)
+	m.ObserveFetch(200*time.Millisecond, errors.New("storage unavailable"))
+	m.ObserveCache(CacheHit)
+	m.ObserveCache(CacheHit)
+	m.ObserveCache(CacheMiss)
+	m.ObserveListing(10, 3)
+	m.ObserveLatest("mainnet", "full", 19547931, time.Unix(1751783254, 0))
+	m.RegisterCacheAge(func() time.Duration { return 42 * time.Second })
+
+	if got := testutil.ToFloat64(m.fetchErrors); got != 1 {
+		t.Errorf("fetch errors = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.cacheRequests.WithLabelValues(CacheHit)); got != 2 {
+		t.Errorf("cache hits = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.listedObjects.WithLabelValues("skipped")); got != 3 {
+		t.Errorf("skipped objects = %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(m.latestBlock.WithLabelValues("mainnet", "full")); got != 19547931 {
+		t.Errorf("latest block = %v, want 19547931", got)
+	}
+
+	expected := `
+# HELP snapshots_api_cache_age_seconds Seconds since the snapshot cache was last refreshed successfully.
+# TYPE snapshots_api_cache_age_seconds gauge
+snapshots_api_cache_age_seconds 42
+`
+	if err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected), "snapshots_api_cache_age_seconds"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+
+	// Instrumentation must be optional
+	m.ObserveFetch(time.Second, nil)
+	m.ObserveCache(CacheHit)
+	m.ObserveListing(1, 1)
+	m.ObserveLatest("mainnet", "full", 1, time.Now())
+	m.RegisterCacheAge(func() time.Duration { return 0 })
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if handler := m.Middleware(next); handler == nil {
+		t.Error("Expected Middleware to return the wrapped handler")
+	}
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := New()
+	m.ObserveCache(CacheMiss)
+
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), `snapshots_api_cache_requests_total{result="miss"} 1`) {
+		t.Error("Expected cache miss counter in /metrics output")
+	}
+}
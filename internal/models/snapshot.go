@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
 
 // SnapshotType represents the type of snapshot (full or light)
 type SnapshotType string
@@ -19,6 +23,9 @@ const (
 	NetworkDevnet  Network = "devnet"
 )
 
+// TimestampFormat is the layout of SnapshotInfo.Timestamp
+const TimestampFormat = "2006-01-02 15:04"
+
 // Snapshot represents a single snapshot file
 type Snapshot struct {
 	Network   Network      `json:"-"`
@@ -27,13 +34,46 @@ type Snapshot struct {
 	Timestamp time.Time    `json:"-"`
 	URL       string       `json:"url"`
 	Filename  string       `json:"-"`
+	Size      int64        `json:"-"`
+	Updated   time.Time    `json:"-"`
+	MD5       []byte       `json:"-"`
+	CRC32C    []byte       `json:"-"`
+	Mirrors   []MirrorURL  `json:"-"`
+}
+
+// Mirror is a server that hosts copies of the snapshot files under BaseURL
+type Mirror struct {
+	Name    string
+	Region  string
+	BaseURL string
+	// Weight is the share of snapshots the mirror serves among mirrors of its region
+	Weight int
+}
+
+// MirrorURL is the download URL of a snapshot on one mirror
+type MirrorURL struct {
+	Name   string `json:"name"`
+	Region string `json:"region,omitempty"`
+	URL    string `json:"url"`
+}
+
+// Checksum represents a digest in both hex and base64 encodings
+type Checksum struct {
+	Hex    string `json:"hex"`
+	Base64 string `json:"base64"`
 }
 
 // SnapshotInfo represents the formatted timestamp for API response
 type SnapshotInfo struct {
-	Block     int64  `json:"block"`
-	Timestamp string `json:"timestamp"`
-	URL       string `json:"url"`
+	Block     int64     `json:"block"`
+	Timestamp string    `json:"timestamp"`
+	URL       string    `json:"url"`
+	Size      int64     `json:"size,omitempty"`
+	Updated   string    `json:"updated,omitempty"`
+	MD5       *Checksum `json:"md5,omitempty"`
+	CRC32C    *Checksum `json:"crc32c,omitempty"`
+	// Mirrors lists the URLs of the snapshot on every configured mirror
+	Mirrors []MirrorURL `json:"mirrors,omitempty"`
 }
 
 // NetworkSnapshots represents snapshots for a specific network
@@ -42,13 +82,78 @@ type NetworkSnapshots struct {
 	Light         *SnapshotInfo  `json:"light,omitempty"`
 	PreviousLight []SnapshotInfo `json:"previous-light,omitempty"`
 	PreviousFull  []SnapshotInfo `json:"previous-full,omitempty"`
+	// Stale is set when the data could not be refreshed from storage recently
+	Stale bool `json:"stale,omitempty"`
+}
+
+// SnapshotPage represents one page of a snapshot history listing
+type SnapshotPage struct {
+	Snapshots []SnapshotInfo `json:"snapshots"`
+	// NextCursor is the block number to pass as cursor for the next page, or nil on the last page
+	NextCursor *int64 `json:"next-cursor,omitempty"`
+}
+
+// Filter returns a copy holding only the snapshot types for which include returns true
+func (n *NetworkSnapshots) Filter(include func(SnapshotType) bool) *NetworkSnapshots {
+	filtered := &NetworkSnapshots{Stale: n.Stale}
+	if include(SnapshotTypeFull) {
+		filtered.Full = n.Full
+		filtered.PreviousFull = n.PreviousFull
+	}
+	if include(SnapshotTypeLight) {
+		filtered.Light = n.Light
+		filtered.PreviousLight = n.PreviousLight
+	}
+	return filtered
+}
+
+// LastModified returns the timestamp of the newest snapshot, or the zero time if there is none
+func (n *NetworkSnapshots) LastModified() time.Time {
+	var newest time.Time
+	for _, info := range []*SnapshotInfo{n.Full, n.Light} {
+		if timestamp := info.Time(); timestamp.After(newest) {
+			newest = timestamp
+		}
+	}
+	return newest
+}
+
+// Time parses Timestamp, returning the zero time for a nil or malformed snapshot
+func (s *SnapshotInfo) Time() time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	timestamp, err := time.Parse(TimestampFormat, s.Timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return timestamp
 }
 
 // ToSnapshotInfo converts a Snapshot to SnapshotInfo with formatted timestamp
 func (s *Snapshot) ToSnapshotInfo() *SnapshotInfo {
-	return &SnapshotInfo{
+	info := &SnapshotInfo{
 		Block:     s.Block,
-		Timestamp: s.Timestamp.Format("2006-01-02 15:04"),
+		Timestamp: s.Timestamp.Format(TimestampFormat),
 		URL:       s.URL,
+		Size:      s.Size,
+		MD5:       newChecksum(s.MD5),
+		CRC32C:    newChecksum(s.CRC32C),
+		Mirrors:   s.Mirrors,
+	}
+	if !s.Updated.IsZero() {
+		info.Updated = s.Updated.UTC().Format(time.RFC3339)
+	}
+	return info
+}
+
+// newChecksum encodes a raw digest, returning nil when the digest is unknown
+func newChecksum(digest []byte) *Checksum {
+	if len(digest) == 0 {
+		return nil
+	}
+	return &Checksum{
+		Hex:    hex.EncodeToString(digest),
+		Base64: base64.StdEncoding.EncodeToString(digest),
 	}
 }
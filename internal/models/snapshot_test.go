@@ -31,6 +31,126 @@ func TestSnapshot_ToSnapshotInfo(t *testing.T) {
 	if result.URL != "https://example.com/snapshot.tar.gz" {
 		t.Errorf("Expected URL %s, got %s", "https://example.com/snapshot.tar.gz", result.URL)
 	}
+
+	// Object metadata is omitted when the backend did not report it
+	if result.Size != 0 || result.Updated != "" || result.MD5 != nil || result.CRC32C != nil {
+		t.Errorf("Expected no object metadata, got %+v", result)
+	}
+	if result.Mirrors != nil {
+		t.Errorf("Expected no mirrors, got %+v", result.Mirrors)
+	}
+
+	snapshot.Mirrors = []MirrorURL{{Name: "sg", Region: "asia", URL: "https://sg.example.com/snapshot.tar.gz"}}
+	if mirrors := snapshot.ToSnapshotInfo().Mirrors; len(mirrors) != 1 || mirrors[0] != snapshot.Mirrors[0] {
+		t.Errorf("Expected mirrors %+v, got %+v", snapshot.Mirrors, mirrors)
+	}
+}
+
+func TestSnapshot_ToSnapshotInfo_ObjectMetadata(t *testing.T) {
+	snapshot := &Snapshot{
+		Block:     12345,
+		Timestamp: time.Date(2025, 7, 6, 14, 30, 45, 0, time.UTC),
+		URL:       "https://example.com/snapshot.tar.gz",
+		Size:      1073741824,
+		Updated:   time.Date(2025, 7, 6, 14, 35, 0, 0, time.UTC),
+		MD5:       []byte{0x5e, 0xb6, 0x3b, 0xbb, 0xe0, 0x1e, 0xee, 0xd0, 0x93, 0xcb, 0x22, 0xbb, 0x8f, 0x5a, 0xcd, 0xc3},
+		CRC32C:    []byte{0xc9, 0x94, 0x65, 0xaa},
+	}
+
+	result := snapshot.ToSnapshotInfo()
+
+	if result.Size != 1073741824 {
+		t.Errorf("Expected size 1073741824, got %d", result.Size)
+	}
+
+	if result.Updated != "2025-07-06T14:35:00Z" {
+		t.Errorf("Expected updated 2025-07-06T14:35:00Z, got %s", result.Updated)
+	}
+
+	if result.MD5 == nil {
+		t.Fatal("Expected MD5 checksum")
+	}
+	if result.MD5.Hex != "5eb63bbbe01eeed093cb22bb8f5acdc3" {
+		t.Errorf("Unexpected MD5 hex %s", result.MD5.Hex)
+	}
+	if result.MD5.Base64 != "XrY7u+Ae7tCTyyK7j1rNww==" {
+		t.Errorf("Unexpected MD5 base64 %s", result.MD5.Base64)
+	}
+
+	if result.CRC32C == nil {
+		t.Fatal("Expected CRC32C checksum")
+	}
+	if result.CRC32C.Hex != "c99465aa" {
+		t.Errorf("Unexpected CRC32C hex %s", result.CRC32C.Hex)
+	}
+	if result.CRC32C.Base64 != "yZRlqg==" {
+		t.Errorf("Unexpected CRC32C base64 %s", result.CRC32C.Base64)
+	}
+}
+
+func TestNetworkSnapshots_Filter(t *testing.T) {
+	snapshots := &NetworkSnapshots{
+		Full:          &SnapshotInfo{Block: 200},
+		PreviousFull:  []SnapshotInfo{{Block: 100}},
+		Light:         &SnapshotInfo{Block: 250},
+		PreviousLight: []SnapshotInfo{{Block: 150}},
+		Stale:         true,
+	}
+
+	light := snapshots.Filter(func(snapshotType SnapshotType) bool {
+		return snapshotType == SnapshotTypeLight
+	})
+	if light.Full != nil || light.PreviousFull != nil {
+		t.Error("Expected full snapshots to be removed")
+	}
+	if light.Light.Block != 250 || len(light.PreviousLight) != 1 || !light.Stale {
+		t.Errorf("Expected light snapshots and stale flag to be kept, got %+v", light)
+	}
+	if snapshots.Full == nil {
+		t.Error("Expected the original to be unchanged")
+	}
+
+	none := snapshots.Filter(func(SnapshotType) bool { return false })
+	if none.Full != nil || none.Light != nil {
+		t.Errorf("Expected no snapshots, got %+v", none)
+	}
+}
+
+func TestNetworkSnapshots_LastModified(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots NetworkSnapshots
+		expected  time.Time
+	}{
+		{
+			name:     "empty",
+			expected: time.Time{},
+		},
+		{
+			name: "newest of full and light",
+			snapshots: NetworkSnapshots{
+				Full:  &SnapshotInfo{Timestamp: "2025-07-06 14:30"},
+				Light: &SnapshotInfo{Timestamp: "2025-07-07 09:15"},
+			},
+			expected: time.Date(2025, 7, 7, 9, 15, 0, 0, time.UTC),
+		},
+		{
+			name: "malformed timestamp ignored",
+			snapshots: NetworkSnapshots{
+				Full:  &SnapshotInfo{Timestamp: "yesterday"},
+				Light: &SnapshotInfo{Timestamp: "2025-07-06 14:30"},
+			},
+			expected: time.Date(2025, 7, 6, 14, 30, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.snapshots.LastModified(); !got.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
 }
 
 func TestNetworkConstants(t *testing.T) {
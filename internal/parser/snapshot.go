@@ -14,6 +14,7 @@ import (
 type SnapshotParser struct {
 	// Regex pattern for snapshot filename: <network>-<full/light>-db-block-<blocknumber>-<timestamp>.tar.gz
 	pattern *regexp.Regexp
+	mirrors []models.Mirror
 }
 
 // NewSnapshotParser creates a new snapshot parser
@@ -25,6 +26,12 @@ func NewSnapshotParser() *SnapshotParser {
 	}
 }
 
+// SetMirrors adds the URLs on these mirrors to every parsed snapshot. It must be
+// called before the parser is used.
+func (p *SnapshotParser) SetMirrors(mirrors []models.Mirror) {
+	p.mirrors = mirrors
+}
+
 // ParseSnapshot parses a snapshot filename and returns a Snapshot struct
 func (p *SnapshotParser) ParseSnapshot(filename, baseURL string) (*models.Snapshot, error) {
 	matches := p.pattern.FindStringSubmatch(filename)
@@ -52,6 +59,15 @@ func (p *SnapshotParser) ParseSnapshot(filename, baseURL string) (*models.Snapsh
 	// Construct public URL
 	url := fmt.Sprintf("%s/%s", strings.TrimSuffix(baseURL, "/o"), filename)
 
+	var mirrors []models.MirrorURL
+	for _, mirror := range p.mirrors {
+		mirrors = append(mirrors, models.MirrorURL{
+			Name:   mirror.Name,
+			Region: mirror.Region,
+			URL:    fmt.Sprintf("%s/%s", strings.TrimSuffix(mirror.BaseURL, "/"), filename),
+		})
+	}
+
 	return &models.Snapshot{
 		Network:   network,
 		Type:      snapshotType,
@@ -59,6 +75,7 @@ func (p *SnapshotParser) ParseSnapshot(filename, baseURL string) (*models.Snapsh
 		Timestamp: timestamp,
 		URL:       url,
 		Filename:  filename,
+		Mirrors:   mirrors,
 	}, nil
 }
 
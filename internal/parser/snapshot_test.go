@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
@@ -132,6 +133,30 @@ func TestSnapshotParser_ParseSnapshot(t *testing.T) {
 	}
 }
 
+func TestSnapshotParser_Mirrors(t *testing.T) {
+	parser := NewSnapshotParser()
+	parser.SetMirrors([]models.Mirror{
+		{Name: "sg", Region: "asia", BaseURL: "https://sg.example.com/snapshots", Weight: 2},
+		{Name: "fra", BaseURL: "https://fra.example.com/", Weight: 1},
+	})
+
+	snapshot, err := parser.ParseSnapshot("mainnet-full-db-block-19547931-20250706-062734.tar.gz", "https://storage.googleapis.com/taraxa-snapshot")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if snapshot.URL != "https://storage.googleapis.com/taraxa-snapshot/mainnet-full-db-block-19547931-20250706-062734.tar.gz" {
+		t.Errorf("Expected the bucket URL, got %s", snapshot.URL)
+	}
+	expected := []models.MirrorURL{
+		{Name: "sg", Region: "asia", URL: "https://sg.example.com/snapshots/mainnet-full-db-block-19547931-20250706-062734.tar.gz"},
+		{Name: "fra", URL: "https://fra.example.com/mainnet-full-db-block-19547931-20250706-062734.tar.gz"},
+	}
+	if !reflect.DeepEqual(snapshot.Mirrors, expected) {
+		t.Errorf("Expected mirrors %+v, got %+v", expected, snapshot.Mirrors)
+	}
+}
+
 func TestSnapshotParser_IsValidNetwork(t *testing.T) {
 	parser := NewSnapshotParser()
 
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: it holds up to Burst tokens and refills Rate tokens per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available when not Allowed
+	RetryAfter time.Duration
+}
+
+// Backend stores token buckets. The in-memory backend limits each replica on its
+// own; a shared implementation (e.g. Redis) lets replicas enforce one limit together.
+type Backend interface {
+	// Take removes one token from the bucket identified by key
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Peek returns the state of the bucket identified by key without taking a token
+	Peek(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// sweepInterval is how often the memory backend drops buckets that have refilled
+const sweepInterval = time.Minute
+
+// bucket is the state of one token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// MemoryBackend keeps token buckets in process memory
+type MemoryBackend struct {
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryBackend creates an empty in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take removes one token from the bucket for key, creating a full bucket on first use
+func (b *MemoryBackend) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.now()
+	b.sweep(now)
+
+	current, ok := b.buckets[key]
+	if !ok || current.limit != limit {
+		current = &bucket{tokens: float64(limit.Burst), updated: now, limit: limit}
+		b.buckets[key] = current
+	}
+
+	current.refill(now)
+	return current.take(), nil
+}
+
+// Peek returns the state of the bucket for key; a missing bucket is full
+func (b *MemoryBackend) Peek(ctx context.Context, key string, limit Limit) (Result, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	current, ok := b.buckets[key]
+	if !ok || current.limit != limit {
+		return Result{Allowed: true, Remaining: limit.Burst}, nil
+	}
+
+	current.refill(b.now())
+	return current.peek(), nil
+}
+
+// sweep drops buckets that are full again, which behave exactly like new ones
+func (b *MemoryBackend) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < sweepInterval {
+		return
+	}
+	b.lastSweep = now
+
+	for key, current := range b.buckets {
+		current.refill(now)
+		if current.tokens >= float64(current.limit.Burst) {
+			delete(b.buckets, key)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	}
+	b.updated = now
+}
+
+func (b *bucket) take() Result {
+	result := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / b.limit.Rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((float64(b.limit.Burst) - b.tokens) / b.limit.Rate)
+	return result
+}
+
+func (b *bucket) peek() Result {
+	result := Result{Allowed: b.tokens >= 1}
+	if !result.Allowed {
+		result.RetryAfter = seconds((1 - b.tokens) / b.limit.Rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((float64(b.limit.Burst) - b.tokens) / b.limit.Rate)
+	return result
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
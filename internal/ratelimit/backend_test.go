@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackend_Take(t *testing.T) {
+	now := time.Date(2025, 7, 6, 0, 0, 0, 0, time.UTC)
+	backend := NewMemoryBackend()
+	backend.now = func() time.Time { return now }
+
+	// 3 requests per 3 seconds: one token per second, bursts of 3
+	limit := Limit{Rate: 1, Burst: 3}
+	take := func() Result {
+		t.Helper()
+		result, err := backend.Take(context.Background(), "ip:192.0.2.1", limit)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return result
+	}
+
+	for i := 2; i >= 0; i-- {
+		result := take()
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("Expected allowed with %d remaining, got %+v", i, result)
+		}
+	}
+
+	result := take()
+	if result.Allowed || result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Errorf("Expected rejection with 1s retry and 3s reset, got %+v", result)
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if result := take(); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Expected a refilled token, got %+v", result)
+	}
+	if result := take(); result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Expected rejection with 500ms retry, got %+v", result)
+	}
+
+	if result, _ := backend.Peek(context.Background(), "ip:192.0.2.1", limit); result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Expected peek to report the empty bucket, got %+v", result)
+	}
+	now = now.Add(time.Second)
+	if result, _ := backend.Peek(context.Background(), "ip:192.0.2.1", limit); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("Expected peek to report a token, got %+v", result)
+	}
+	if result, _ := backend.Peek(context.Background(), "ip:192.0.2.1", limit); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("Expected peek not to take the token, got %+v", result)
+	}
+
+	// Other clients have their own bucket
+	if result, _ := backend.Take(context.Background(), "ip:192.0.2.2", limit); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("Expected a separate bucket, got %+v", result)
+	}
+}
+
+func TestMemoryBackend_Sweep(t *testing.T) {
+	now := time.Date(2025, 7, 6, 0, 0, 0, 0, time.UTC)
+	backend := NewMemoryBackend()
+	backend.now = func() time.Time { return now }
+
+	backend.Take(context.Background(), "idle", Limit{Rate: 1, Burst: 1})
+	backend.Take(context.Background(), "busy", Limit{Rate: 0.001, Burst: 1})
+
+	now = now.Add(2 * sweepInterval)
+	backend.Take(context.Background(), "new", Limit{Rate: 1, Burst: 5})
+
+	if _, ok := backend.buckets["idle"]; ok {
+		t.Error("Expected refilled bucket to be dropped")
+	}
+	if _, ok := backend.buckets["busy"]; !ok {
+		t.Error("Expected draining bucket to be kept")
+	}
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// minBandwidthBurst keeps reads large enough to be efficient at low rates
+const minBandwidthBurst = 32 * 1024
+
+// Bandwidth throttles the bytes sent to each client. Concurrent downloads of one
+// client share its rate, so opening more connections does not raise the limit.
+type Bandwidth struct {
+	rate  float64
+	burst int
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+	sleep     func(ctx context.Context, d time.Duration) error
+}
+
+// NewBandwidth creates a throttle allowing bytesPerSecond per client with bursts
+// of one second
+func NewBandwidth(bytesPerSecond int64) *Bandwidth {
+	burst := int(bytesPerSecond)
+	if burst < minBandwidthBurst {
+		burst = minBandwidthBurst
+	}
+	return &Bandwidth{
+		rate:    float64(bytesPerSecond),
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+		sleep:   sleepContext,
+	}
+}
+
+// Reader returns r throttled to the rate of client. It is safe to call on a nil
+// *Bandwidth, which returns r unchanged.
+func (b *Bandwidth) Reader(ctx context.Context, client string, r io.Reader) io.Reader {
+	if b == nil {
+		return r
+	}
+	return &throttledReader{ctx: ctx, bandwidth: b, client: client, reader: r}
+}
+
+// wait takes n bytes from the bucket of client, sleeping until the bucket has
+// refilled when it is overdrawn. The debt is shared by all readers of the client.
+func (b *Bandwidth) wait(ctx context.Context, client string, n int) error {
+	b.mutex.Lock()
+	now := b.now()
+	b.sweep(now)
+
+	current, ok := b.buckets[client]
+	if !ok {
+		current = &bucket{tokens: float64(b.burst), updated: now, limit: Limit{Rate: b.rate, Burst: b.burst}}
+		b.buckets[client] = current
+	}
+	current.refill(now)
+	current.tokens -= float64(n)
+	debt := current.tokens
+	b.mutex.Unlock()
+
+	if debt >= 0 {
+		return nil
+	}
+	return b.sleep(ctx, seconds(-debt/b.rate))
+}
+
+// sweep drops buckets that are full again, like MemoryBackend.sweep
+func (b *Bandwidth) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < sweepInterval {
+		return
+	}
+	b.lastSweep = now
+
+	for client, current := range b.buckets {
+		current.refill(now)
+		if current.tokens >= float64(current.limit.Burst) {
+			delete(b.buckets, client)
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader reads at most one burst at a time and waits for the bytes it read
+type throttledReader struct {
+	ctx       context.Context
+	bandwidth *Bandwidth
+	client    string
+	reader    io.Reader
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > r.bandwidth.burst {
+		p = p[:r.bandwidth.burst]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.bandwidth.wait(r.ctx, r.client, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBandwidth_Reader(t *testing.T) {
+	now := time.Date(2025, 7, 6, 0, 0, 0, 0, time.UTC)
+	var slept time.Duration
+	bandwidth := NewBandwidth(64 * 1024)
+	bandwidth.now = func() time.Time { return now }
+	bandwidth.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		now = now.Add(d)
+		return ctx.Err()
+	}
+
+	download := func(client string, size int) time.Duration {
+		t.Helper()
+		slept = 0
+		n, err := io.Copy(io.Discard, bandwidth.Reader(context.Background(), client, bytes.NewReader(make([]byte, size))))
+		if err != nil || n != int64(size) {
+			t.Fatalf("Expected %d bytes, got %d (%v)", size, n, err)
+		}
+		return slept
+	}
+
+	// The first second is a free burst; the remaining 192 KiB take three seconds
+	if got := download("key:partner", 256*1024); got != 3*time.Second {
+		t.Errorf("Expected 3s of throttling, got %v", got)
+	}
+	// The bucket is empty, so a second download of the same key waits right away
+	if got := download("key:partner", 64*1024); got != time.Second {
+		t.Errorf("Expected the same key to share its rate, got %v", got)
+	}
+	// Other clients have their own bucket
+	if got := download("ip:192.0.2.1", 64*1024); got != 0 {
+		t.Errorf("Expected a separate bucket, got %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := io.Copy(io.Discard, bandwidth.Reader(ctx, "key:partner", bytes.NewReader(make([]byte, 128*1024)))); err != context.Canceled {
+		t.Errorf("Expected cancellation to stop the download, got %v", err)
+	}
+}
+
+func TestBandwidth_Nil(t *testing.T) {
+	var bandwidth *Bandwidth
+	reader := bytes.NewReader([]byte("snapshot"))
+	if bandwidth.Reader(context.Background(), "key:partner", reader) != reader {
+		t.Error("Expected a nil Bandwidth to return the reader unchanged")
+	}
+}
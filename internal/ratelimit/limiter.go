@@ -0,0 +1,240 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/config"
+	"github.com/taraxa/snapshots-api/internal/metrics"
+)
+
+// exemptPaths are probes and scrapes that must never be throttled
+var exemptPaths = map[string]bool{
+	"/health":  true,
+	"/ready":   true,
+	"/metrics": true,
+}
+
+// failedAuthPrefix keys the buckets of failed authentications per client IP
+const failedAuthPrefix = "auth-failed:"
+
+// Limiter throttles requests per client IP for anonymous callers and per API key
+// for authenticated ones. A zero Limit disables throttling for that caller.
+type Limiter struct {
+	backend        Backend
+	anonymous      Limit
+	tiers          map[string]Limit
+	trustedProxies []netip.Prefix
+	metrics        *metrics.Metrics
+}
+
+// NewLimiter creates a limiter. tiers are keyed by the tier of an API key; keys
+// with an unknown or empty tier use config.DefaultTier.
+func NewLimiter(backend Backend, anonymous Limit, tiers map[string]Limit) *Limiter {
+	return &Limiter{
+		backend:   backend,
+		anonymous: anonymous,
+		tiers:     tiers,
+	}
+}
+
+// FromConfig converts a configured rate limit into a token bucket that allows
+// bursts of the full request count
+func FromConfig(limit config.RateLimit) Limit {
+	if limit.Unlimited || limit.Requests <= 0 || limit.Period <= 0 {
+		return Limit{}
+	}
+	return Limit{Rate: float64(limit.Requests) / limit.Period.Seconds(), Burst: limit.Requests}
+}
+
+// SetTrustedProxies sets the IPs and CIDRs whose X-Forwarded-For header is trusted
+func (l *Limiter) SetTrustedProxies(proxies []string) error {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	l.trustedProxies = prefixes
+	return nil
+}
+
+// HasTier reports whether a rate limit tier is configured. A nil *Limiter throttles
+// nothing and accepts any tier.
+func (l *Limiter) HasTier(tier string) bool {
+	if l == nil {
+		return true
+	}
+	_, ok := l.tiers[tier]
+	return ok
+}
+
+// SetMetrics enables counting of throttled requests
+func (l *Limiter) SetMetrics(m *metrics.Metrics) {
+	l.metrics = m
+}
+
+// Middleware throttles requests and sets the RateLimit-* headers. It must run
+// inside auth.Middleware.Authenticate so the caller is known. It is safe to call
+// on a nil *Limiter, which does not throttle.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exemptPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, limit, client := l.bucketFor(r)
+		if limit.Rate <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if client == "anonymous" && r.Header.Get("Authorization") != "" {
+			// The credentials did not authenticate; once a client runs out of these,
+			// AuthGuard rejects its credentials without verifying them
+			if _, err := l.backend.Take(r.Context(), failedAuthPrefix+l.ClientIP(r), limit); err != nil {
+				log.Printf("Rate limit backend error, not counting failed authentication: %v", err)
+			}
+		}
+
+		result, err := l.backend.Take(r.Context(), key, limit)
+		if err != nil {
+			// Fail open: an unavailable backend must not take the API down
+			log.Printf("Rate limit backend error, allowing request: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(seconds(float64(limit.Burst)/limit.Rate))))
+
+		if !result.Allowed {
+			l.metrics.ObserveRateLimited(client)
+			writeRateLimited(w, result.RetryAfter)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AuthGuard rejects requests carrying credentials from client IPs that used up their
+// failed authentications, before the credentials are verified, so invalid API keys and
+// JWTs cannot make the server run slow hashes and signature checks without bound.
+// Failures are counted by Middleware against the anonymous limit. AuthGuard must run
+// outside auth.Middleware.Authenticate. It is safe to call on a nil *Limiter.
+func (l *Limiter) AuthGuard(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.anonymous.Rate <= 0 || exemptPaths[r.URL.Path] || r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := l.backend.Peek(r.Context(), failedAuthPrefix+l.ClientIP(r), l.anonymous)
+		if err != nil {
+			log.Printf("Rate limit backend error, allowing request: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !result.Allowed {
+			l.metrics.ObserveRateLimited("auth")
+			writeRateLimited(w, result.RetryAfter)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeRateLimited writes the 429 response of a throttled request
+func writeRateLimited(w http.ResponseWriter, retryAfter time.Duration) {
+	header := w.Header()
+	header.Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+	header.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(`{"error": "rate_limited", "message": "rate limit exceeded, retry after the Retry-After delay"}`))
+}
+
+// bucketFor returns the bucket key and limit of a request and whether the caller
+// is an API key or an anonymous client
+func (l *Limiter) bucketFor(r *http.Request) (string, Limit, string) {
+	if principal, _ := auth.PrincipalFromContext(r.Context()); principal != nil {
+		limit, ok := l.tiers[principal.Tier]
+		if !ok {
+			limit = l.tiers[config.DefaultTier]
+		}
+		return "key:" + principal.ID, limit, "key"
+	}
+	return "ip:" + l.ClientIP(r), l.anonymous, "anonymous"
+}
+
+// ClientIP returns the address of the client. X-Forwarded-For is only honored
+// when the connection comes from a trusted proxy; it is then read from the right,
+// skipping trusted proxies, so clients cannot spoof their address by prepending entries.
+// A nil *Limiter trusts no proxies.
+func (l *Limiter) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !l.trusted(remote) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if !l.trusted(hop) {
+			return hop.Unmap().String()
+		}
+		remote = hop
+	}
+	return remote.Unmap().String()
+}
+
+func (l *Limiter) trusted(addr netip.Addr) bool {
+	if l == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range l.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ceilSeconds rounds a duration up to whole seconds for headers
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
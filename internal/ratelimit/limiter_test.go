@@ -0,0 +1,226 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/config"
+)
+
+// failingBackend always returns an error
+type failingBackend struct{}
+
+func (failingBackend) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	return Result{}, errors.New("backend unavailable")
+}
+
+func (failingBackend) Peek(ctx context.Context, key string, limit Limit) (Result, error) {
+	return Result{}, errors.New("backend unavailable")
+}
+
+func TestFromConfig(t *testing.T) {
+	if limit := FromConfig(config.RateLimit{Requests: 120, Period: time.Minute}); limit.Rate != 2 || limit.Burst != 120 {
+		t.Errorf("Unexpected limit: %+v", limit)
+	}
+	if limit := FromConfig(config.RateLimit{Unlimited: true}); limit != (Limit{}) {
+		t.Errorf("Expected zero limit for unlimited, got %+v", limit)
+	}
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	cfg := &config.Config{
+		APIKeys: []string{"default-key"},
+		Keys: []config.APIKey{
+			{ID: "partner", Key: "partner-key", Tier: "partner"},
+			{ID: "internal", Key: "internal-key", Tier: "internal"},
+		},
+	}
+	limiter := NewLimiter(NewMemoryBackend(), Limit{Rate: 1, Burst: 2}, map[string]Limit{
+		config.DefaultTier: {Rate: 1, Burst: 3},
+		"partner":          {Rate: 1, Burst: 5},
+		"internal":         {},
+	})
+	handler := auth.NewMiddleware(cfg).Authenticate(limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	// allowed counts the requests let through before the first 429
+	allowed := func(path, key, remoteAddr string) (int, *httptest.ResponseRecorder) {
+		for i := 0; i < 10; i++ {
+			req := httptest.NewRequest("GET", path, nil)
+			req.RemoteAddr = remoteAddr
+			if key != "" {
+				req.Header.Set("Authorization", "Bearer "+key)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code == http.StatusTooManyRequests {
+				return i, rec
+			}
+		}
+		return 10, nil
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		key        string
+		remoteAddr string
+		expected   int
+	}{
+		{name: "anonymous", path: "/v1/networks", remoteAddr: "192.0.2.1:1234", expected: 2},
+		{name: "other anonymous client", path: "/v1/networks", remoteAddr: "192.0.2.2:1234", expected: 2},
+		{name: "default tier", path: "/v1/networks", key: "default-key", remoteAddr: "192.0.2.1:1234", expected: 3},
+		{name: "partner tier", path: "/v1/networks", key: "partner-key", remoteAddr: "192.0.2.1:1234", expected: 5},
+		{name: "unlimited tier", path: "/v1/networks", key: "internal-key", remoteAddr: "192.0.2.1:1234", expected: 10},
+		{name: "invalid key counts as anonymous", path: "/v1/networks", key: "wrong", remoteAddr: "192.0.2.3:1234", expected: 2},
+		{name: "health probes are exempt", path: "/health", remoteAddr: "192.0.2.4:1234", expected: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, rejected := allowed(tt.path, tt.key, tt.remoteAddr)
+			if count != tt.expected {
+				t.Fatalf("Expected %d requests allowed, got %d", tt.expected, count)
+			}
+			if rejected == nil {
+				return
+			}
+
+			if retryAfter := rejected.Header().Get("Retry-After"); retryAfter != "1" {
+				t.Errorf("Expected Retry-After 1, got %q", retryAfter)
+			}
+			if remaining := rejected.Header().Get("RateLimit-Remaining"); remaining != "0" {
+				t.Errorf("Expected RateLimit-Remaining 0, got %q", remaining)
+			}
+			if policy := rejected.Header().Get("RateLimit-Policy"); policy == "" || rejected.Header().Get("RateLimit-Limit") == "" || rejected.Header().Get("RateLimit-Reset") == "" {
+				t.Errorf("Expected RateLimit headers, got %v", rejected.Header())
+			}
+			if contentType := rejected.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("Expected JSON error body, got %q", contentType)
+			}
+		})
+	}
+}
+
+func TestLimiter_AuthGuard(t *testing.T) {
+	cfg := &config.Config{APIKeys: []string{"valid-key"}}
+	limiter := NewLimiter(NewMemoryBackend(), Limit{Rate: 0.001, Burst: 2}, map[string]Limit{
+		config.DefaultTier: {Rate: 1, Burst: 100},
+	})
+	authenticate := auth.NewMiddleware(cfg).Authenticate(limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	verified := 0
+	handler := limiter.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verified++
+		authenticate.ServeHTTP(w, r)
+	}))
+
+	request := func(key, remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/v1/networks", nil)
+		req.RemoteAddr = remoteAddr
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 5; i++ {
+		request("wrong", "192.0.2.1:1234")
+	}
+	if verified != 2 {
+		t.Errorf("Expected only 2 invalid keys to be verified, got %d", verified)
+	}
+	if code := request("valid-key", "192.0.2.1:1234"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected credentials from the blocked client to be rejected, got %d", code)
+	}
+
+	// Valid keys do not use up the failed authentications of their client
+	for i := 0; i < 5; i++ {
+		if code := request("valid-key", "192.0.2.2:1234"); code != http.StatusOK {
+			t.Fatalf("Request %d: expected valid key to be allowed, got %d", i+1, code)
+		}
+	}
+}
+
+func TestLimiter_Middleware_Headers(t *testing.T) {
+	limiter := NewLimiter(NewMemoryBackend(), Limit{Rate: 2, Burst: 120}, nil)
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/networks", nil))
+
+	expected := map[string]string{
+		"RateLimit-Limit":     "120",
+		"RateLimit-Remaining": "119",
+		"RateLimit-Reset":     "1",
+		"RateLimit-Policy":    "120;w=60",
+	}
+	for header, value := range expected {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("Expected %s %q, got %q", header, value, got)
+		}
+	}
+}
+
+func TestLimiter_Middleware_BackendError(t *testing.T) {
+	limiter := NewLimiter(failingBackend{}, Limit{Rate: 1, Burst: 1}, nil)
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/networks", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected requests to be allowed when the backend fails, got %d", rec.Code)
+	}
+}
+
+func TestLimiter_ClientIP(t *testing.T) {
+	limiter := NewLimiter(NewMemoryBackend(), Limit{}, nil)
+	if err := limiter.SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		expectedIP   string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.5:1234", expectedIP: "203.0.113.5"},
+		{name: "untrusted proxy is ignored", remoteAddr: "203.0.113.5:1234", forwardedFor: "198.51.100.1", expectedIP: "203.0.113.5"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:1234", forwardedFor: "198.51.100.1", expectedIP: "198.51.100.1"},
+		{name: "proxy chain", remoteAddr: "10.1.2.3:1234", forwardedFor: "198.51.100.1, 192.168.1.1", expectedIP: "198.51.100.1"},
+		{name: "spoofed entries are skipped", remoteAddr: "10.1.2.3:1234", forwardedFor: "1.1.1.1, 198.51.100.1", expectedIP: "198.51.100.1"},
+		{name: "trusted proxy without header", remoteAddr: "10.1.2.3:1234", expectedIP: "10.1.2.3"},
+		{name: "malformed header", remoteAddr: "10.1.2.3:1234", forwardedFor: "unknown", expectedIP: "10.1.2.3"},
+		{name: "ipv6 client", remoteAddr: "[2001:db8::1]:1234", expectedIP: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if ip := limiter.ClientIP(req); ip != tt.expectedIP {
+				t.Errorf("Expected %s, got %s", tt.expectedIP, ip)
+			}
+		})
+	}
+
+	if err := limiter.SetTrustedProxies([]string{"proxy.local"}); err == nil {
+		t.Error("Expected error for a hostname")
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// ErrSnapshotNotFound is returned when no snapshot matches a lookup
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// History page size limits
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// typeIndex holds every snapshot of one network and type
+type typeIndex struct {
+	// byBlock is sorted by block number (descending), then by timestamp (descending)
+	byBlock []*models.Snapshot
+	// byTime is sorted by timestamp (descending), then by block number (descending)
+	byTime []*models.Snapshot
+	// byFilename maps the file name of each snapshot, without any storage prefix, to it
+	byFilename map[string]*models.Snapshot
+}
+
+// snapshotIndex holds the full sorted snapshot history per network and type
+type snapshotIndex map[models.Network]map[models.SnapshotType]*typeIndex
+
+// buildIndex groups snapshots by network and type and sorts each group for binary search
+func buildIndex(snapshots []*models.Snapshot) snapshotIndex {
+	index := make(snapshotIndex)
+
+	for _, snapshot := range snapshots {
+		if _, exists := index[snapshot.Network]; !exists {
+			index[snapshot.Network] = make(map[models.SnapshotType]*typeIndex)
+		}
+		entry, exists := index[snapshot.Network][snapshot.Type]
+		if !exists {
+			entry = &typeIndex{byFilename: make(map[string]*models.Snapshot)}
+			index[snapshot.Network][snapshot.Type] = entry
+		}
+		entry.byBlock = append(entry.byBlock, snapshot)
+		entry.byTime = append(entry.byTime, snapshot)
+		entry.byFilename[path.Base(snapshot.Filename)] = snapshot
+	}
+
+	for _, types := range index {
+		for _, entry := range types {
+			sort.Slice(entry.byBlock, func(i, j int) bool {
+				if entry.byBlock[i].Block != entry.byBlock[j].Block {
+					return entry.byBlock[i].Block > entry.byBlock[j].Block
+				}
+				return entry.byBlock[i].Timestamp.After(entry.byBlock[j].Timestamp)
+			})
+			sort.Slice(entry.byTime, func(i, j int) bool {
+				if !entry.byTime[i].Timestamp.Equal(entry.byTime[j].Timestamp) {
+					return entry.byTime[i].Timestamp.After(entry.byTime[j].Timestamp)
+				}
+				return entry.byTime[i].Block > entry.byTime[j].Block
+			})
+		}
+	}
+
+	return index
+}
+
+// added returns the snapshots that are not in the index, oldest first. Snapshots are
+// matched by file name against the whole history, not only the latest few.
+func (index snapshotIndex) added(snapshots []*models.Snapshot) []*models.Snapshot {
+	var added []*models.Snapshot
+	for _, snapshot := range snapshots {
+		if entry := index[snapshot.Network][snapshot.Type]; entry != nil {
+			if _, known := entry.byFilename[path.Base(snapshot.Filename)]; known {
+				continue
+			}
+		}
+		added = append(added, snapshot)
+	}
+
+	sort.SliceStable(added, func(i, j int) bool {
+		if !added[i].Timestamp.Equal(added[j].Timestamp) {
+			return added[i].Timestamp.Before(added[j].Timestamp)
+		}
+		return added[i].Block < added[j].Block
+	})
+	return added
+}
+
+// lookup returns the index entry for a network and type, or nil if there is none
+func (s *SnapshotService) lookup(network models.Network, snapshotType models.SnapshotType) (*typeIndex, error) {
+	if _, err := s.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.index[network][snapshotType], nil
+}
+
+// FindByBlock returns the newest snapshot at or below block, or the oldest
+// snapshot at or above block when above is set
+func (s *SnapshotService) FindByBlock(network models.Network, snapshotType models.SnapshotType, block int64, above bool) (*models.SnapshotInfo, error) {
+	entry, err := s.lookup(network, snapshotType)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrSnapshotNotFound
+	}
+
+	snapshots := entry.byBlock
+	var i int
+	if above {
+		// Last snapshot whose block is still >= the target
+		i = sort.Search(len(snapshots), func(i int) bool {
+			return snapshots[i].Block < block
+		}) - 1
+	} else {
+		// First snapshot whose block is <= the target
+		i = sort.Search(len(snapshots), func(i int) bool {
+			return snapshots[i].Block <= block
+		})
+	}
+
+	if i < 0 || i >= len(snapshots) {
+		return nil, ErrSnapshotNotFound
+	}
+	return snapshots[i].ToSnapshotInfo(), nil
+}
+
+// FindByFilename returns the snapshot stored under filename, which may be any
+// snapshot in the history, not only the newest at its block
+func (s *SnapshotService) FindByFilename(network models.Network, snapshotType models.SnapshotType, filename string) (*models.SnapshotInfo, error) {
+	entry, err := s.lookup(network, snapshotType)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrSnapshotNotFound
+	}
+
+	snapshot, found := entry.byFilename[filename]
+	if !found {
+		return nil, ErrSnapshotNotFound
+	}
+	return snapshot.ToSnapshotInfo(), nil
+}
+
+// FindBefore returns the newest snapshot taken at or before the given time
+func (s *SnapshotService) FindBefore(network models.Network, snapshotType models.SnapshotType, before time.Time) (*models.SnapshotInfo, error) {
+	entry, err := s.lookup(network, snapshotType)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrSnapshotNotFound
+	}
+
+	snapshots := entry.byTime
+	i := sort.Search(len(snapshots), func(i int) bool {
+		return !snapshots[i].Timestamp.After(before)
+	})
+
+	if i >= len(snapshots) {
+		return nil, ErrSnapshotNotFound
+	}
+	return snapshots[i].ToSnapshotInfo(), nil
+}
+
+// ListSnapshots returns one page of the snapshot history of a network and type,
+// newest first. A positive cursor starts the page below that block number. Snapshots
+// sharing a block number are never split across pages, so a page may exceed limit.
+func (s *SnapshotService) ListSnapshots(network models.Network, snapshotType models.SnapshotType, cursor int64, limit int) (*models.SnapshotPage, error) {
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	entry, err := s.lookup(network, snapshotType)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.SnapshotPage{Snapshots: []models.SnapshotInfo{}}
+	if entry == nil {
+		return page, nil
+	}
+
+	snapshots := entry.byBlock
+	start := 0
+	if cursor > 0 {
+		start = sort.Search(len(snapshots), func(i int) bool {
+			return snapshots[i].Block < cursor
+		})
+	}
+
+	end := start + limit
+	if end >= len(snapshots) {
+		end = len(snapshots)
+	} else {
+		// Extend the page over snapshots sharing the last block so the cursor skips none
+		for end < len(snapshots) && snapshots[end].Block == snapshots[end-1].Block {
+			end++
+		}
+	}
+
+	for _, snapshot := range snapshots[start:end] {
+		page.Snapshots = append(page.Snapshots, *snapshot.ToSnapshotInfo())
+	}
+
+	if end < len(snapshots) {
+		next := snapshots[end-1].Block
+		page.NextCursor = &next
+	}
+
+	return page, nil
+}
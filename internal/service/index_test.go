@@ -0,0 +1,279 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/storage"
+)
+
+func newIndexedService() *SnapshotService {
+	lister := &fakeLister{
+		objects: []storage.Object{
+			{Name: "mainnet-full-db-block-100-20250701-000000.tar.gz"},
+			{Name: "mainnet-full-db-block-200-20250702-000000.tar.gz"},
+			{Name: "mainnet-full-db-block-300-20250703-000000.tar.gz"},
+			{Name: "mainnet-full-db-block-300-20250703-120000.tar.gz"},
+			{Name: "mainnet-full-db-block-400-20250704-000000.tar.gz"},
+			{Name: "mainnet-full-db-block-500-20250705-000000.tar.gz"},
+			{Name: "mainnet-light-db-block-450-20250705-000000.tar.gz"},
+		},
+	}
+	return NewSnapshotServiceWithLister(lister)
+}
+
+func TestSnapshotService_FindByBlock(t *testing.T) {
+	service := newIndexedService()
+
+	tests := []struct {
+		name              string
+		network           models.Network
+		snapshotType      models.SnapshotType
+		block             int64
+		above             bool
+		expectedNotFound  bool
+		expectedBlock     int64
+		expectedTimestamp string
+	}{
+		{
+			name:          "exact match",
+			network:       models.NetworkMainnet,
+			snapshotType:  models.SnapshotTypeFull,
+			block:         200,
+			expectedBlock: 200,
+		},
+		{
+			name:          "newest at or below",
+			network:       models.NetworkMainnet,
+			snapshotType:  models.SnapshotTypeFull,
+			block:         250,
+			expectedBlock: 200,
+		},
+		{
+			name:              "same block prefers latest timestamp",
+			network:           models.NetworkMainnet,
+			snapshotType:      models.SnapshotTypeFull,
+			block:             350,
+			expectedBlock:     300,
+			expectedTimestamp: "2025-07-03 12:00",
+		},
+		{
+			name:          "above latest returns latest",
+			network:       models.NetworkMainnet,
+			snapshotType:  models.SnapshotTypeFull,
+			block:         10000,
+			expectedBlock: 500,
+		},
+		{
+			name:             "below oldest",
+			network:          models.NetworkMainnet,
+			snapshotType:     models.SnapshotTypeFull,
+			block:            50,
+			expectedNotFound: true,
+		},
+		{
+			name:          "nearest above",
+			network:       models.NetworkMainnet,
+			snapshotType:  models.SnapshotTypeFull,
+			block:         250,
+			above:         true,
+			expectedBlock: 300,
+		},
+		{
+			name:          "nearest above exact match",
+			network:       models.NetworkMainnet,
+			snapshotType:  models.SnapshotTypeFull,
+			block:         400,
+			above:         true,
+			expectedBlock: 400,
+		},
+		{
+			name:             "nearest above past latest",
+			network:          models.NetworkMainnet,
+			snapshotType:     models.SnapshotTypeFull,
+			block:            501,
+			above:            true,
+			expectedNotFound: true,
+		},
+		{
+			name:             "unknown network",
+			network:          models.NetworkDevnet,
+			snapshotType:     models.SnapshotTypeFull,
+			block:            100,
+			expectedNotFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.FindByBlock(tt.network, tt.snapshotType, tt.block, tt.above)
+
+			if tt.expectedNotFound {
+				if !errors.Is(err, ErrSnapshotNotFound) {
+					t.Errorf("Expected ErrSnapshotNotFound, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Block != tt.expectedBlock {
+				t.Errorf("Expected block %d, got %d", tt.expectedBlock, result.Block)
+			}
+			if tt.expectedTimestamp != "" && result.Timestamp != tt.expectedTimestamp {
+				t.Errorf("Expected timestamp %s, got %s", tt.expectedTimestamp, result.Timestamp)
+			}
+		})
+	}
+}
+
+func TestSnapshotService_FindBefore(t *testing.T) {
+	service := newIndexedService()
+
+	tests := []struct {
+		name             string
+		before           time.Time
+		expectedNotFound bool
+		expectedBlock    int64
+	}{
+		{
+			name:          "exact timestamp",
+			before:        time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC),
+			expectedBlock: 200,
+		},
+		{
+			name:          "between snapshots",
+			before:        time.Date(2025, 7, 3, 6, 0, 0, 0, time.UTC),
+			expectedBlock: 300,
+		},
+		{
+			name:          "after latest",
+			before:        time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			expectedBlock: 500,
+		},
+		{
+			name:             "before oldest",
+			before:           time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+			expectedNotFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.FindBefore(models.NetworkMainnet, models.SnapshotTypeFull, tt.before)
+
+			if tt.expectedNotFound {
+				if !errors.Is(err, ErrSnapshotNotFound) {
+					t.Errorf("Expected ErrSnapshotNotFound, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Block != tt.expectedBlock {
+				t.Errorf("Expected block %d, got %d", tt.expectedBlock, result.Block)
+			}
+		})
+	}
+}
+
+func TestSnapshotService_FindByFilename(t *testing.T) {
+	service := newIndexedService()
+
+	tests := []struct {
+		name              string
+		filename          string
+		expectedNotFound  bool
+		expectedTimestamp string
+	}{
+		{name: "newest at its block", filename: "mainnet-full-db-block-300-20250703-120000.tar.gz", expectedTimestamp: "2025-07-03 12:00"},
+		{name: "older at its block", filename: "mainnet-full-db-block-300-20250703-000000.tar.gz", expectedTimestamp: "2025-07-03 00:00"},
+		{name: "unknown timestamp", filename: "mainnet-full-db-block-300-20250703-060000.tar.gz", expectedNotFound: true},
+		{name: "other type", filename: "mainnet-light-db-block-450-20250705-000000.tar.gz", expectedNotFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.FindByFilename(models.NetworkMainnet, models.SnapshotTypeFull, tt.filename)
+
+			if tt.expectedNotFound {
+				if !errors.Is(err, ErrSnapshotNotFound) {
+					t.Errorf("Expected ErrSnapshotNotFound, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Timestamp != tt.expectedTimestamp {
+				t.Errorf("Expected timestamp %s, got %s", tt.expectedTimestamp, result.Timestamp)
+			}
+		})
+	}
+}
+
+func TestSnapshotService_ListSnapshots(t *testing.T) {
+	service := newIndexedService()
+
+	// Walk the full history two snapshots at a time
+	var blocks []int64
+	var pages int
+	var cursor int64
+	for {
+		page, err := service.ListSnapshots(models.NetworkMainnet, models.SnapshotTypeFull, cursor, 2)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		pages++
+
+		for _, snapshot := range page.Snapshots {
+			blocks = append(blocks, snapshot.Block)
+		}
+
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+
+		if pages > 10 {
+			t.Fatal("Pagination did not terminate")
+		}
+	}
+
+	// Both snapshots at block 300 land on the same page
+	expectedBlocks := []int64{500, 400, 300, 300, 200, 100}
+	if len(blocks) != len(expectedBlocks) {
+		t.Fatalf("Expected blocks %v, got %v", expectedBlocks, blocks)
+	}
+	for i, expected := range expectedBlocks {
+		if blocks[i] != expected {
+			t.Errorf("Expected block %d at position %d, got %d", expected, i, blocks[i])
+		}
+	}
+
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+}
+
+func TestSnapshotService_ListSnapshots_Empty(t *testing.T) {
+	service := newIndexedService()
+
+	page, err := service.ListSnapshots(models.NetworkDevnet, models.SnapshotTypeLight, 0, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if page.Snapshots == nil || len(page.Snapshots) != 0 {
+		t.Errorf("Expected empty snapshot list, got %v", page.Snapshots)
+	}
+	if page.NextCursor != nil {
+		t.Errorf("Expected no next cursor, got %d", *page.NextCursor)
+	}
+}
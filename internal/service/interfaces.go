@@ -1,11 +1,18 @@
 package service
 
-import "github.com/taraxa/snapshots-api/internal/models"
+import (
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
 
 // SnapshotServiceInterface defines the contract for snapshot service
 type SnapshotServiceInterface interface {
 	GetSnapshots(network models.Network) (*models.NetworkSnapshots, error)
-	GetSnapshotsWithAuth(network models.Network, authenticated bool) (*models.NetworkSnapshots, error)
+	FindByBlock(network models.Network, snapshotType models.SnapshotType, block int64, above bool) (*models.SnapshotInfo, error)
+	FindByFilename(network models.Network, snapshotType models.SnapshotType, filename string) (*models.SnapshotInfo, error)
+	FindBefore(network models.Network, snapshotType models.SnapshotType, before time.Time) (*models.SnapshotInfo, error)
+	ListSnapshots(network models.Network, snapshotType models.SnapshotType, cursor int64, limit int) (*models.SnapshotPage, error)
 	IsValidNetwork(network string) bool
 	GetAllNetworks() []models.Network
 }
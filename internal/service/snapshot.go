@@ -1,132 +1,345 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
+	"log"
 	"sort"
 	"sync"
 	"time"
 
+	"golang.org/x/sync/singleflight"
+
+	"github.com/taraxa/snapshots-api/internal/metrics"
 	"github.com/taraxa/snapshots-api/internal/models"
 	"github.com/taraxa/snapshots-api/internal/parser"
+	"github.com/taraxa/snapshots-api/internal/storage"
+)
+
+// refreshKey is the singleflight key shared by every cache refresh
+const refreshKey = "refresh"
+
+// Default cache settings
+const (
+	DefaultRefreshInterval = 5 * time.Minute
+	DefaultStaleAfter      = 2 * DefaultRefreshInterval
+	// DefaultHistoryDepth is the number of previous snapshots listed per type
+	DefaultHistoryDepth = 3
 )
 
-// GCPStorageResponse represents the response from GCP Storage API
-type GCPStorageResponse struct {
-	Kind  string `json:"kind"`
-	Items []struct {
-		Name string `json:"name"`
-	} `json:"items"`
+// RefreshEvent describes a successful cache refresh
+type RefreshEvent struct {
+	Time time.Time
+	// Snapshots holds every snapshot parsed from the storage listing
+	Snapshots []*models.Snapshot
+	// Previous and Current hold the cached per-network responses before and after the refresh
+	Previous map[models.Network]*models.NetworkSnapshots
+	Current  map[models.Network]*models.NetworkSnapshots
+	// Added lists the snapshots that were not in the previous listing, oldest first.
+	// It is empty after the first refresh, which has no previous listing to compare against.
+	Added []*models.Snapshot
 }
 
+// RefreshListener is called after every successful cache refresh
+type RefreshListener func(event RefreshEvent)
+
 // SnapshotService handles snapshot operations
 type SnapshotService struct {
-	bucketName string
-	bucketURL  string
+	lister     storage.Lister
 	parser     *parser.SnapshotParser
 	cache      map[models.Network]*models.NetworkSnapshots
+	index      snapshotIndex
 	cacheTime  time.Time
+	lastError  error
 	mutex      sync.RWMutex
 	cacheTTL   time.Duration
+	staleAfter time.Duration
+	// historyDepth is the number of previous snapshots kept in the cached responses
+	historyDepth int
+	// refreshGroup coalesces concurrent refreshes into a single storage listing
+	refreshGroup singleflight.Group
+	metrics      *metrics.Metrics
+	listeners    []RefreshListener
+	// intervalChanged wakes Run when SetCacheOptions changes the refresh interval
+	intervalChanged chan struct{}
+	// ctx is the context of Run, so refreshes started by requests stop on shutdown
+	ctx context.Context
 }
 
-// NewSnapshotService creates a new snapshot service
+// NewSnapshotService creates a new snapshot service backed by a public GCS bucket
 func NewSnapshotService(bucketName, bucketURL string) *SnapshotService {
+	publicURL := fmt.Sprintf("https://storage.googleapis.com/%s", bucketName)
+	return NewSnapshotServiceWithLister(storage.NewGCSLister(bucketURL, publicURL, "", 0))
+}
+
+// NewSnapshotServiceWithLister creates a new snapshot service backed by any storage backend
+func NewSnapshotServiceWithLister(lister storage.Lister) *SnapshotService {
 	return &SnapshotService{
-		bucketName: bucketName,
-		bucketURL:  bucketURL,
-		parser:     parser.NewSnapshotParser(),
-		cache:      make(map[models.Network]*models.NetworkSnapshots),
-		cacheTTL:   5 * time.Minute, // Cache for 5 minutes
+		lister:       lister,
+		parser:       parser.NewSnapshotParser(),
+		cache:        make(map[models.Network]*models.NetworkSnapshots),
+		index:        make(snapshotIndex),
+		cacheTTL:     DefaultRefreshInterval,
+		staleAfter:   DefaultStaleAfter,
+		historyDepth: DefaultHistoryDepth,
+
+		intervalChanged: make(chan struct{}, 1),
+		ctx:             context.Background(),
 	}
 }
 
-// GetSnapshots retrieves snapshots for a specific network (backward compatibility)
-func (s *SnapshotService) GetSnapshots(network models.Network) (*models.NetworkSnapshots, error) {
-	return s.GetSnapshotsWithAuth(network, true)
+// SetCacheOptions configures how often the cache is refreshed and how old cached
+// data may get before responses are flagged as stale. Non-positive values keep
+// the current setting. It is safe to call while Run is active, e.g. on a config reload.
+func (s *SnapshotService) SetCacheOptions(refreshInterval, staleAfter time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if refreshInterval > 0 && refreshInterval != s.cacheTTL {
+		s.cacheTTL = refreshInterval
+		select {
+		case s.intervalChanged <- struct{}{}:
+		default:
+		}
+	}
+	if staleAfter > 0 {
+		s.staleAfter = staleAfter
+	}
+}
+
+// SetMetrics enables instrumentation of storage fetches and cache lookups
+func (s *SnapshotService) SetMetrics(m *metrics.Metrics) {
+	s.metrics = m
 }
 
-// GetSnapshotsWithAuth retrieves snapshots for a specific network with authentication filtering
-func (s *SnapshotService) GetSnapshotsWithAuth(network models.Network, authenticated bool) (*models.NetworkSnapshots, error) {
+// CacheAge returns the time since the cache was last refreshed successfully,
+// or zero if it was never populated
+func (s *SnapshotService) CacheAge() time.Duration {
 	s.mutex.RLock()
-	cached, exists := s.cache[network]
-	cacheValid := time.Since(s.cacheTime) < s.cacheTTL
-	s.mutex.RUnlock()
+	defer s.mutex.RUnlock()
 
-	if exists && cacheValid {
-		// If not authenticated, filter out full snapshots from cached data
-		if !authenticated {
-			filteredResult := &models.NetworkSnapshots{
-				Light:         cached.Light,
-				PreviousLight: cached.PreviousLight,
-				// Full and PreviousFull are omitted (nil) for unauthenticated requests
-			}
-			return filteredResult, nil
-		}
-		return cached, nil
+	if s.cacheTime.IsZero() {
+		return 0
 	}
+	return time.Since(s.cacheTime)
+}
 
-	// Fetch fresh data
-	snapshots, err := s.fetchSnapshots()
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch snapshots: %w", err)
+// OnRefresh registers a listener that is called, in registration order, after
+// every successful refresh. Listeners run on the refreshing goroutine and should
+// hand off slow work.
+func (s *SnapshotService) OnRefresh(listener RefreshListener) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.listeners = append(s.listeners, listener)
+}
+
+// SetHistoryDepth configures how many previous snapshots are listed per type in
+// the previous-full and previous-light arrays. Negative values keep the current
+// setting. The change applies from the next refresh.
+func (s *SnapshotService) SetHistoryDepth(depth int) {
+	if depth < 0 {
+		return
 	}
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// Update cache
-	s.cache = s.processSnapshots(snapshots)
-	s.cacheTime = time.Now()
+	s.historyDepth = depth
+}
 
-	result, exists := s.cache[network]
-	if !exists {
-		return &models.NetworkSnapshots{}, nil
+// SetMirrors lists the URLs of every snapshot on these mirrors. It must be called
+// before Run.
+func (s *SnapshotService) SetMirrors(mirrors []models.Mirror) {
+	s.parser.SetMirrors(mirrors)
+}
+
+// Run refreshes the cache immediately and then on every refresh interval until
+// ctx is cancelled. Failed refreshes keep the last good data in the cache.
+func (s *SnapshotService) Run(ctx context.Context) {
+	s.mutex.Lock()
+	s.ctx = ctx
+	s.mutex.Unlock()
+
+	s.refresh(ctx)
+
+	ticker := time.NewTicker(s.refreshInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.intervalChanged:
+			ticker.Reset(s.refreshInterval())
+		case <-ticker.C:
+			s.refresh(ctx)
+		}
 	}
+}
+
+func (s *SnapshotService) refreshInterval() time.Duration {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.cacheTTL
+}
 
-	// If not authenticated, filter out full snapshots
-	if !authenticated {
-		filteredResult := &models.NetworkSnapshots{
-			Light:         result.Light,
-			PreviousLight: result.PreviousLight,
-			// Full and PreviousFull are omitted (nil) for unauthenticated requests
+// runContext returns the context of Run, or the background context before Run starts
+func (s *SnapshotService) runContext() context.Context {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.ctx
+}
+
+// refresh fetches the snapshot listing and replaces the cache on success.
+// Concurrent callers share the result of a single in-flight refresh.
+func (s *SnapshotService) refresh(ctx context.Context) error {
+	_, err, _ := s.refreshGroup.Do(refreshKey, func() (interface{}, error) {
+		return nil, s.doRefresh(ctx)
+	})
+	return err
+}
+
+// doRefresh performs the actual listing and cache update
+func (s *SnapshotService) doRefresh(ctx context.Context) error {
+	start := time.Now()
+	snapshots, err := s.fetchSnapshots(ctx)
+	s.metrics.ObserveFetch(time.Since(start), err)
+	if err != nil {
+		log.Printf("Snapshot refresh failed, serving cached data: %v", err)
+		s.mutex.Lock()
+		s.lastError = err
+		s.mutex.Unlock()
+		return err
+	}
+
+	index := buildIndex(snapshots)
+	processed := s.processSnapshots(snapshots)
+
+	for network, types := range index {
+		for snapshotType, entry := range types {
+			latest := entry.byBlock[0]
+			s.metrics.ObserveLatest(string(network), string(snapshotType), latest.Block, latest.Timestamp)
+		}
+	}
+
+	now := time.Now()
+
+	s.mutex.Lock()
+	previous := s.cache
+	var added []*models.Snapshot
+	if !s.cacheTime.IsZero() {
+		added = s.index.added(snapshots)
+	}
+	s.cache = processed
+	s.index = index
+	s.cacheTime = now
+	s.lastError = nil
+	listeners := s.listeners
+	s.mutex.Unlock()
+
+	event := RefreshEvent{
+		Time:      now,
+		Snapshots: snapshots,
+		Previous:  previous,
+		Current:   processed,
+		Added:     added,
+	}
+	for _, listener := range listeners {
+		listener(event)
+	}
+
+	return nil
+}
+
+// revalidate refreshes the cache in the background, joining any refresh already in flight
+func (s *SnapshotService) revalidate() {
+	ctx := s.runContext()
+	s.refreshGroup.DoChan(refreshKey, func() (interface{}, error) {
+		return nil, s.doRefresh(ctx)
+	})
+}
+
+// ensureLoaded populates the cache on first use and triggers a background
+// revalidation once it has expired. It reports whether the cached data is stale.
+func (s *SnapshotService) ensureLoaded() (bool, error) {
+	s.mutex.RLock()
+	loaded := !s.cacheTime.IsZero()
+	s.mutex.RUnlock()
+
+	if !loaded {
+		s.metrics.ObserveCache(metrics.CacheMiss)
+		if err := s.refresh(s.runContext()); err != nil {
+			return false, fmt.Errorf("failed to fetch snapshots: %w", err)
 		}
-		return filteredResult, nil
 	}
 
-	return result, nil
+	s.mutex.RLock()
+	age := time.Since(s.cacheTime)
+	expired := age >= s.cacheTTL
+	stale := age >= s.staleAfter
+	s.mutex.RUnlock()
+
+	if expired {
+		s.metrics.ObserveCache(metrics.CacheStale)
+		s.revalidate()
+	} else if loaded {
+		s.metrics.ObserveCache(metrics.CacheHit)
+	}
+
+	return stale, nil
 }
 
-// fetchSnapshots retrieves all snapshots from GCP bucket
-func (s *SnapshotService) fetchSnapshots() ([]*models.Snapshot, error) {
-	resp, err := http.Get(s.bucketURL)
+// GetSnapshots retrieves the latest and previous snapshots of every type for a network.
+// Cached data is served even after it expires while a refresh runs in the background;
+// only the very first request blocks on the storage backend. Access filtering is up
+// to the caller (see auth.Policy).
+func (s *SnapshotService) GetSnapshots(network models.Network) (*models.NetworkSnapshots, error) {
+	stale, err := s.ensureLoaded()
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch bucket contents: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GCP API returned status %d", resp.StatusCode)
+	s.mutex.RLock()
+	cached, exists := s.cache[network]
+	s.mutex.RUnlock()
+
+	if !exists {
+		return &models.NetworkSnapshots{Stale: stale}, nil
 	}
 
-	var gcpResp GCPStorageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&gcpResp); err != nil {
-		return nil, fmt.Errorf("failed to decode GCP response: %w", err)
+	result := *cached
+	result.Stale = stale
+	return &result, nil
+}
+
+// fetchSnapshots retrieves all snapshots from the storage backend
+func (s *SnapshotService) fetchSnapshots(ctx context.Context) ([]*models.Snapshot, error) {
+	objects, err := s.lister.List(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	var snapshots []*models.Snapshot
-	baseURL := fmt.Sprintf("https://storage.googleapis.com/%s", s.bucketName)
+	var skipped int
+	baseURL := s.lister.PublicURL()
 
-	for _, item := range gcpResp.Items {
-		snapshot, err := s.parser.ParseSnapshot(item.Name, baseURL)
+	for _, object := range objects {
+		snapshot, err := s.parser.ParseSnapshot(object.Name, baseURL)
 		if err != nil {
 			// Skip invalid filenames (not all files in bucket are snapshots)
+			skipped++
 			continue
 		}
+		snapshot.Size = object.Size
+		snapshot.Updated = object.Updated
+		snapshot.MD5 = object.MD5
+		snapshot.CRC32C = object.CRC32C
 		snapshots = append(snapshots, snapshot)
 	}
 
+	s.metrics.ObserveListing(len(snapshots), skipped)
+
 	return snapshots, nil
 }
 
@@ -199,7 +412,7 @@ func (s *SnapshotService) findLatestSnapshot(snapshots []*models.Snapshot) *mode
 	return snapshots[0]
 }
 
-// findLatestAndPreviousSnapshots finds the latest snapshot and up to 3 previous snapshots
+// findLatestAndPreviousSnapshots finds the latest snapshot and up to historyDepth previous snapshots
 func (s *SnapshotService) findLatestAndPreviousSnapshots(snapshots []*models.Snapshot) (*models.Snapshot, []*models.Snapshot) {
 	if len(snapshots) == 0 {
 		return nil, nil
@@ -215,9 +428,11 @@ func (s *SnapshotService) findLatestAndPreviousSnapshots(snapshots []*models.Sna
 
 	latest := snapshots[0]
 
-	// Get up to 3 previous snapshots
+	// Get up to historyDepth previous snapshots
 	var previous []*models.Snapshot
-	maxPrevious := 3
+	s.mutex.RLock()
+	maxPrevious := s.historyDepth
+	s.mutex.RUnlock()
 	if len(snapshots) > 1 {
 		endIndex := len(snapshots)
 		if len(snapshots) > maxPrevious+1 {
@@ -1,14 +1,77 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/taraxa/snapshots-api/internal/models"
+	"github.com/taraxa/snapshots-api/internal/storage"
 )
 
+// fakeLister is an in-memory storage.Lister for service tests
+type fakeLister struct {
+	mu      sync.Mutex
+	objects []storage.Object
+	err     error
+	calls   int
+	// release, when set, blocks List until it is closed
+	release chan struct{}
+}
+
+func (f *fakeLister) List(ctx context.Context) ([]storage.Object, error) {
+	f.mu.Lock()
+	f.calls++
+	release := f.release
+	f.mu.Unlock()
+
+	if release != nil {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.objects, nil
+}
+
+func (f *fakeLister) PublicURL() string {
+	return "https://storage.googleapis.com/test-bucket"
+}
+
+func (f *fakeLister) setError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *fakeLister) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func newFakeLister() *fakeLister {
+	return &fakeLister{
+		objects: []storage.Object{
+			{Name: "mainnet-full-db-block-200-20250706-062734.tar.gz"},
+			{Name: "mainnet-light-db-block-150-20250706-052226.tar.gz"},
+			{Name: "mainnet-light-db-block-100-20250705-052226.tar.gz"},
+		},
+	}
+}
+
 func TestSnapshotService_processSnapshots(t *testing.T) {
 	service := NewSnapshotService("test-bucket", "https://test.example.com")
 
@@ -386,6 +449,36 @@ func TestSnapshotService_findLatestAndPreviousSnapshots(t *testing.T) {
 	}
 }
 
+func TestSnapshotService_SetHistoryDepth(t *testing.T) {
+	tests := []struct {
+		depth         int
+		expectedCount int
+	}{
+		{depth: 0, expectedCount: 0},
+		{depth: 1, expectedCount: 1},
+		{depth: 3, expectedCount: 3},
+		{depth: 10, expectedCount: 4},
+	}
+
+	for _, tt := range tests {
+		service := NewSnapshotService("test-bucket", "https://test.example.com")
+		service.SetHistoryDepth(tt.depth)
+
+		var snapshots []*models.Snapshot
+		for block := int64(100); block <= 500; block += 100 {
+			snapshots = append(snapshots, &models.Snapshot{
+				Block:     block,
+				Timestamp: time.Date(2025, 7, 6, 10, 0, 0, 0, time.UTC),
+			})
+		}
+
+		_, previous := service.findLatestAndPreviousSnapshots(snapshots)
+		if len(previous) != tt.expectedCount {
+			t.Errorf("depth %d: expected %d previous snapshots, got %d", tt.depth, tt.expectedCount, len(previous))
+		}
+	}
+}
+
 func TestSnapshotService_IsValidNetwork(t *testing.T) {
 	service := NewSnapshotService("test-bucket", "https://test.example.com")
 
@@ -443,7 +536,7 @@ func TestSnapshotService_fetchSnapshots_Error(t *testing.T) {
 
 	service := NewSnapshotService("test-bucket", server.URL)
 
-	_, err := service.fetchSnapshots()
+	_, err := service.fetchSnapshots(context.Background())
 	if err == nil {
 		t.Error("Expected error from fetchSnapshots")
 	}
@@ -456,7 +549,13 @@ func TestSnapshotService_fetchSnapshots_Success(t *testing.T) {
 		response := `{
 			"kind": "storage#objects",
 			"items": [
-				{"name": "mainnet-full-db-block-19547931-20250706-062734.tar.gz"},
+				{
+					"name": "mainnet-full-db-block-19547931-20250706-062734.tar.gz",
+					"size": "1073741824",
+					"md5Hash": "XrY7u+Ae7tCTyyK7j1rNww==",
+					"crc32c": "yZRlqg==",
+					"updated": "2025-07-06T06:30:12Z"
+				},
 				{"name": "testnet-light-db-block-2516167-20250706-052226.tar.gz"},
 				{"name": "invalid-file.txt"}
 			]
@@ -467,7 +566,7 @@ func TestSnapshotService_fetchSnapshots_Success(t *testing.T) {
 
 	service := NewSnapshotService("test-bucket", server.URL)
 
-	snapshots, err := service.fetchSnapshots()
+	snapshots, err := service.fetchSnapshots(context.Background())
 	if err != nil {
 		t.Errorf("Unexpected error from fetchSnapshots: %v", err)
 		return
@@ -488,4 +587,352 @@ func TestSnapshotService_fetchSnapshots_Success(t *testing.T) {
 	if snapshots[0].Block != 19547931 {
 		t.Errorf("Expected block 19547931, got %d", snapshots[0].Block)
 	}
+
+	// Verify object metadata is carried through from the listing
+	if snapshots[0].Size != 1073741824 {
+		t.Errorf("Expected size 1073741824, got %d", snapshots[0].Size)
+	}
+	if len(snapshots[0].MD5) != 16 {
+		t.Errorf("Expected 16 byte MD5, got %d bytes", len(snapshots[0].MD5))
+	}
+	if len(snapshots[0].CRC32C) != 4 {
+		t.Errorf("Expected 4 byte CRC32C, got %d bytes", len(snapshots[0].CRC32C))
+	}
+	if snapshots[0].Updated.IsZero() {
+		t.Error("Expected updated time to be set")
+	}
+}
+
+func TestSnapshotService_GetSnapshots(t *testing.T) {
+	lister := newFakeLister()
+	service := NewSnapshotServiceWithLister(lister)
+
+	result, err := service.GetSnapshots(models.NetworkMainnet)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Full == nil || result.Full.Block != 200 {
+		t.Errorf("Expected full snapshot at block 200, got %+v", result.Full)
+	}
+	if result.Stale {
+		t.Error("Expected fresh data")
+	}
+
+	result, err = service.GetSnapshots(models.NetworkMainnet)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Light == nil || result.Light.Block != 150 {
+		t.Errorf("Expected light snapshot at block 150, got %+v", result.Light)
+	}
+
+	// Second request is served from cache
+	if calls := lister.callCount(); calls != 1 {
+		t.Errorf("Expected 1 listing call, got %d", calls)
+	}
+}
+
+func TestSnapshotService_GetSnapshots_InitialFailure(t *testing.T) {
+	lister := newFakeLister()
+	lister.setError(errors.New("storage unavailable"))
+	service := NewSnapshotServiceWithLister(lister)
+
+	if _, err := service.GetSnapshots(models.NetworkMainnet); err == nil {
+		t.Error("Expected error when the cache was never populated")
+	}
+}
+
+func TestSnapshotService_GetSnapshots_ServesStaleData(t *testing.T) {
+	lister := newFakeLister()
+	service := NewSnapshotServiceWithLister(lister)
+	service.SetCacheOptions(time.Minute, 2*time.Minute)
+
+	if _, err := service.GetSnapshots(models.NetworkMainnet); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Storage goes down and the cache ages past the stale threshold
+	lister.setError(errors.New("storage unavailable"))
+	service.mutex.Lock()
+	service.cacheTime = time.Now().Add(-time.Hour)
+	service.mutex.Unlock()
+
+	result, err := service.GetSnapshots(models.NetworkMainnet)
+	if err != nil {
+		t.Fatalf("Expected stale data instead of error, got %v", err)
+	}
+	if result.Full == nil || result.Full.Block != 200 {
+		t.Errorf("Expected cached full snapshot at block 200, got %+v", result.Full)
+	}
+	if !result.Stale {
+		t.Error("Expected response to be flagged as stale")
+	}
+
+	// The expired cache triggers a background revalidation
+	deadline := time.Now().Add(time.Second)
+	for lister.callCount() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if calls := lister.callCount(); calls < 2 {
+		t.Errorf("Expected background revalidation, got %d listing calls", calls)
+	}
+}
+
+func TestSnapshotService_Run(t *testing.T) {
+	lister := newFakeLister()
+	service := NewSnapshotServiceWithLister(lister)
+	service.SetCacheOptions(10*time.Millisecond, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for lister.callCount() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if calls := lister.callCount(); calls < 3 {
+		t.Errorf("Expected periodic refreshes, got %d listing calls", calls)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after context cancellation")
+	}
+
+	// The cache was populated in the background, so requests do not hit storage
+	service.SetCacheOptions(time.Hour, time.Hour)
+	calls := lister.callCount()
+	if _, err := service.GetSnapshots(models.NetworkMainnet); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lister.callCount() != calls {
+		t.Error("Expected request to be served from the background-populated cache")
+	}
+}
+
+func TestSnapshotService_Run_CancelsRevalidation(t *testing.T) {
+	lister := newFakeLister()
+	service := NewSnapshotServiceWithLister(lister)
+	service.SetCacheOptions(time.Hour, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.Run(ctx)
+		close(done)
+	}()
+	if _, err := service.GetSnapshots(models.NetworkMainnet); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cancel()
+	<-done
+
+	// A revalidation started by a request after shutdown must not hang on the backend
+	release := make(chan struct{})
+	defer close(release)
+	lister.mu.Lock()
+	lister.release = release
+	lister.mu.Unlock()
+	service.SetCacheOptions(time.Nanosecond, 0)
+
+	calls := lister.callCount()
+	service.GetSnapshots(models.NetworkMainnet)
+	deadline := time.Now().Add(time.Second)
+	for {
+		service.mutex.RLock()
+		err := service.lastError
+		service.mutex.RUnlock()
+		if errors.Is(err, context.Canceled) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the revalidation to be cancelled, got calls %d -> %d, error %v", calls, lister.callCount(), err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSnapshotService_Run_IntervalChange(t *testing.T) {
+	lister := newFakeLister()
+	service := NewSnapshotServiceWithLister(lister)
+	service.SetCacheOptions(time.Hour, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.Run(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for lister.callCount() < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// A reload shortens the interval without waiting for the hourly tick
+	service.SetCacheOptions(10*time.Millisecond, time.Minute)
+
+	deadline = time.Now().Add(time.Second)
+	for lister.callCount() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if calls := lister.callCount(); calls < 3 {
+		t.Errorf("Expected refreshes on the new interval, got %d listing calls", calls)
+	}
+}
+
+func TestSnapshotService_GetSnapshots_CoalescesConcurrentMisses(t *testing.T) {
+	lister := newFakeLister()
+	lister.release = make(chan struct{})
+	service := NewSnapshotServiceWithLister(lister)
+
+	const concurrency = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := service.GetSnapshots(models.NetworkMainnet)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if result.Light == nil {
+				errs <- errors.New("expected light snapshot")
+			}
+		}()
+	}
+
+	// Wait for the first listing to start, give the other requests time to pile up, then let it finish
+	deadline := time.Now().Add(time.Second)
+	for lister.callCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(lister.release)
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if calls := lister.callCount(); calls != 1 {
+		t.Errorf("Expected %d concurrent misses to share 1 listing call, got %d", concurrency, calls)
+	}
+}
+
+func TestSnapshotService_OnRefresh(t *testing.T) {
+	lister := newFakeLister()
+	service := NewSnapshotServiceWithLister(lister)
+
+	var events []RefreshEvent
+	service.OnRefresh(func(event RefreshEvent) {
+		events = append(events, event)
+	})
+
+	if err := service.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 refresh event, got %d", len(events))
+	}
+	if len(events[0].Snapshots) != 3 {
+		t.Errorf("Expected 3 snapshots in event, got %d", len(events[0].Snapshots))
+	}
+	if len(events[0].Previous) != 0 || len(events[0].Added) != 0 {
+		t.Errorf("Expected no previous cache and no added snapshots on the first refresh")
+	}
+	if events[0].Current[models.NetworkMainnet].Full.Block != 200 {
+		t.Errorf("Expected current mainnet full block 200")
+	}
+
+	// Failed refreshes do not notify listeners
+	lister.setError(errors.New("storage unavailable"))
+	if err := service.refresh(context.Background()); err == nil {
+		t.Fatal("Expected refresh error")
+	}
+	if len(events) != 1 {
+		t.Errorf("Expected no event for a failed refresh, got %d events", len(events))
+	}
+}
+
+func TestSnapshotService_OnRefresh_Added(t *testing.T) {
+	lister := &fakeLister{}
+	service := NewSnapshotServiceWithLister(lister)
+	service.SetHistoryDepth(1)
+
+	var added [][]*models.Snapshot
+	service.OnRefresh(func(event RefreshEvent) {
+		added = append(added, event.Added)
+	})
+
+	steps := []struct {
+		name     string
+		objects  []string
+		expected []string
+	}{
+		{
+			name: "initial empty listing",
+		},
+		{
+			name:     "first snapshot in an empty bucket",
+			objects:  []string{"mainnet-light-db-block-100-20250705-052226.tar.gz"},
+			expected: []string{"mainnet-light-db-block-100-20250705-052226.tar.gz"},
+		},
+		{
+			name: "more new snapshots than the history depth, oldest first",
+			objects: []string{
+				"mainnet-light-db-block-100-20250705-052226.tar.gz",
+				"mainnet-light-db-block-400-20250708-052226.tar.gz",
+				"mainnet-full-db-block-200-20250706-062734.tar.gz",
+				"mainnet-light-db-block-300-20250707-052226.tar.gz",
+			},
+			expected: []string{
+				"mainnet-full-db-block-200-20250706-062734.tar.gz",
+				"mainnet-light-db-block-300-20250707-052226.tar.gz",
+				"mainnet-light-db-block-400-20250708-052226.tar.gz",
+			},
+		},
+		{
+			name: "raising the history depth adds nothing",
+			objects: []string{
+				"mainnet-light-db-block-100-20250705-052226.tar.gz",
+				"mainnet-light-db-block-400-20250708-052226.tar.gz",
+				"mainnet-full-db-block-200-20250706-062734.tar.gz",
+				"mainnet-light-db-block-300-20250707-052226.tar.gz",
+			},
+		},
+	}
+
+	for i, step := range steps {
+		lister.mu.Lock()
+		lister.objects = nil
+		for _, name := range step.objects {
+			lister.objects = append(lister.objects, storage.Object{Name: name})
+		}
+		lister.mu.Unlock()
+		if i == len(steps)-1 {
+			service.SetHistoryDepth(5)
+		}
+
+		if err := service.refresh(context.Background()); err != nil {
+			t.Fatalf("%s: refresh failed: %v", step.name, err)
+		}
+
+		var got []string
+		for _, snapshot := range added[i] {
+			got = append(got, snapshot.Filename)
+		}
+		if strings.Join(got, ",") != strings.Join(step.expected, ",") {
+			t.Errorf("%s: expected added %v, got %v", step.name, step.expected, got)
+		}
+	}
 }
@@ -0,0 +1,192 @@
+package signedurl
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/sigv4"
+)
+
+// Signing algorithms of GCS V4 signed URLs
+const (
+	AlgorithmRSA  = "GOOG4-RSA-SHA256"
+	AlgorithmHMAC = "GOOG4-HMAC-SHA256"
+)
+
+// MaxExpiry is the longest validity GCS accepts for a V4 signed URL
+const MaxExpiry = 7 * 24 * time.Hour
+
+// signingQuantum is the granularity of the signing time. Signing with the start of
+// the current minute keeps responses, and their ETags, stable within a minute.
+const signingQuantum = time.Minute
+
+// Signer creates GCS V4 signed URLs for GET requests with a service account RSA
+// key or an HMAC key
+type Signer struct {
+	algorithm string
+	// accessID is the service account email or the HMAC access id
+	accessID string
+	rsaKey   *rsa.PrivateKey
+	secret   string
+	expiry   time.Duration
+	now      func() time.Time
+}
+
+// NewRSASigner creates a signer for the service account email with its private key
+func NewRSASigner(email string, key *rsa.PrivateKey, expiry time.Duration) *Signer {
+	return &Signer{algorithm: AlgorithmRSA, accessID: email, rsaKey: key, expiry: expiry, now: time.Now}
+}
+
+// NewHMACSigner creates a signer for a GCS HMAC key
+func NewHMACSigner(accessID, secret string, expiry time.Duration) *Signer {
+	return &Signer{algorithm: AlgorithmHMAC, accessID: accessID, secret: secret, expiry: expiry, now: time.Now}
+}
+
+// keyFile is a service account JSON key or a GCS HMAC key as printed by
+// `gcloud storage hmac create --format=json`
+type keyFile struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	AccessID    string `json:"accessId"`
+	Secret      string `json:"secret"`
+	Metadata    struct {
+		AccessID string `json:"accessId"`
+	} `json:"metadata"`
+}
+
+// LoadSigner creates a signer from a service account JSON key or an HMAC key file
+func LoadSigner(path string, expiry time.Duration) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+
+	switch {
+	case file.PrivateKey != "":
+		if file.ClientEmail == "" {
+			return nil, fmt.Errorf("signing key %s has no client_email", path)
+		}
+		key, err := parseRSAKey(file.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", path, err)
+		}
+		return NewRSASigner(file.ClientEmail, key, expiry), nil
+	case file.Secret != "":
+		accessID := file.AccessID
+		if accessID == "" {
+			accessID = file.Metadata.AccessID
+		}
+		if accessID == "" {
+			return nil, fmt.Errorf("signing key %s has no accessId", path)
+		}
+		return NewHMACSigner(accessID, file.Secret, expiry), nil
+	default:
+		return nil, fmt.Errorf("signing key %s is neither a service account key nor an HMAC key", path)
+	}
+}
+
+// parseRSAKey decodes a PEM encoded PKCS #8 or PKCS #1 RSA private key
+func parseRSAKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("private_key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private_key is not an RSA key")
+	}
+	return key, nil
+}
+
+// Expiry returns how long signed URLs stay valid
+func (s *Signer) Expiry() time.Duration {
+	return s.expiry
+}
+
+// Sign returns rawURL signed for GET requests. The host and path of rawURL are
+// signed as they are, so path-style and virtual-hosted URLs both work.
+func (s *Signer) Sign(rawURL string) (string, error) {
+	return s.signAt(rawURL, s.now().UTC().Truncate(signingQuantum))
+}
+
+func (s *Signer) signAt(rawURL string, signedAt time.Time) (string, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if target.Host == "" {
+		return "", fmt.Errorf("URL %q has no host", rawURL)
+	}
+
+	timestamp := signedAt.UTC().Format("20060102T150405Z")
+	scope := signedAt.UTC().Format("20060102") + "/auto/storage/goog4_request"
+
+	query := target.Query()
+	query.Set("X-Goog-Algorithm", s.algorithm)
+	query.Set("X-Goog-Credential", s.accessID+"/"+scope)
+	query.Set("X-Goog-Date", timestamp)
+	query.Set("X-Goog-Expires", fmt.Sprintf("%d", int64(s.expiry/time.Second)))
+	query.Set("X-Goog-SignedHeaders", "host")
+	canonicalQuery := sigv4.CanonicalQuery(query)
+
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		target.EscapedPath(),
+		canonicalQuery,
+		"host:" + strings.ToLower(target.Host) + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	requestDigest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{s.algorithm, timestamp, scope, hex.EncodeToString(requestDigest[:])}, "\n")
+
+	signature, err := s.signature(signedAt, stringToSign)
+	if err != nil {
+		return "", err
+	}
+
+	target.RawQuery = canonicalQuery + "&X-Goog-Signature=" + signature
+	target.Fragment = ""
+	return target.String(), nil
+}
+
+// signature signs stringToSign and returns the hex encoded signature
+func (s *Signer) signature(signedAt time.Time, stringToSign string) (string, error) {
+	if s.algorithm == AlgorithmHMAC {
+		// The signing key is derived like an AWS Signature Version 4 key
+		key := sigv4.SigningKey("GOOG4"+s.secret, signedAt.UTC().Format("20060102"), "auto", "storage", "goog4_request")
+		return sigv4.Sign(key, stringToSign), nil
+	}
+
+	digest := sha256.Sum256([]byte(stringToSign))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign URL: %w", err)
+	}
+	return hex.EncodeToString(signature), nil
+}
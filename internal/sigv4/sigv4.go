@@ -0,0 +1,60 @@
+// Package sigv4 holds the pieces of Signature Version 4 shared by the S3 request
+// signer and the GCS V4 URL signer, which derive keys and canonicalize queries alike.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// SigningKey derives the key of one day and scope by chaining HMAC-SHA256 over the
+// date and scope parts, starting from the secret with its provider prefix ("AWS4"
+// or "GOOG4") already applied
+func SigningKey(secret, date string, scope ...string) []byte {
+	key := HMACSHA256([]byte(secret), date)
+	for _, part := range scope {
+		key = HMACSHA256(key, part)
+	}
+	return key
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of stringToSign
+func Sign(key []byte, stringToSign string) string {
+	return hex.EncodeToString(HMACSHA256(key, stringToSign))
+}
+
+// HMACSHA256 returns the HMAC-SHA256 of data under key
+func HMACSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// CanonicalQuery sorts the parameters by name, then value, and percent-encodes
+// names and values as RFC 3986 requires
+func CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, Escape(name)+"="+Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// Escape percent-encodes everything but the RFC 3986 unreserved characters
+func Escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
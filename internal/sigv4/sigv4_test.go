@@ -0,0 +1,24 @@
+package sigv4
+
+import (
+	"encoding/hex"
+	"net/url"
+	"testing"
+)
+
+func TestSigningKey(t *testing.T) {
+	// Example from the AWS Signature Version 4 documentation
+	key := SigningKey("AWS4wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam", "aws4_request")
+	if got := hex.EncodeToString(key); got != "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d" {
+		t.Errorf("Unexpected signing key %s", got)
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	query := url.Values{"prefix": {"a b"}, "list-type": {"2"}, "continuation-token": {"x/y="}, "marker": {"z", "a"}}
+
+	expected := "continuation-token=x%2Fy%3D&list-type=2&marker=a&marker=z&prefix=a%20b"
+	if got := CanonicalQuery(query); got != expected {
+		t.Errorf("CanonicalQuery() = %s, want %s", got, expected)
+	}
+}
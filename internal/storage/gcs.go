@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultMaxPages is the default upper bound on listing pages fetched per List call
+const DefaultMaxPages = 100
+
+// gcsListFields restricts the GCS listing response to the fields we actually use
+const gcsListFields = "kind,nextPageToken,items(name,size,md5Hash,crc32c,updated)"
+
+// GCSListResponse represents a single page of the GCS JSON API objects.list response
+type GCSListResponse struct {
+	Kind          string `json:"kind"`
+	NextPageToken string `json:"nextPageToken"`
+	Items         []struct {
+		Name    string    `json:"name"`
+		Size    int64     `json:"size,string"`
+		MD5Hash string    `json:"md5Hash"`
+		CRC32C  string    `json:"crc32c"`
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+}
+
+// GCSLister lists objects through the unauthenticated GCS JSON API
+type GCSLister struct {
+	bucketURL string
+	publicURL string
+	prefix    string
+	maxPages  int
+	client    *http.Client
+}
+
+// NewGCSLister creates a lister for the objects.list endpoint at bucketURL.
+// A non-positive maxPages falls back to DefaultMaxPages.
+func NewGCSLister(bucketURL, publicURL, prefix string, maxPages int) *GCSLister {
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	return &GCSLister{
+		bucketURL: bucketURL,
+		publicURL: publicURL,
+		prefix:    prefix,
+		maxPages:  maxPages,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// PublicURL returns the base download URL of the bucket
+func (l *GCSLister) PublicURL() string {
+	return l.publicURL
+}
+
+// List retrieves every object in the bucket, following pagination
+func (l *GCSLister) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+
+	pageToken := ""
+	for page := 0; ; page++ {
+		if page >= l.maxPages {
+			return nil, fmt.Errorf("bucket listing exceeded %d pages", l.maxPages)
+		}
+
+		resp, err := l.fetchPage(ctx, pageToken)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Items {
+			objects = append(objects, Object{
+				Name:    item.Name,
+				Size:    item.Size,
+				Updated: item.Updated,
+				MD5:     decodeBase64(item.MD5Hash),
+				CRC32C:  decodeBase64(item.CRC32C),
+			})
+		}
+
+		if resp.NextPageToken == "" {
+			break
+		}
+		pageToken = resp.NextPageToken
+	}
+
+	return objects, nil
+}
+
+// fetchPage retrieves a single page of the bucket listing
+func (l *GCSLister) fetchPage(ctx context.Context, pageToken string) (*GCSListResponse, error) {
+	listURL, err := url.Parse(l.bucketURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bucket URL: %w", err)
+	}
+
+	query := listURL.Query()
+	query.Set("fields", gcsListFields)
+	if l.prefix != "" {
+		query.Set("prefix", l.prefix)
+	}
+	if pageToken != "" {
+		query.Set("pageToken", pageToken)
+	}
+	listURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bucket contents: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GCP API returned status %d", resp.StatusCode)
+	}
+
+	var gcsResp GCSListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gcsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode GCP response: %w", err)
+	}
+
+	return &gcsResp, nil
+}
+
+// decodeBase64 decodes a base64 digest, returning nil if it is empty or malformed
+func decodeBase64(value string) []byte {
+	if value == "" {
+		return nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil
+	}
+	return decoded
+}
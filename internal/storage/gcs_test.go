@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGCSLister_List_Pagination(t *testing.T) {
+	pages := map[string]string{
+		"": `{
+			"kind": "storage#objects",
+			"nextPageToken": "page-2",
+			"items": [
+				{
+					"name": "mainnet-full-db-block-100-20250706-062734.tar.gz",
+					"size": "1073741824",
+					"md5Hash": "XrY7u+Ae7tCTyyK7j1rNww==",
+					"crc32c": "yZRlqg==",
+					"updated": "2025-07-06T06:30:12.345Z"
+				},
+				{"name": "invalid-file.txt"}
+			]
+		}`,
+		"page-2": `{
+			"kind": "storage#objects",
+			"nextPageToken": "page-3",
+			"items": [
+				{"name": "mainnet-full-db-block-200-20250707-062734.tar.gz"}
+			]
+		}`,
+		"page-3": `{
+			"kind": "storage#objects",
+			"items": [
+				{"name": "mainnet-light-db-block-300-20250708-062734.tar.gz"}
+			]
+		}`,
+	}
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		query := r.URL.Query()
+		if query.Get("prefix") != "mainnet-" {
+			t.Errorf("Expected prefix 'mainnet-', got %q", query.Get("prefix"))
+		}
+		if query.Get("fields") == "" {
+			t.Error("Expected fields parameter to be set")
+		}
+
+		body, ok := pages[query.Get("pageToken")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	lister := NewGCSLister(server.URL, "https://storage.googleapis.com/test-bucket", "mainnet-", 10)
+
+	objects, err := lister.List(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error from List: %v", err)
+	}
+
+	if requests != 3 {
+		t.Errorf("Expected 3 page requests, got %d", requests)
+	}
+
+	expectedNames := []string{
+		"mainnet-full-db-block-100-20250706-062734.tar.gz",
+		"invalid-file.txt",
+		"mainnet-full-db-block-200-20250707-062734.tar.gz",
+		"mainnet-light-db-block-300-20250708-062734.tar.gz",
+	}
+	if len(objects) != len(expectedNames) {
+		t.Fatalf("Expected %d objects, got %d", len(expectedNames), len(objects))
+	}
+	for i, expected := range expectedNames {
+		if objects[i].Name != expected {
+			t.Errorf("Expected object %d to be %s, got %s", i, expected, objects[i].Name)
+		}
+	}
+
+	first := objects[0]
+	if first.Size != 1073741824 {
+		t.Errorf("Expected size 1073741824, got %d", first.Size)
+	}
+	if hex.EncodeToString(first.MD5) != "5eb63bbbe01eeed093cb22bb8f5acdc3" {
+		t.Errorf("Unexpected MD5 %x", first.MD5)
+	}
+	if hex.EncodeToString(first.CRC32C) != "c99465aa" {
+		t.Errorf("Unexpected CRC32C %x", first.CRC32C)
+	}
+	expectedUpdated := time.Date(2025, 7, 6, 6, 30, 12, 345000000, time.UTC)
+	if !first.Updated.Equal(expectedUpdated) {
+		t.Errorf("Expected updated %v, got %v", expectedUpdated, first.Updated)
+	}
+}
+
+func TestGCSLister_List_MaxPages(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// Always hand out another page token to simulate a runaway listing
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"kind": "storage#objects", "nextPageToken": "page-%d", "items": []}`, requests)
+	}))
+	defer server.Close()
+
+	lister := NewGCSLister(server.URL, "", "", 3)
+
+	_, err := lister.List(context.Background())
+	if err == nil {
+		t.Error("Expected error when listing exceeds max pages")
+	}
+
+	if requests != 3 {
+		t.Errorf("Expected 3 page requests before giving up, got %d", requests)
+	}
+}
+
+func TestGCSLister_List_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	lister := NewGCSLister(server.URL, "", "", 0)
+
+	if _, err := lister.List(context.Background()); err == nil {
+		t.Error("Expected error from List")
+	}
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LocalLister lists snapshot files from a local directory, e.g. a disk mirror of the bucket
+type LocalLister struct {
+	dir       string
+	publicURL string
+	prefix    string
+}
+
+// NewLocalLister creates a lister for the files directly inside dir
+func NewLocalLister(dir, publicURL, prefix string) *LocalLister {
+	return &LocalLister{
+		dir:       dir,
+		publicURL: publicURL,
+		prefix:    prefix,
+	}
+}
+
+// PublicURL returns the base URL the directory is served from
+func (l *LocalLister) PublicURL() string {
+	return l.publicURL
+}
+
+// List returns the regular files in the directory. Hashes are not computed
+// because reading multi-gigabyte snapshots on every refresh is too expensive.
+func (l *LocalLister) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", l.dir, err)
+	}
+
+	var objects []Object
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), l.prefix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// File removed between ReadDir and Info
+			continue
+		}
+
+		objects = append(objects, Object{
+			Name:    entry.Name(),
+			Size:    info.Size(),
+			Updated: info.ModTime().UTC(),
+		})
+	}
+
+	return objects, nil
+}
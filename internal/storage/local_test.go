@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalLister_List(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"mainnet-full-db-block-100-20250706-062734.tar.gz":  "full",
+		"mainnet-light-db-block-100-20250706-062734.tar.gz": "light",
+		"testnet-light-db-block-50-20250706-062734.tar.gz":  "testnet",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "mainnet-subdir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	lister := NewLocalLister(dir, "https://mirror.example.com/snapshots", "mainnet-")
+
+	objects, err := lister.List(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error from List: %v", err)
+	}
+
+	// Directories and objects outside the prefix are skipped
+	if len(objects) != 2 {
+		t.Fatalf("Expected 2 objects, got %d", len(objects))
+	}
+
+	for _, object := range objects {
+		content, ok := files[object.Name]
+		if !ok {
+			t.Errorf("Unexpected object %s", object.Name)
+			continue
+		}
+		if object.Size != int64(len(content)) {
+			t.Errorf("Expected size %d for %s, got %d", len(content), object.Name, object.Size)
+		}
+		if object.Updated.IsZero() {
+			t.Errorf("Expected updated time for %s", object.Name)
+		}
+	}
+
+	if lister.PublicURL() != "https://mirror.example.com/snapshots" {
+		t.Errorf("Unexpected public URL %s", lister.PublicURL())
+	}
+}
+
+func TestLocalLister_List_MissingDirectory(t *testing.T) {
+	lister := NewLocalLister(filepath.Join(t.TempDir(), "missing"), "", "")
+
+	if _, err := lister.List(context.Background()); err == nil {
+		t.Error("Expected error for missing directory")
+	}
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/sigv4"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body, used for SigV4 GET requests
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Options configures an S3-compatible lister
+type S3Options struct {
+	Endpoint string
+	Bucket   string
+	Region   string
+	// AccessKeyID and SecretAccessKey enable SigV4 request signing; leave empty for public buckets
+	AccessKeyID     string
+	SecretAccessKey string
+	// PublicURL overrides the download base URL, which defaults to Endpoint/Bucket
+	PublicURL string
+	Prefix    string
+	MaxPages  int
+}
+
+// S3ListResponse represents a single page of the ListObjectsV2 XML response
+type S3ListResponse struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// S3Lister lists objects through the S3 ListObjectsV2 API using path-style addressing
+type S3Lister struct {
+	opts   S3Options
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3Lister creates a lister for an S3-compatible bucket such as AWS S3 or MinIO
+func NewS3Lister(opts S3Options) *S3Lister {
+	opts.Endpoint = strings.TrimSuffix(opts.Endpoint, "/")
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = DefaultMaxPages
+	}
+	if opts.PublicURL == "" {
+		opts.PublicURL = fmt.Sprintf("%s/%s", opts.Endpoint, opts.Bucket)
+	}
+	return &S3Lister{
+		opts:   opts,
+		client: &http.Client{Timeout: 30 * time.Second},
+		now:    time.Now,
+	}
+}
+
+// PublicURL returns the base download URL of the bucket
+func (l *S3Lister) PublicURL() string {
+	return l.opts.PublicURL
+}
+
+// List retrieves every object in the bucket, following continuation tokens
+func (l *S3Lister) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+
+	token := ""
+	for page := 0; ; page++ {
+		if page >= l.opts.MaxPages {
+			return nil, fmt.Errorf("bucket listing exceeded %d pages", l.opts.MaxPages)
+		}
+
+		resp, err := l.fetchPage(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Contents {
+			objects = append(objects, Object{
+				Name:    item.Key,
+				Size:    item.Size,
+				Updated: item.LastModified,
+				MD5:     etagMD5(item.ETag),
+			})
+		}
+
+		if !resp.IsTruncated || resp.NextContinuationToken == "" {
+			break
+		}
+		token = resp.NextContinuationToken
+	}
+
+	return objects, nil
+}
+
+// fetchPage retrieves a single page of the bucket listing
+func (l *S3Lister) fetchPage(ctx context.Context, token string) (*S3ListResponse, error) {
+	listURL, err := url.Parse(fmt.Sprintf("%s/%s", l.opts.Endpoint, l.opts.Bucket))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("list-type", "2")
+	if l.opts.Prefix != "" {
+		query.Set("prefix", l.opts.Prefix)
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	listURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if l.opts.AccessKeyID != "" {
+		l.sign(req, l.now().UTC())
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bucket contents: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("S3 API returned status %d", resp.StatusCode)
+	}
+
+	var s3Resp S3ListResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&s3Resp); err != nil {
+		return nil, fmt.Errorf("failed to decode S3 response: %w", err)
+	}
+
+	return &s3Resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to a bodiless request
+func (l *S3Lister) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n",
+		req.URL.Host, emptyPayloadHash, amzDate)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		sigv4.CanonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, l.opts.Region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := sigv4.SigningKey("AWS4"+l.opts.SecretAccessKey, date, l.opts.Region, "s3", "aws4_request")
+	signature := sigv4.Sign(key, stringToSign)
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		l.opts.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalPath returns the URI-encoded path used in the SigV4 canonical request
+func canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// etagMD5 returns the MD5 digest encoded in an ETag, or nil for multipart uploads
+// whose ETag is not a plain content hash
+func etagMD5(etag string) []byte {
+	etag = strings.Trim(etag, `"`)
+	if len(etag) != 32 {
+		return nil
+	}
+	digest, err := hex.DecodeString(etag)
+	if err != nil {
+		return nil
+	}
+	return digest
+}
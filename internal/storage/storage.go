@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Backend names accepted by STORAGE_BACKEND
+const (
+	BackendGCS   = "gcs"
+	BackendS3    = "s3"
+	BackendLocal = "local"
+)
+
+// Object describes a single object stored in a snapshot bucket
+type Object struct {
+	Name    string
+	Size    int64
+	Updated time.Time
+	// MD5 and CRC32C hold the raw digest bytes, or nil when the backend does not report them
+	MD5    []byte
+	CRC32C []byte
+}
+
+// Lister lists the objects of a snapshot bucket
+type Lister interface {
+	// List returns every object in the bucket, following pagination where needed
+	List(ctx context.Context) ([]Object, error)
+	// PublicURL returns the base URL under which objects can be downloaded
+	PublicURL() string
+}
+
+// Options selects a storage backend and configures it
+type Options struct {
+	Backend string
+	// PublicURL overrides the base URL of download links
+	PublicURL string
+	Prefix    string
+	MaxPages  int
+
+	GCPBucketName string
+	GCPBucketURL  string
+
+	S3Endpoint        string
+	S3Bucket          string
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+
+	LocalDir string
+}
+
+// New creates the Lister selected by opts.Backend
+func New(opts Options) (Lister, error) {
+	switch opts.Backend {
+	case BackendGCS, "":
+		publicURL := opts.PublicURL
+		if publicURL == "" {
+			publicURL = fmt.Sprintf("https://storage.googleapis.com/%s", opts.GCPBucketName)
+		}
+		return NewGCSLister(opts.GCPBucketURL, publicURL, opts.Prefix, opts.MaxPages), nil
+	case BackendS3:
+		if opts.S3Endpoint == "" || opts.S3Bucket == "" {
+			return nil, fmt.Errorf("s3 backend requires S3_ENDPOINT and S3_BUCKET")
+		}
+		return NewS3Lister(S3Options{
+			Endpoint:        opts.S3Endpoint,
+			Bucket:          opts.S3Bucket,
+			Region:          opts.S3Region,
+			AccessKeyID:     opts.S3AccessKeyID,
+			SecretAccessKey: opts.S3SecretAccessKey,
+			PublicURL:       opts.PublicURL,
+			Prefix:          opts.Prefix,
+			MaxPages:        opts.MaxPages,
+		}), nil
+	case BackendLocal:
+		if opts.LocalDir == "" {
+			return nil, fmt.Errorf("local backend requires LOCAL_DIR")
+		}
+		return NewLocalLister(opts.LocalDir, opts.PublicURL, opts.Prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", opts.Backend)
+	}
+}
@@ -0,0 +1,74 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// usageBucket holds one JSON record per API key id
+var usageBucket = []byte("usage")
+
+// Store persists usage records in a BoltDB file
+type Store struct {
+	db *bolt.DB
+}
+
+// OpenStore opens or creates the usage database at path
+func OpenStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(usageBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize usage store: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Load returns every persisted record
+func (s *Store) Load() ([]Record, error) {
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usageBucket).ForEach(func(key, value []byte) error {
+			var record Record
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("invalid usage record %s: %w", key, err)
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, err
+}
+
+// Save writes records in one transaction, replacing earlier versions
+func (s *Store) Save(records []Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usageBucket)
+		for _, record := range records {
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(record.Key), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the database file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
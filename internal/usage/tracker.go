@@ -0,0 +1,260 @@
+package usage
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// Record is the usage of one API key since it was first seen
+type Record struct {
+	Key   string `json:"key"`
+	Owner string `json:"owner,omitempty"`
+	// Requests counts every request made with the key, Downloads the download redirects among them
+	Requests  int64     `json:"requests"`
+	Downloads int64     `json:"downloads"`
+	FirstSeen time.Time `json:"first-seen"`
+	LastSeen  time.Time `json:"last-seen"`
+	// Networks and Types count requests per network and snapshot type
+	Networks map[string]int64 `json:"networks,omitempty"`
+	Types    map[string]int64 `json:"types,omitempty"`
+}
+
+// clone returns a deep copy of the record
+func (r *Record) clone() Record {
+	copied := *r
+	copied.Networks = maps.Clone(r.Networks)
+	copied.Types = maps.Clone(r.Types)
+	return copied
+}
+
+// Tracker aggregates usage per API key in memory and periodically persists it.
+// Anonymous requests are not tracked. All methods are safe to call on a nil
+// *Tracker so tracking stays optional.
+type Tracker struct {
+	mutex    sync.Mutex
+	records  map[string]*Record
+	dirty    map[string]bool
+	networks map[string]bool
+	store    *Store
+	now      func() time.Time
+}
+
+// NewTracker creates a tracker; requests for networks outside networks are
+// counted without a network so bogus paths cannot grow the records
+func NewTracker(networks []models.Network) *Tracker {
+	known := make(map[string]bool, len(networks))
+	for _, network := range networks {
+		known[string(network)] = true
+	}
+	return &Tracker{
+		records:  make(map[string]*Record),
+		dirty:    make(map[string]bool),
+		networks: known,
+		now:      time.Now,
+	}
+}
+
+// SetStore loads the usage persisted in store and saves future usage to it
+func (t *Tracker) SetStore(store *Store) error {
+	records, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, record := range records {
+		record := record
+		t.records[record.Key] = &record
+	}
+	t.store = store
+	return nil
+}
+
+// Middleware counts the requests of authenticated callers. It must run inside
+// auth.Middleware.Authenticate and outside the ServeMux, which sets the path values.
+func (t *Tracker) Middleware(next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		if principal == nil {
+			return
+		}
+
+		network := r.PathValue("network")
+		if network == "" {
+			network = r.URL.Query().Get("network")
+		}
+		t.record(principal, network, r.PathValue("type"), false)
+	})
+}
+
+// RecordDownload counts a snapshot download by the caller of r; the request itself
+// is counted by Middleware
+func (t *Tracker) RecordDownload(r *http.Request) {
+	if t == nil {
+		return
+	}
+	if principal, _ := auth.PrincipalFromContext(r.Context()); principal != nil {
+		t.record(principal, "", "", true)
+	}
+}
+
+func (t *Tracker) record(principal *auth.Principal, network, snapshotType string, download bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now().UTC()
+	record, ok := t.records[principal.ID]
+	if !ok {
+		record = &Record{Key: principal.ID, FirstSeen: now}
+		t.records[principal.ID] = record
+	}
+	record.Owner = principal.Owner
+	record.LastSeen = now
+	t.dirty[principal.ID] = true
+
+	if download {
+		record.Downloads++
+		return
+	}
+
+	record.Requests++
+	if t.networks[network] {
+		if record.Networks == nil {
+			record.Networks = make(map[string]int64)
+		}
+		record.Networks[network]++
+	}
+	if snapshotType := models.SnapshotType(snapshotType); snapshotType == models.SnapshotTypeFull || snapshotType == models.SnapshotTypeLight {
+		if record.Types == nil {
+			record.Types = make(map[string]int64)
+		}
+		record.Types[string(snapshotType)]++
+	}
+}
+
+// Records returns the usage of every key, ordered by key
+func (t *Tracker) Records() []Record {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	records := make([]Record, 0, len(t.records))
+	for _, key := range slices.Sorted(maps.Keys(t.records)) {
+		records = append(records, t.records[key].clone())
+	}
+	return records
+}
+
+// Flush persists the records that changed since the last flush
+func (t *Tracker) Flush() error {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	if t.store == nil || len(t.dirty) == 0 {
+		t.mutex.Unlock()
+		return nil
+	}
+	changed := make([]Record, 0, len(t.dirty))
+	for key := range t.dirty {
+		changed = append(changed, t.records[key].clone())
+	}
+	dirty := t.dirty
+	t.dirty = make(map[string]bool)
+	store := t.store
+	t.mutex.Unlock()
+
+	if err := store.Save(changed); err != nil {
+		// Keep the records dirty so the next flush retries them
+		t.mutex.Lock()
+		for key := range dirty {
+			t.dirty[key] = true
+		}
+		t.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes the records every interval until ctx is cancelled, then flushes once more
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.Flush(); err != nil {
+				log.Printf("Failed to save usage: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				log.Printf("Failed to save usage: %v", err)
+			}
+		}
+	}
+}
+
+// csvHeader lists the CSV export columns
+var csvHeader = []string{"key", "owner", "requests", "downloads", "first_seen", "last_seen", "networks", "types"}
+
+// WriteCSV writes records as CSV with one row per key. Networks and types are
+// written as "name=count" pairs separated by semicolons.
+func WriteCSV(w io.Writer, records []Record) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		row := []string{
+			record.Key,
+			record.Owner,
+			strconv.FormatInt(record.Requests, 10),
+			strconv.FormatInt(record.Downloads, 10),
+			record.FirstSeen.Format(time.RFC3339),
+			record.LastSeen.Format(time.RFC3339),
+			formatCounts(record.Networks),
+			formatCounts(record.Types),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatCounts(counts map[string]int64) string {
+	pairs := make([]string, 0, len(counts))
+	for _, name := range slices.Sorted(maps.Keys(counts)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", name, counts[name]))
+	}
+	return strings.Join(pairs, ";")
+}
@@ -0,0 +1,136 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/auth"
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+func newTestTracker() *Tracker {
+	tracker := NewTracker([]models.Network{models.NetworkMainnet, models.NetworkTestnet})
+	tracker.now = func() time.Time { return time.Date(2025, 7, 6, 14, 30, 0, 0, time.UTC) }
+	return tracker
+}
+
+// serve sends a request through the tracker as the given principal
+func serve(tracker *Tracker, principal *auth.Principal, pattern, path string, download bool) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if download {
+			tracker.RecordDownload(r)
+		}
+	})
+
+	req := httptest.NewRequest("GET", path, nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	tracker.Middleware(mux).ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestTracker_Middleware(t *testing.T) {
+	tracker := newTestTracker()
+	partner := &auth.Principal{ID: "partner", Owner: "ops@partner.io"}
+
+	serve(tracker, partner, "GET /v1/networks/{network}/snapshots/{type}/latest", "/v1/networks/mainnet/snapshots/full/latest", false)
+	serve(tracker, partner, "GET /v1/networks/{network}/snapshots/{type}/latest/download", "/v1/networks/mainnet/snapshots/light/latest/download", true)
+	serve(tracker, partner, "/{$}", "/?network=testnet", false)
+	serve(tracker, partner, "GET /v1/networks/{network}/snapshots", "/v1/networks/bogus/snapshots", false)
+	serve(tracker, nil, "GET /v1/networks", "/v1/networks", false)
+
+	records := tracker.Records()
+	if len(records) != 1 {
+		t.Fatalf("Expected only the API key to be tracked, got %+v", records)
+	}
+
+	record := records[0]
+	if record.Key != "partner" || record.Owner != "ops@partner.io" || record.Requests != 4 || record.Downloads != 1 {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+	if record.Networks["mainnet"] != 2 || record.Networks["testnet"] != 1 || len(record.Networks) != 2 {
+		t.Errorf("Unexpected networks: %v", record.Networks)
+	}
+	if record.Types["full"] != 1 || record.Types["light"] != 1 {
+		t.Errorf("Unexpected types: %v", record.Types)
+	}
+	if !record.FirstSeen.Equal(tracker.now()) || !record.LastSeen.Equal(tracker.now()) {
+		t.Errorf("Unexpected timestamps: %v, %v", record.FirstSeen, record.LastSeen)
+	}
+
+	// Records are copies
+	records[0].Networks["mainnet"] = 100
+	if tracker.Records()[0].Networks["mainnet"] != 2 {
+		t.Error("Expected Records to return copies")
+	}
+}
+
+func TestTracker_Store(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.db")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tracker := newTestTracker()
+	if err := tracker.SetStore(store); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tracker.Run(ctx, time.Hour)
+	}()
+
+	principal := &auth.Principal{ID: "dashboard"}
+	serve(tracker, principal, "GET /v1/networks/{network}/snapshots", "/v1/networks/mainnet/snapshots", false)
+
+	// Stopping flushes the pending usage
+	cancel()
+	<-done
+	store.Close()
+
+	store, err = OpenStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	restored := newTestTracker()
+	if err := restored.SetStore(store); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	serve(restored, principal, "GET /v1/networks/{network}/snapshots", "/v1/networks/mainnet/snapshots", false)
+
+	records := restored.Records()
+	if len(records) != 1 || records[0].Requests != 2 || records[0].Networks["mainnet"] != 2 {
+		t.Errorf("Expected usage to accumulate across restarts, got %+v", records)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	seen := time.Date(2025, 7, 6, 14, 30, 0, 0, time.UTC)
+	records := []Record{
+		{Key: "dashboard", Owner: "ops, inc", Requests: 12, Downloads: 2, FirstSeen: seen, LastSeen: seen,
+			Networks: map[string]int64{"testnet": 2, "mainnet": 10}, Types: map[string]int64{"full": 3}},
+		{Key: "idle", FirstSeen: seen, LastSeen: seen},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, records); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "key,owner,requests,downloads,first_seen,last_seen,networks,types\n" +
+		"dashboard,\"ops, inc\",12,2,2025-07-06T14:30:00Z,2025-07-06T14:30:00Z,mainnet=10;testnet=2,full=3\n" +
+		"idle,,0,0,2025-07-06T14:30:00Z,2025-07-06T14:30:00Z,,\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
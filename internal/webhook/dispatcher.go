@@ -0,0 +1,315 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Delivery request headers
+const (
+	HeaderEvent     = "X-Snapshots-Event"
+	HeaderDelivery  = "X-Snapshots-Delivery"
+	HeaderTimestamp = "X-Snapshots-Timestamp"
+	HeaderSignature = "X-Snapshots-Signature"
+)
+
+// Delivery states
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Defaults for retries and history
+const (
+	DefaultMaxAttempts = 5
+	DefaultBaseDelay   = time.Second
+	DefaultMaxDelay    = time.Minute
+	DefaultHistorySize = 500
+	defaultWorkers     = 4
+	queueSize          = 256
+	deliveryTimeout    = 10 * time.Second
+)
+
+// Delivery records the attempts to send one event to one endpoint
+type Delivery struct {
+	ID         string    `json:"id"`
+	EventID    string    `json:"event-id"`
+	EventType  string    `json:"event-type"`
+	URL        string    `json:"url"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status-code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created-at"`
+	UpdatedAt  time.Time `json:"updated-at"`
+
+	event Event
+}
+
+// Dispatcher posts signed events to webhook endpoints with retries
+type Dispatcher struct {
+	urls   []string
+	secret []byte
+	client *http.Client
+
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	deadLetter  string
+
+	queue chan *Delivery
+
+	mutex       sync.RWMutex
+	history     []*Delivery
+	historySize int
+}
+
+// NewDispatcher creates a dispatcher for the given endpoint URLs. Requests are signed
+// with secret; an empty secret sends them unsigned.
+func NewDispatcher(urls []string, secret string) *Dispatcher {
+	return &Dispatcher{
+		urls:        urls,
+		secret:      []byte(secret),
+		client:      &http.Client{Timeout: deliveryTimeout},
+		maxAttempts: DefaultMaxAttempts,
+		baseDelay:   DefaultBaseDelay,
+		maxDelay:    DefaultMaxDelay,
+		queue:       make(chan *Delivery, queueSize),
+		historySize: DefaultHistorySize,
+	}
+}
+
+// SetRetry configures the number of attempts per delivery and the initial backoff,
+// which doubles after every failed attempt up to DefaultMaxDelay
+func (d *Dispatcher) SetRetry(maxAttempts int, baseDelay time.Duration) {
+	if maxAttempts > 0 {
+		d.maxAttempts = maxAttempts
+	}
+	if baseDelay > 0 {
+		d.baseDelay = baseDelay
+	}
+}
+
+// SetDeadLetter appends deliveries that exhaust their attempts to path as JSON lines
+func (d *Dispatcher) SetDeadLetter(path string) {
+	d.deadLetter = path
+}
+
+// Publish queues every event for delivery to every endpoint
+func (d *Dispatcher) Publish(events []Event) {
+	for _, event := range events {
+		for _, url := range d.urls {
+			now := time.Now()
+			delivery := &Delivery{
+				ID:        newID(),
+				EventID:   event.ID,
+				EventType: event.Type,
+				URL:       url,
+				Status:    StatusPending,
+				CreatedAt: now,
+				UpdatedAt: now,
+				event:     event,
+			}
+			d.record(delivery)
+
+			select {
+			case d.queue <- delivery:
+			default:
+				d.fail(delivery, 0, fmt.Errorf("delivery queue is full"))
+			}
+		}
+	}
+}
+
+// Run delivers queued events until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < defaultWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case delivery := <-d.queue:
+					d.deliver(ctx, delivery)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Deliveries returns the most recent deliveries, newest first, optionally filtered by status
+func (d *Dispatcher) Deliveries(status string) []Delivery {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	deliveries := []Delivery{}
+	for i := len(d.history) - 1; i >= 0; i-- {
+		if status == "" || d.history[i].Status == status {
+			deliveries = append(deliveries, *d.history[i])
+		}
+	}
+	return deliveries
+}
+
+// deliver attempts a delivery until it succeeds, fails permanently or runs out of attempts
+func (d *Dispatcher) deliver(ctx context.Context, delivery *Delivery) {
+	body, err := json.Marshal(delivery.event)
+	if err != nil {
+		d.fail(delivery, 0, fmt.Errorf("failed to encode event: %w", err))
+		return
+	}
+
+	delay := d.baseDelay
+	for attempt := 1; ; attempt++ {
+		statusCode, err := d.post(ctx, delivery, body)
+		d.update(delivery, func() {
+			delivery.Attempts = attempt
+			delivery.StatusCode = statusCode
+			delivery.Error = ""
+			if err != nil {
+				delivery.Error = err.Error()
+			} else {
+				delivery.Status = StatusDelivered
+			}
+		})
+		if err == nil {
+			return
+		}
+
+		if attempt >= d.maxAttempts || !retryable(statusCode) {
+			d.fail(delivery, statusCode, err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			d.fail(delivery, statusCode, ctx.Err())
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, d.maxDelay)
+	}
+}
+
+// post sends one signed request and returns the response status
+func (d *Dispatcher) post(ctx context.Context, delivery *Delivery, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if len(d.secret) > 0 {
+		req.Header.Set(HeaderSignature, "sha256="+Sign(d.secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// fail marks a delivery as failed and writes it to the dead-letter log
+func (d *Dispatcher) fail(delivery *Delivery, statusCode int, err error) {
+	d.update(delivery, func() {
+		delivery.Status = StatusFailed
+		delivery.StatusCode = statusCode
+		delivery.Error = err.Error()
+	})
+
+	log.Printf("Webhook delivery %s of event %s to %s failed after %d attempts: %v",
+		delivery.ID, delivery.EventID, delivery.URL, delivery.Attempts, err)
+
+	if d.deadLetter == "" {
+		return
+	}
+
+	d.mutex.RLock()
+	entry := struct {
+		Delivery
+		Event Event `json:"event"`
+	}{*delivery, delivery.event}
+	d.mutex.RUnlock()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode dead-letter entry: %v", err)
+		return
+	}
+
+	file, err := os.OpenFile(d.deadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("Failed to open dead-letter log: %v", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to write dead-letter log: %v", err)
+	}
+}
+
+// record adds a delivery to the bounded history
+func (d *Dispatcher) record(delivery *Delivery) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.history = append(d.history, delivery)
+	if len(d.history) > d.historySize {
+		d.history = d.history[len(d.history)-d.historySize:]
+	}
+}
+
+// update changes a delivery under the history lock
+func (d *Dispatcher) update(delivery *Delivery, change func()) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	change()
+	delivery.UpdatedAt = time.Now()
+}
+
+// retryable reports whether a failed attempt may succeed later. Network errors,
+// 429 and 5xx responses are retried; other client errors are permanent.
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// Sign computes the hex HMAC-SHA256 of "timestamp.body", as sent in the
+// X-Snapshots-Signature header
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
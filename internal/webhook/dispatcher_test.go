@@ -0,0 +1,201 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// waitForStatus polls the history until every delivery reached a final state
+func waitForStatus(t *testing.T, dispatcher *Dispatcher, count int) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries := dispatcher.Deliveries("")
+		done := 0
+		for _, delivery := range deliveries {
+			if delivery.Status != StatusPending {
+				done++
+			}
+		}
+		if len(deliveries) == count && done == count {
+			return deliveries
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d deliveries: %+v", count, dispatcher.Deliveries(""))
+	return nil
+}
+
+func testEvent() Event {
+	return Event{
+		ID:           "event-1",
+		Type:         EventSnapshotPublished,
+		Network:      models.NetworkMainnet,
+		SnapshotType: models.SnapshotTypeLight,
+		Snapshot:     models.SnapshotInfo{Block: 100, URL: "https://example.com/mainnet-light-db-block-100.tar.gz"},
+	}
+}
+
+func TestDispatcher_SignedDelivery(t *testing.T) {
+	secret := "webhook-secret"
+	var mu sync.Mutex
+	var received Event
+	var signatureValid bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		signatureValid = r.Header.Get(HeaderSignature) == "sha256="+Sign([]byte(secret), r.Header.Get(HeaderTimestamp), body)
+		if r.Header.Get(HeaderEvent) != EventSnapshotPublished || r.Header.Get(HeaderDelivery) == "" {
+			t.Errorf("Missing event headers: %v", r.Header)
+		}
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher([]string{server.URL}, secret)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	dispatcher.Publish([]Event{testEvent()})
+	deliveries := waitForStatus(t, dispatcher, 1)
+
+	if deliveries[0].Status != StatusDelivered || deliveries[0].Attempts != 1 || deliveries[0].StatusCode != http.StatusOK {
+		t.Errorf("Unexpected delivery: %+v", deliveries[0])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !signatureValid {
+		t.Error("Expected a valid signature")
+	}
+	if received.ID != "event-1" || received.Snapshot.Block != 100 {
+		t.Errorf("Unexpected event body: %+v", received)
+	}
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	tests := []struct {
+		name             string
+		responses        []int
+		expectedStatus   string
+		expectedAttempts int
+	}{
+		{
+			name:             "recovers after server errors",
+			responses:        []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			expectedStatus:   StatusDelivered,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "gives up after max attempts",
+			responses:        []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			expectedStatus:   StatusFailed,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "client errors are not retried",
+			responses:        []int{http.StatusGone},
+			expectedStatus:   StatusFailed,
+			expectedAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				w.WriteHeader(tt.responses[min(calls, len(tt.responses)-1)])
+				calls++
+			}))
+			defer server.Close()
+
+			deadLetter := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+			dispatcher := NewDispatcher([]string{server.URL}, "")
+			dispatcher.SetRetry(3, time.Millisecond)
+			dispatcher.SetDeadLetter(deadLetter)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go dispatcher.Run(ctx)
+
+			dispatcher.Publish([]Event{testEvent()})
+			delivery := waitForStatus(t, dispatcher, 1)[0]
+
+			if delivery.Status != tt.expectedStatus || delivery.Attempts != tt.expectedAttempts {
+				t.Errorf("Expected %s after %d attempts, got %s after %d", tt.expectedStatus, tt.expectedAttempts, delivery.Status, delivery.Attempts)
+			}
+
+			file, err := os.Open(deadLetter)
+			if tt.expectedStatus == StatusDelivered {
+				if err == nil {
+					file.Close()
+					t.Error("Expected no dead-letter log for a delivered event")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected dead-letter log: %v", err)
+			}
+			defer file.Close()
+
+			scanner := bufio.NewScanner(file)
+			if !scanner.Scan() {
+				t.Fatal("Expected a dead-letter entry")
+			}
+			var entry struct {
+				ID    string `json:"id"`
+				Event Event  `json:"event"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("Invalid dead-letter entry: %v", err)
+			}
+			if entry.ID != delivery.ID || entry.Event.ID != "event-1" {
+				t.Errorf("Unexpected dead-letter entry: %s", scanner.Text())
+			}
+		})
+	}
+}
+
+func TestDispatcher_Deliveries(t *testing.T) {
+	dispatcher := NewDispatcher([]string{"http://a.invalid", "http://b.invalid"}, "")
+	dispatcher.historySize = 3
+
+	// Without Run nothing is delivered and the history stays pending
+	dispatcher.Publish([]Event{testEvent(), testEvent()})
+
+	deliveries := dispatcher.Deliveries("")
+	if len(deliveries) != 3 {
+		t.Fatalf("Expected history capped at 3, got %d", len(deliveries))
+	}
+	if deliveries[0].URL != "http://b.invalid" {
+		t.Errorf("Expected newest delivery first, got %s", deliveries[0].URL)
+	}
+	if len(dispatcher.Deliveries(StatusDelivered)) != 0 {
+		t.Error("Expected no delivered deliveries")
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	expected := "086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54"
+	if got := Sign([]byte("secret"), "1700000000", []byte(`{"id":"1"}`)); got != expected {
+		t.Errorf("Expected signature %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+// EventSnapshotPublished is sent for every snapshot that appears in storage
+const EventSnapshotPublished = "snapshot.published"
+
+// Event is the JSON body posted to webhook endpoints
+type Event struct {
+	ID           string              `json:"id"`
+	Type         string              `json:"type"`
+	Time         time.Time           `json:"time"`
+	Network      models.Network      `json:"network"`
+	Snapshot     models.SnapshotInfo `json:"snapshot"`
+	SnapshotType models.SnapshotType `json:"snapshot-type"`
+}
+
+// Published returns a published event for every added snapshot, in the given order
+func Published(added []*models.Snapshot, now time.Time) []Event {
+	var events []Event
+	for _, snapshot := range added {
+		info := *snapshot.ToSnapshotInfo()
+		events = append(events, Event{
+			ID:           eventID(EventSnapshotPublished, snapshot.Network, snapshot.Type, info),
+			Type:         EventSnapshotPublished,
+			Time:         now,
+			Network:      snapshot.Network,
+			Snapshot:     info,
+			SnapshotType: snapshot.Type,
+		})
+	}
+	return events
+}
+
+// eventID derives the ID of an event from the snapshot it reports, so every replica
+// announcing the same snapshot sends the same ID and subscribers can deduplicate
+func eventID(eventType string, network models.Network, snapshotType models.SnapshotType, info models.SnapshotInfo) string {
+	key := fmt.Sprintf("%s/%s/%s/%d/%s", eventType, network, snapshotType, info.Block, path.Base(info.URL))
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:12])
+}
+
+// newID returns a random identifier for deliveries
+func newID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
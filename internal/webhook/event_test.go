@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/taraxa/snapshots-api/internal/models"
+)
+
+func snapshot(snapshotType models.SnapshotType, block int64) *models.Snapshot {
+	filename := "mainnet-" + string(snapshotType) + "-db-block-" + time.Unix(block, 0).UTC().Format("150405") + ".tar.gz"
+	return &models.Snapshot{
+		Network:   models.NetworkMainnet,
+		Type:      snapshotType,
+		Block:     block,
+		Timestamp: time.Date(2025, 7, 6, 0, 0, int(block), 0, time.UTC),
+		URL:       "https://storage.googleapis.com/taraxa-snapshot/" + filename,
+		Filename:  filename,
+	}
+}
+
+func TestPublished(t *testing.T) {
+	now := time.Date(2025, 7, 10, 12, 0, 0, 0, time.UTC)
+	full200, light150, light190 := snapshot(models.SnapshotTypeFull, 200), snapshot(models.SnapshotTypeLight, 150), snapshot(models.SnapshotTypeLight, 190)
+
+	tests := []struct {
+		name     string
+		added    []*models.Snapshot
+		expected []int64
+	}{
+		{
+			name: "nothing added",
+		},
+		{
+			name:     "new full snapshot",
+			added:    []*models.Snapshot{full200},
+			expected: []int64{200},
+		},
+		{
+			name:     "several new snapshots keep their order",
+			added:    []*models.Snapshot{light150, light190},
+			expected: []int64{150, 190},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := Published(tt.added, now)
+			if len(events) != len(tt.expected) {
+				t.Fatalf("Expected %d events, got %d", len(tt.expected), len(events))
+			}
+			for i, event := range events {
+				if event.Snapshot.Block != tt.expected[i] {
+					t.Errorf("Event %d: expected block %d, got %d", i, tt.expected[i], event.Snapshot.Block)
+				}
+				if event.Type != EventSnapshotPublished || event.Network != models.NetworkMainnet || event.ID == "" || event.Snapshot.URL != tt.added[i].URL {
+					t.Errorf("Unexpected event: %+v", event)
+				}
+			}
+		})
+	}
+
+	events := Published([]*models.Snapshot{full200}, now)
+	if len(events) != 1 || events[0].SnapshotType != models.SnapshotTypeFull {
+		t.Errorf("Expected a full snapshot event, got %+v", events)
+	}
+
+	// Another replica reporting the same snapshot later sends the same ID
+	again := Published([]*models.Snapshot{full200, light190}, now.Add(time.Minute))
+	if len(again) != 2 || again[0].ID != events[0].ID || again[1].ID == events[0].ID {
+		t.Errorf("Expected event IDs derived from the snapshot, got %+v and %+v", events, again)
+	}
+}